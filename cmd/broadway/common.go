@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/namely/broadway/pkg/cfg"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -44,6 +46,18 @@ var CommonFlags = []cli.Flag{
 		EnvVar:      "KUBERNETES_CA_FILE",
 		Destination: &cfg.GlobalCfg.K8sCAFile,
 	},
+	cli.StringFlag{
+		Name:        "kubeconfig",
+		Usage:       "kubectl configuration files to connect to Kubernetes with, taking precedence over the other Kubernetes flags",
+		EnvVar:      "BROADWAY_KUBECONFIG,KUBECONFIG",
+		Destination: &cfg.GlobalCfg.Kubeconfig,
+	},
+	cli.StringFlag{
+		Name:        "kube-context",
+		Usage:       "the kubeconfig context to use instead of its current one",
+		EnvVar:      "KUBE_CONTEXT",
+		Destination: &cfg.GlobalCfg.KubeContext,
+	},
 	cli.StringFlag{
 		Name:        "etcd-endpoints",
 		Usage:       "one or more comma separated etcd endpoints",
@@ -58,6 +72,20 @@ var CommonFlags = []cli.Flag{
 		EnvVar:      "ETCD_PATH",
 		Destination: &cfg.GlobalCfg.EtcdPath,
 	},
+	cli.DurationFlag{
+		Name:        "etcd-timeout",
+		Usage:       "the timeout for each etcd request",
+		Value:       5 * time.Second,
+		EnvVar:      "ETCD_TIMEOUT",
+		Destination: &cfg.GlobalCfg.EtcdTimeout,
+	},
+	cli.IntFlag{
+		Name:        "etcd-retries",
+		Usage:       "how many times a failed etcd request is retried",
+		Value:       2,
+		EnvVar:      "ETCD_RETRIES",
+		Destination: &cfg.GlobalCfg.EtcdRetries,
+	},
 	cli.StringFlag{
 		Name:        "playbook-dir",
 		Usage:       "path to a folder containing broadway playbooks",
@@ -88,9 +116,82 @@ var CommonFlags = []cli.Flag{
 	},
 	cli.IntFlag{
 		Name:        "instance-cleanup-time",
-		Usage:       "the amount of time in seconds to do the instances cleanup",
+		Usage:       "the amount of time in seconds to do the instances cleanup, 0 disables it",
 		Value:       15,
 		EnvVar:      "INSTANCE_CLEANUP",
 		Destination: &cfg.GlobalCfg.InstanceCleanup,
 	},
+	cli.BoolFlag{
+		Name:        "cleanup-dry-run",
+		Usage:       "only log what the instance cleanup worker would stop or delete",
+		EnvVar:      "CLEANUP_DRY_RUN",
+		Destination: &cfg.GlobalCfg.CleanupDryRun,
+	},
+	cli.DurationFlag{
+		Name:        "expiry-warning",
+		Usage:       "how long before an instance expires a warning is sent to Slack, 0 disables warnings",
+		Value:       24 * time.Hour,
+		EnvVar:      "EXPIRY_WARNING",
+		Destination: &cfg.GlobalCfg.ExpiryWarning,
+	},
+	cli.IntFlag{
+		Name:        "history-retention",
+		Usage:       "how many history records and revisions are kept per instance, 0 keeps all",
+		Value:       25,
+		EnvVar:      "HISTORY_RETENTION",
+		Destination: &cfg.GlobalCfg.HistoryRetention,
+	},
+	cli.DurationFlag{
+		Name:        "lease-ttl",
+		Usage:       "how long a deploy or stop may go without a heartbeat before it's considered abandoned",
+		Value:       90 * time.Second,
+		EnvVar:      "LEASE_TTL",
+		Destination: &cfg.GlobalCfg.LeaseTTL,
+	},
+	cli.BoolFlag{
+		Name:        "resume-interrupted",
+		Usage:       "run abandoned deploys and stops again instead of leaving the instance in error",
+		EnvVar:      "RESUME_INTERRUPTED",
+		Destination: &cfg.GlobalCfg.ResumeInterrupted,
+	},
+	cli.DurationFlag{
+		Name:        "job-retention",
+		Usage:       "how long finished jobs are kept, 0 keeps all",
+		Value:       24 * time.Hour,
+		EnvVar:      "JOB_RETENTION",
+		Destination: &cfg.GlobalCfg.JobRetention,
+	},
+	cli.IntFlag{
+		Name:        "workers",
+		Usage:       "how many deploys and stops run at once",
+		Value:       4,
+		EnvVar:      "WORKERS",
+		Destination: &cfg.GlobalCfg.Workers,
+	},
+	cli.IntFlag{
+		Name:        "playbook-workers",
+		Usage:       "how many deploys and stops of a single playbook run at once, 0 only applies --workers",
+		Value:       2,
+		EnvVar:      "PLAYBOOK_WORKERS",
+		Destination: &cfg.GlobalCfg.PlaybookWorkers,
+	},
+	cli.DurationFlag{
+		Name:        "drift-interval",
+		Usage:       "how often deployed instances are compared with the cluster, 0 disables drift detection",
+		Value:       10 * time.Minute,
+		EnvVar:      "DRIFT_INTERVAL",
+		Destination: &cfg.GlobalCfg.DriftInterval,
+	},
+	cli.StringFlag{
+		Name:        "targets-file",
+		Usage:       "a YAML file defining named Kubernetes cluster targets",
+		EnvVar:      "TARGETS_FILE",
+		Destination: &cfg.GlobalCfg.TargetsFile,
+	},
+	cli.StringFlag{
+		Name:        "default-target",
+		Usage:       "the target of instances whose playbook doesn't choose one, instead of the cluster set by the kubernetes flags",
+		EnvVar:      "DEFAULT_TARGET",
+		Destination: &cfg.GlobalCfg.DefaultTarget,
+	},
 }
@@ -3,11 +3,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/metrics"
 	"github.com/namely/broadway/pkg/server"
 	"github.com/namely/broadway/pkg/store/etcdstore"
 )
@@ -15,9 +17,27 @@ import (
 // ServerCmd is executed by cli on `broadway server`
 var ServerCmd = func(c *cli.Context) error {
 	etcdstore.Setup(cfg.GlobalCfg)  // configure etcd before using
-	deployment.Setup(cfg.GlobalCfg) // configure kubernetes deployments before using
+	deployment.Setup(cfg.GlobalCfg) // load playbooks before using
+	if cfg.GlobalCfg.TargetsFile != "" {
+		targets, err := cfg.LoadTargets(cfg.GlobalCfg.TargetsFile)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		cfg.GlobalCfg.Targets = targets
+	}
+	s := server.New(cfg.GlobalCfg, metrics.InstrumentStore(etcdstore.New()))
+	switch cfg.GlobalCfg.Deployer {
+	case "kubernetes":
+		if err := reportClusters(cfg.GlobalCfg); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	case "fake":
+		fmt.Println("deploying nowhere: the fake deployer only logs the objects it renders")
+		s.Deployers = deployment.NewFake().Factory
+	default:
+		return cli.NewExitError(fmt.Sprintf("Unknown deployer %s, use kubernetes or fake", cfg.GlobalCfg.Deployer), 1)
+	}
 	fmt.Printf("starting server with config...\n%+v", cfg.GlobalCfg)
-	s := server.New(cfg.GlobalCfg, etcdstore.New())
 	s.Init()
 	if err := s.Run(cfg.GlobalCfg.ServerHost); err != nil {
 		panic(err)
@@ -25,6 +45,35 @@ var ServerCmd = func(c *cli.Context) error {
 	return nil
 }
 
+// reportClusters prints the clusters broadway deploys to, failing if the
+// cluster of the Kubernetes flags is needed but not configured
+func reportClusters(c cfg.Type) error {
+	if c.DefaultTarget == "" {
+		config, err := deployment.Config(c)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deploying to Kubernetes cluster %s, namespace %s\n", config.Host, c.K8sNamespace)
+	}
+	names := []string{}
+	for name := range c.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := c.Targets[name]
+		namespace := t.Namespace
+		if namespace == "" {
+			namespace = c.K8sNamespace
+		}
+		fmt.Printf("target %s is Kubernetes cluster %s, namespace %s\n", name, t.Host, namespace)
+	}
+	if c.DefaultTarget != "" {
+		fmt.Printf("deploying to target %s by default\n", c.DefaultTarget)
+	}
+	return nil
+}
+
 // ServerCmdFlags declares what flags can be passed to the `server` subcommand
 var ServerCmdFlags = []cli.Flag{
 	cli.StringFlag{
@@ -34,12 +83,25 @@ var ServerCmdFlags = []cli.Flag{
 		EnvVar:      "HOST",
 		Destination: &cfg.GlobalCfg.ServerHost,
 	},
+	cli.StringFlag{
+		Name:        "deployer",
+		Value:       "kubernetes",
+		Usage:       "the backend deploying instances: kubernetes, or fake to only log the objects rendered from manifests",
+		EnvVar:      "BROADWAY_DEPLOYER",
+		Destination: &cfg.GlobalCfg.Deployer,
+	},
 	cli.StringFlag{
 		Name:        "auth-token",
 		Usage:       "a global bearer token required for http api requests", // but not GET/POST command/
 		EnvVar:      "BROADWAY_AUTH_TOKEN",
 		Destination: &cfg.GlobalCfg.AuthBearerToken,
 	},
+	cli.StringFlag{
+		Name:        "metrics-token",
+		Usage:       "a bearer token required for GET /metrics, which is unauthenticated without it",
+		EnvVar:      "BROADWAY_METRICS_TOKEN",
+		Destination: &cfg.GlobalCfg.MetricsToken,
+	},
 	// slack-token is sent from Slack to POST command/ and can be found on Slack's Custom Command configuration page.
 	// broadway denies the request if the received token doesn't match this config value
 	cli.StringFlag{
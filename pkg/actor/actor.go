@@ -0,0 +1,52 @@
+package actor
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// Sources an Actor can act through
+const (
+	SourceAPI    = "api"
+	SourceSlack  = "slack"
+	SourceSystem = "system"
+)
+
+// Actor identifies who triggered an action in broadway
+type Actor struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+}
+
+// System is the Actor used for work broadway starts on its own, such as the
+// expired instances cleanup
+var System = Actor{Source: SourceSystem, Name: "broadway"}
+
+func (a Actor) String() string {
+	return fmt.Sprintf("%s:%s", a.Source, a.Name)
+}
+
+type key int
+
+const actorKey key = 0
+
+// NewContext returns a copy of ctx carrying a as the actor responsible for the
+// work done with it
+func NewContext(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey, a)
+}
+
+// FromContext returns the Actor stored in ctx, or System if there is none
+func FromContext(ctx context.Context) Actor {
+	if a, ok := ctx.Value(actorKey).(Actor); ok {
+		return a
+	}
+	return System
+}
+
+// Detach returns a background context carrying the Actor from ctx. Use it for
+// work that outlives the request that started it.
+func Detach(ctx context.Context) context.Context {
+	return NewContext(context.Background(), FromContext(ctx))
+}
@@ -0,0 +1,27 @@
+package actor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, System, FromContext(context.Background()))
+
+	bill := Actor{Source: SourceSlack, Name: "bill"}
+	ctx := NewContext(context.Background(), bill)
+	assert.Equal(t, bill, FromContext(ctx))
+	assert.Equal(t, "slack:bill", FromContext(ctx).String())
+}
+
+func TestDetach(t *testing.T) {
+	bill := Actor{Source: SourceSlack, Name: "bill"}
+	ctx, cancel := context.WithCancel(NewContext(context.Background(), bill))
+	cancel()
+
+	detached := Detach(ctx)
+	assert.Nil(t, detached.Err())
+	assert.Equal(t, bill, FromContext(detached))
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionSetVar   = "setvar"
+	ActionExtend   = "extend"
+	ActionPin      = "pin"
+	ActionUnpin    = "unpin"
+	ActionDeploy   = "deploy"
+	ActionRollback = "rollback"
+	ActionStop     = "stop"
+	ActionCancel   = "cancel"
+	ActionDelete   = "delete"
+	ActionExpire   = "expire"
+)
+
+// Path represents the store path holding the audit log
+type Path struct {
+	RootPath string
+}
+
+func (p Path) String() string {
+	return fmt.Sprintf("%s/audit", p.RootPath)
+}
+
+// Summary is the state of an instance before or after an action
+type Summary struct {
+	Status    string            `json:"status"`
+	Target    string            `json:"target,omitempty"`
+	Revision  int               `json:"revision,omitempty"`
+	ExpiredAt int64             `json:"expired_at,omitempty"`
+	Pinned    bool              `json:"pinned,omitempty"`
+	Vars      map[string]string `json:"vars,omitempty"`
+}
+
+// Entry records who took an action on an instance, and when. Entries are
+// never changed once saved.
+type Entry struct {
+	ID         string   `json:"id"`
+	Time       int64    `json:"time"`
+	Actor      string   `json:"actor"`
+	Source     string   `json:"source"`
+	Action     string   `json:"action"`
+	PlaybookID string   `json:"playbook_id"`
+	InstanceID string   `json:"instance_id"`
+	JobID      string   `json:"job_id,omitempty"`
+	Detail     string   `json:"detail,omitempty"`
+	Before     *Summary `json:"before,omitempty"`
+	After      *Summary `json:"after,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// NewEntry starts an Entry for an action a took on an instance at time now
+func NewEntry(a actor.Actor, action, playbookID, instanceID string, now time.Time) *Entry {
+	return &Entry{
+		ID:         store.TimeKey(now),
+		Time:       now.Unix(),
+		Actor:      a.Name,
+		Source:     a.Source,
+		Action:     action,
+		PlaybookID: playbookID,
+		InstanceID: instanceID,
+	}
+}
+
+// Save appends an entry to the audit log
+func Save(ctx context.Context, s store.Store, root string, e *Entry) error {
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.SetValue(ctx, Path{RootPath: root}.String()+"/"+e.ID, string(encoded))
+}
+
+// Query filters the audit log. Empty fields match every entry.
+type Query struct {
+	Actor      string
+	Source     string
+	Actions    []string
+	PlaybookID string
+	InstanceID string
+	// From and To bound the time of the entries, as Unix timestamps
+	From int64
+	To   int64
+	// Limit keeps the newest entries only, 0 keeps them all
+	Limit int
+}
+
+// Matches returns true if e passes the filters of q
+func (q Query) Matches(e *Entry) bool {
+	switch {
+	case q.Actor != "" && e.Actor != q.Actor,
+		q.Source != "" && e.Source != q.Source,
+		q.PlaybookID != "" && e.PlaybookID != q.PlaybookID,
+		q.InstanceID != "" && e.InstanceID != q.InstanceID,
+		q.From != 0 && e.Time < q.From,
+		q.To != 0 && e.Time > q.To:
+		return false
+	}
+	if len(q.Actions) == 0 {
+		return true
+	}
+	for _, a := range q.Actions {
+		if e.Action == a {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the entries of the audit log matching q, newest first
+func List(ctx context.Context, s store.Store, root string, q Query) ([]*Entry, error) {
+	values, err := s.Values(ctx, Path{RootPath: root}.String())
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, v := range values {
+		e := &Entry{}
+		if err := json.Unmarshal([]byte(v), e); err != nil {
+			return nil, err
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Sort(sort.Reverse(byID(entries)))
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
+
+// WriteJSONLines writes entries to w as JSON Lines, one entry per line
+func WriteJSONLines(w io.Writer, entries []*Entry) error {
+	encoder := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byID []*Entry
+
+func (ee byID) Len() int           { return len(ee) }
+func (ee byID) Less(i, j int) bool { return ee[i].ID < ee[j].ID }
+func (ee byID) Swap(i, j int)      { ee[i], ee[j] = ee[j], ee[i] }
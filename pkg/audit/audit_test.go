@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSaveAndList(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	bill := actor.Actor{Source: actor.SourceSlack, Name: "bill"}
+	api := actor.Actor{Source: actor.SourceAPI, Name: "token"}
+
+	e1 := NewEntry(api, ActionCreate, "web", "master", t0)
+	e1.After = &Summary{Vars: map[string]string{"version": "1"}}
+	e2 := NewEntry(bill, ActionSetVar, "web", "master", t0.Add(time.Hour))
+	e2.Before = e1.After
+	e2.After = &Summary{Vars: map[string]string{"version": "2"}}
+	e3 := NewEntry(bill, ActionDeploy, "api", "master", t0.Add(2*time.Hour))
+	e4 := NewEntry(actor.System, ActionExpire, "web", "pr-1", t0.Add(24*time.Hour))
+	for _, e := range []*Entry{e1, e2, e3, e4} {
+		assert.Nil(t, Save(ctx, s, "/broadwaytest", e))
+	}
+
+	cases := []struct {
+		Name     string
+		Query    Query
+		Expected []*Entry
+	}{
+		{"everything, newest first", Query{}, []*Entry{e4, e3, e2, e1}},
+		{"by actor", Query{Actor: "bill"}, []*Entry{e3, e2}},
+		{"by source", Query{Source: actor.SourceSystem}, []*Entry{e4}},
+		{"by actions", Query{Actions: []string{ActionCreate, ActionDeploy}}, []*Entry{e3, e1}},
+		{"by instance", Query{PlaybookID: "web", InstanceID: "master"}, []*Entry{e2, e1}},
+		{"by time", Query{From: t0.Add(time.Hour).Unix(), To: t0.Add(2 * time.Hour).Unix()}, []*Entry{e3, e2}},
+		{"limited", Query{Limit: 1}, []*Entry{e4}},
+	}
+	for _, c := range cases {
+		entries, err := List(ctx, s, "/broadwaytest", c.Query)
+		assert.Nil(t, err, c.Name)
+		assert.Equal(t, c.Expected, entries, c.Name)
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	e := NewEntry(actor.Actor{Source: actor.SourceSlack, Name: "bill"}, ActionStop, "web", "master", t0)
+	e.JobID = "01"
+	e.Before = &Summary{Status: "deployed"}
+
+	var b bytes.Buffer
+	assert.Nil(t, WriteJSONLines(&b, []*Entry{e, e}))
+	line := `{"id":"` + e.ID + `","time":1470355200,"actor":"bill","source":"slack","action":"stop","playbook_id":"web","instance_id":"master","job_id":"01","before":{"status":"deployed"}}` + "\n"
+	assert.Equal(t, line+line, b.String())
+}
+
+func TestSameTimeEntries(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	bill := actor.Actor{Source: actor.SourceSlack, Name: "bill"}
+
+	e1 := NewEntry(bill, ActionDeploy, "web", "master", now)
+	e2 := NewEntry(bill, ActionDeploy, "api", "master", now)
+	assert.NotEqual(t, e1.ID, e2.ID)
+	assert.True(t, strings.HasPrefix(e1.ID, "01470355200000000000-"), "IDs start with the time")
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", e1))
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", e2))
+
+	entries, err := List(ctx, s, "/broadwaytest", Query{})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2, "entries recorded at the same time are all kept")
+}
@@ -1,26 +1,46 @@
 package cfg
 
+import "time"
+
 // GlobalCfg is this deployment's global configuration object
 // Only touch this in main.go and cmd/*.go, inject cfg dependency into all other code
 var GlobalCfg Type
 
 // Type declares what the common config looks like
 type Type struct {
-	K8sServiceHost         string // the Kubernetes host
-	K8sServicePort         string // the Kubernetes port
-	K8sNamespace           string // the namespace used by Broadway's deployments
-	K8sCertFile            string // the cert file setting for local development
-	K8sKeyFile             string // the key file setting for local development
-	K8sCAFile              string // the CA file setting for local development
-	EtcdEndpoints          string // the list Etcd hosts separated by comma
-	EtcdPath               string // the root directory for Broadway objects
-	PlaybooksPath          string // the folder where playbooks are found
-	ManifestsPath          string // the folder where manifests are found
-	ManifestsExtension     string // .yml or .yaml
-	AuthBearerToken        string // a global token required for all requests except GET/POST command/
-	SlackToken             string // the expected Slack custom command token.
-	ServerHost             string // passed to gin and configures the listen address of the server
-	SlackWebhook           string // your team's slack incoming message webhook URL
-	InstanceExpirationDays int    // the amount of time in days for expiring an Instance
-	InstanceCleanup        int    // the amount of time in seconds for doing the expired instances cleanup
+	K8sServiceHost         string            // the Kubernetes host
+	K8sServicePort         string            // the Kubernetes port
+	K8sNamespace           string            // the namespace used by Broadway's deployments
+	K8sCertFile            string            // the cert file setting for local development
+	K8sKeyFile             string            // the key file setting for local development
+	K8sCAFile              string            // the CA file setting for local development
+	Deployer               string            // the backend deploying instances, kubernetes or fake
+	Kubeconfig             string            // the kubectl configuration files, separated like $KUBECONFIG, taking precedence over the other K8s settings
+	KubeContext            string            // the kubeconfig context to use instead of its current one
+	EtcdEndpoints          string            // the list Etcd hosts separated by comma
+	EtcdPath               string            // the root directory for Broadway objects
+	EtcdTimeout            time.Duration     // the timeout applied to each etcd request
+	EtcdRetries            int               // how many times a failed etcd request is retried
+	PlaybooksPath          string            // the folder where playbooks are found
+	ManifestsPath          string            // the folder where manifests are found
+	ManifestsExtension     string            // .yml or .yaml
+	AuthBearerToken        string            // a global token required for all requests except GET/POST command/
+	MetricsToken           string            // the token required by GET /metrics, which is unauthenticated if it's empty
+	SlackToken             string            // the expected Slack custom command token.
+	ServerHost             string            // passed to gin and configures the listen address of the server
+	SlackWebhook           string            // your team's slack incoming message webhook URL
+	InstanceExpirationDays int               // the amount of time in days for expiring an Instance
+	InstanceCleanup        int               // the amount of time in seconds for doing the expired instances cleanup
+	CleanupDryRun          bool              // whether the cleanup worker only logs what it would do
+	ExpiryWarning          time.Duration     // how long before an instance expires a warning is sent, 0 disables warnings
+	HistoryRetention       int               // how many history records and revisions are kept per instance, 0 keeps all
+	LeaseTTL               time.Duration     // how long a deploy or stop may go without a heartbeat before it's considered abandoned
+	ResumeInterrupted      bool              // whether abandoned deploys and stops are run again after being recovered
+	JobRetention           time.Duration     // how long finished jobs are kept, 0 keeps all
+	Workers                int               // how many deploys and stops run at once
+	PlaybookWorkers        int               // how many deploys and stops of a single playbook run at once, 0 only applies Workers
+	DriftInterval          time.Duration     // how often deployed instances are compared with the cluster, 0 disables drift detection
+	TargetsFile            string            // the YAML file defining the cluster targets
+	Targets                map[string]Target // the cluster targets instances can be deployed to, by name
+	DefaultTarget          string            // the target of instances whose playbook doesn't choose one, empty for the cluster configured by the K8s settings
 }
@@ -0,0 +1,154 @@
+package cfg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// kubeconfig is the part of a kubectl configuration file broadway understands
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// DefaultKubeconfig returns where kubectl looks for its configuration by
+// default
+func DefaultKubeconfig() string {
+	return filepath.Join(os.Getenv("HOME"), ".kube", "config")
+}
+
+// LoadKubeconfig returns the cluster and user of a context of a kubectl
+// configuration file, or of its current context if context is empty. paths
+// may list several files like $KUBECONFIG does, the first one that exists is
+// used.
+func LoadKubeconfig(paths, context string) (Target, error) {
+	path := ""
+	for _, p := range filepath.SplitList(paths) {
+		if _, err := os.Stat(p); err == nil {
+			path = p
+			break
+		}
+	}
+	if path == "" {
+		return Target{}, fmt.Errorf("No kubeconfig found at %s", paths)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Target{}, err
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return Target{}, fmt.Errorf("Invalid kubeconfig %s: %s", path, err)
+	}
+
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	if context == "" {
+		return Target{}, fmt.Errorf("Kubeconfig %s has no current context, choose one with --kube-context", path)
+	}
+	clusterName, userName, found := "", "", false
+	for _, c := range kc.Contexts {
+		if c.Name == context {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+	if !found {
+		return Target{}, fmt.Errorf("Kubeconfig %s has no context %s", path, context)
+	}
+
+	// Files are relative to the kubeconfig, like kubectl resolves them:
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	t := Target{}
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		t.Host = c.Cluster.Server
+		t.CAFile = resolve(c.Cluster.CertificateAuthority)
+		t.Insecure = c.Cluster.InsecureSkipTLSVerify
+		if t.CAData, err = decodeKubeconfigData(c.Cluster.CertificateAuthorityData); err != nil {
+			return Target{}, fmt.Errorf("Kubeconfig %s has an invalid CA for cluster %s: %s", path, clusterName, err)
+		}
+	}
+	if !found {
+		return Target{}, fmt.Errorf("Kubeconfig %s has no cluster %s for context %s", path, clusterName, context)
+	}
+	if t.Host == "" {
+		return Target{}, fmt.Errorf("Kubeconfig %s is missing the server of cluster %s", path, clusterName)
+	}
+
+	found = userName == ""
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		found = true
+		t.Token = u.User.Token
+		t.TokenFile = resolve(u.User.TokenFile)
+		t.Username = u.User.Username
+		t.Password = u.User.Password
+		t.CertFile = resolve(u.User.ClientCertificate)
+		t.KeyFile = resolve(u.User.ClientKey)
+		if t.CertData, err = decodeKubeconfigData(u.User.ClientCertificateData); err != nil {
+			return Target{}, fmt.Errorf("Kubeconfig %s has an invalid certificate for user %s: %s", path, userName, err)
+		}
+		if t.KeyData, err = decodeKubeconfigData(u.User.ClientKeyData); err != nil {
+			return Target{}, fmt.Errorf("Kubeconfig %s has an invalid key for user %s: %s", path, userName, err)
+		}
+	}
+	if !found {
+		return Target{}, fmt.Errorf("Kubeconfig %s has no user %s for context %s", path, userName, context)
+	}
+	return t, nil
+}
+
+// decodeKubeconfigData decodes the base64 PEM data embedded in kubeconfigs
+func decodeKubeconfigData(data string) ([]byte, error) {
+	if data == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
@@ -0,0 +1,102 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority: ca.pem
+- name: remote
+  cluster:
+    server: https://k8s.example.com
+    certificate-authority-data: Y2E=
+contexts:
+- name: dev
+  context:
+    cluster: local
+    user: admin
+- name: ci
+  context:
+    cluster: remote
+    user: robot
+- name: shared
+  context:
+    cluster: remote
+    user: basic
+- name: broken
+  context:
+    cluster: elsewhere
+    user: admin
+users:
+- name: admin
+  user:
+    client-certificate: /etc/k8s/admin.pem
+    client-key-data: a2V5
+- name: robot
+  user:
+    token: s3cr3t
+- name: basic
+  user:
+    username: bill
+    password: hunter2
+`
+
+func TestLoadKubeconfig(t *testing.T) {
+	path := writeTargets(t, testKubeconfig)
+	defer os.Remove(path)
+
+	testcases := []struct {
+		Scenario string
+		Context  string
+		Expected Target
+		Error    string
+	}{
+		{
+			"The current context is used by default, relative files are resolved next to the kubeconfig",
+			"",
+			Target{Host: "https://127.0.0.1:6443", CAFile: filepath.Join(filepath.Dir(path), "ca.pem"), CertFile: "/etc/k8s/admin.pem", KeyData: []byte("key")},
+			"",
+		},
+		{"Token users", "ci", Target{Host: "https://k8s.example.com", CAData: []byte("ca"), Token: "s3cr3t"}, ""},
+		{"Basic auth users", "shared", Target{Host: "https://k8s.example.com", CAData: []byte("ca"), Username: "bill", Password: "hunter2"}, ""},
+		{"Missing contexts", "prod", Target{}, "Kubeconfig " + path + " has no context prod"},
+		{"Missing clusters", "broken", Target{}, "Kubeconfig " + path + " has no cluster elsewhere for context broken"},
+	}
+	for _, tc := range testcases {
+		target, err := LoadKubeconfig(path, tc.Context)
+		if tc.Error != "" {
+			assert.EqualError(t, err, tc.Error, tc.Scenario)
+			continue
+		}
+		assert.Nil(t, err, tc.Scenario)
+		assert.Equal(t, tc.Expected, target, tc.Scenario)
+	}
+}
+
+func TestLoadKubeconfigPaths(t *testing.T) {
+	path := writeTargets(t, testKubeconfig)
+	defer os.Remove(path)
+
+	target, err := LoadKubeconfig("/nonexistent/config"+string(filepath.ListSeparator)+path, "ci")
+	assert.Nil(t, err, "the first kubeconfig that exists is used")
+	assert.Equal(t, "https://k8s.example.com", target.Host)
+
+	_, err = LoadKubeconfig("/nonexistent/config", "")
+	assert.EqualError(t, err, "No kubeconfig found at /nonexistent/config")
+
+	noContext := writeTargets(t, "clusters: []\n")
+	defer os.Remove(noContext)
+	_, err = LoadKubeconfig(noContext, "")
+	assert.EqualError(t, err, "Kubeconfig "+noContext+" has no current context, choose one with --kube-context")
+}
@@ -0,0 +1,48 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Target is a named Kubernetes cluster instances can be deployed to
+type Target struct {
+	Host      string `yaml:"host"`       // the Kubernetes API URL
+	Namespace string `yaml:"namespace"`  // the default namespace, instead of K8sNamespace
+	Token     string `yaml:"token"`      // a bearer token
+	TokenFile string `yaml:"token_file"` // a file holding a bearer token
+	Username  string `yaml:"username"`   // a basic authentication user
+	Password  string `yaml:"password"`   // the basic authentication user's password
+	CertFile  string `yaml:"cert_file"`  // a client certificate
+	KeyFile   string `yaml:"key_file"`   // the client certificate's key
+	CAFile    string `yaml:"ca_file"`    // the CA that signed the cluster's certificate
+	Insecure  bool   `yaml:"insecure"`   // skips verifying the cluster's certificate
+	// The PEM data of the client certificate, its key and the CA, as
+	// embedded in kubeconfigs
+	CertData []byte `yaml:"-"`
+	KeyData  []byte `yaml:"-"`
+	CAData   []byte `yaml:"-"`
+}
+
+// LoadTargets reads the cluster targets defined in a YAML file under a
+// targets key, by name
+func LoadTargets(path string) (map[string]Target, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Targets map[string]Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Invalid targets file %s: %s", path, err)
+	}
+	for name, t := range file.Targets {
+		if t.Host == "" {
+			return nil, fmt.Errorf("Target %s in %s is missing a host", name, path)
+		}
+	}
+	return file.Targets, nil
+}
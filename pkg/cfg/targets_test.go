@@ -0,0 +1,58 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTargets(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestLoadTargets(t *testing.T) {
+	path := writeTargets(t, `
+targets:
+  staging:
+    host: https://staging.example.com
+    token_file: /etc/broadway/staging-token
+  production:
+    host: https://production.example.com
+    namespace: apps
+    cert_file: /etc/broadway/cert.pem
+    key_file: /etc/broadway/key.pem
+    ca_file: /etc/broadway/ca.pem
+`)
+	defer os.Remove(path)
+
+	targets, err := LoadTargets(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Target{
+		"staging": {Host: "https://staging.example.com", TokenFile: "/etc/broadway/staging-token"},
+		"production": {
+			Host:      "https://production.example.com",
+			Namespace: "apps",
+			CertFile:  "/etc/broadway/cert.pem",
+			KeyFile:   "/etc/broadway/key.pem",
+			CAFile:    "/etc/broadway/ca.pem",
+		},
+	}, targets)
+
+	missingHost := writeTargets(t, "targets:\n  staging:\n    namespace: apps\n")
+	defer os.Remove(missingHost)
+	_, err = LoadTargets(missingHost)
+	assert.EqualError(t, err, "Target staging in "+missingHost+" is missing a host")
+
+	_, err = LoadTargets("/nonexistent/targets.yml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,61 @@
+package deployment
+
+import (
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/v1"
+	coreclient "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/runtime/serializer"
+)
+
+// Cluster is a Kubernetes cluster deployments run against: a client, the
+// namespace objects are deployed to and the codec decoding manifests. Create
+// one per cluster and share it, it's safe for concurrent use.
+type Cluster struct {
+	Client       coreclient.CoreInterface
+	Namespace    string
+	scheme       *runtime.Scheme
+	deserializer runtime.Decoder
+}
+
+// NewCluster creates a Cluster deploying to namespace with a client configured
+// by config
+func NewCluster(config *restclient.Config, namespace string) (*Cluster, error) {
+	client, err := coreclient.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClusterWithClient(client, namespace), nil
+}
+
+// NewClusterWithClient creates a Cluster deploying to namespace with client
+func NewClusterWithClient(client coreclient.CoreInterface, namespace string) *Cluster {
+	scheme := runtime.NewScheme()
+	v1.AddToScheme(scheme)
+	factory := serializer.NewCodecFactory(scheme)
+	return &Cluster{
+		Client:       client,
+		Namespace:    namespace,
+		scheme:       scheme,
+		deserializer: factory.UniversalDeserializer(),
+	}
+}
+
+// Ping checks the cluster's API can be reached. Errors returned by the API,
+// such as the namespace missing or being forbidden, mean it was reached.
+func (c *Cluster) Ping() error {
+	_, err := c.Client.Namespaces().Get(c.Namespace)
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return nil
+	}
+	return err
+}
+
+func (c *Cluster) deserialize(manifest string) (runtime.Object, error) {
+	object, _, err := c.deserializer.Decode([]byte(manifest), &groupVersionKind, nil)
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,36 @@
+package deployment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1/fake"
+	"k8s.io/kubernetes/pkg/client/testing/core"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func TestClusterPing(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Err      error
+		Expected error
+	}{
+		{"API answers", nil, nil},
+		{"API refuses", apierrors.NewForbidden(unversioned.GroupResource{Resource: "namespaces"}, "test", errors.New("no")), nil},
+		{"API unreachable", errors.New("connection refused"), errors.New("connection refused")},
+	}
+
+	for _, c := range cases {
+		cluster := newTestCluster()
+		f := cluster.Client.(*fake.FakeCore).Fake
+		err := c.Err
+		f.AddReactor("get", "namespaces", func(core.Action) (bool, runtime.Object, error) {
+			return true, nil, err
+		})
+		assert.Equal(t, c.Expected, cluster.Ping(), c.Name)
+	}
+}
@@ -1,8 +1,10 @@
 package deployment
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/golang/glog"
 	"k8s.io/kubernetes/pkg/client/restclient"
@@ -41,18 +43,31 @@ func IsKubernetesEnv(cfg cfg.Type) bool {
 	return true
 }
 
-// Config returns a kubernetes configuration
-func Config(cfg cfg.Type) (*restclient.Config, error) {
-	config := LocalConfig(cfg)
-	if IsKubernetesEnv(cfg) {
-		var err error
-		config, err = KubernetesConfig(cfg)
+// ErrNoCluster indicates none of the Kubernetes settings configure a cluster
+var ErrNoCluster = errors.New("No Kubernetes cluster is configured: pass --kubeconfig or set $KUBECONFIG, run broadway in a Kubernetes pod, or pass --k8s-host")
+
+// Config returns the configuration of the cluster set up by the Kubernetes
+// settings: a kubeconfig, the service account of the pod broadway runs in, or
+// a host with its certificates, in that order
+func Config(c cfg.Type) (*restclient.Config, error) {
+	kubeconfig := c.Kubeconfig
+	if kubeconfig == "" && c.KubeContext != "" {
+		kubeconfig = cfg.DefaultKubeconfig()
+	}
+	if kubeconfig != "" {
+		t, err := cfg.LoadKubeconfig(kubeconfig, c.KubeContext)
 		if err != nil {
 			return nil, err
 		}
+		return TargetConfig(t)
 	}
-
-	return config, nil
+	if IsKubernetesEnv(c) {
+		return KubernetesConfig(c)
+	}
+	if c.K8sServiceHost != "" {
+		return LocalConfig(c), nil
+	}
+	return nil, ErrNoCluster
 }
 
 // KubernetesConfig returns Kubernetes configuration for native Kubernetes environment
@@ -70,23 +85,42 @@ func KubernetesConfig(cfg cfg.Type) (*restclient.Config, error) {
 	}, nil
 }
 
-// LocalConfig returns a configuration for local development
-func LocalConfig(cfg cfg.Type) *restclient.Config {
-	if cfg.K8sServiceHost != "" && cfg.K8sCertFile != "" &&
-		cfg.K8sKeyFile != "" && cfg.K8sCAFile != "" {
-
-		return &restclient.Config{
-			Host: cfg.K8sServiceHost,
-			TLSClientConfig: restclient.TLSClientConfig{
-				CertFile: cfg.K8sCertFile,
-				KeyFile:  cfg.K8sKeyFile,
-				CAFile:   cfg.K8sCAFile,
-			},
+// TargetConfig returns the configuration of a cluster target
+func TargetConfig(t cfg.Target) (*restclient.Config, error) {
+	config := &restclient.Config{
+		Host:        t.Host,
+		BearerToken: t.Token,
+		Username:    t.Username,
+		Password:    t.Password,
+		Insecure:    t.Insecure,
+		TLSClientConfig: restclient.TLSClientConfig{
+			CertFile: t.CertFile,
+			KeyFile:  t.KeyFile,
+			CAFile:   t.CAFile,
+			CertData: t.CertData,
+			KeyData:  t.KeyData,
+			CAData:   t.CAData,
+		},
+	}
+	if t.TokenFile != "" {
+		token, err := ioutil.ReadFile(t.TokenFile)
+		if err != nil {
+			return nil, err
 		}
+		config.BearerToken = strings.TrimSpace(string(token))
 	}
+	return config, nil
+}
 
+// LocalConfig returns a configuration for local development, connecting to
+// the Kubernetes host with the certificates that are set
+func LocalConfig(cfg cfg.Type) *restclient.Config {
 	return &restclient.Config{
-		Host:     "http://localhost:8080",
-		Insecure: true,
+		Host: cfg.K8sServiceHost,
+		TLSClientConfig: restclient.TLSClientConfig{
+			CertFile: cfg.K8sCertFile,
+			KeyFile:  cfg.K8sKeyFile,
+			CAFile:   cfg.K8sCAFile,
+		},
 	}
 }
@@ -0,0 +1,49 @@
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+current-context: ci
+clusters:
+- name: remote
+  cluster:
+    server: https://k8s.example.com
+contexts:
+- name: ci
+  context:
+    cluster: remote
+    user: basic
+users:
+- name: basic
+  user:
+    username: bill
+    password: hunter2
+`)
+	f.Close()
+
+	config, err := Config(cfg.Type{Kubeconfig: f.Name(), K8sServiceHost: "http://localhost:8080"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://k8s.example.com", config.Host, "kubeconfigs take precedence")
+	assert.Equal(t, "bill", config.Username)
+	assert.Equal(t, "hunter2", config.Password)
+
+	config, err = Config(cfg.Type{K8sServiceHost: "http://localhost:8080"})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:8080", config.Host)
+
+	_, err = Config(cfg.Type{})
+	assert.Equal(t, ErrNoCluster, err, "there's no fallback to localhost")
+}
@@ -1,7 +1,64 @@
 package deployment
 
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
 // Deployer declares something that can Deploy Deployments
 type Deployer interface {
 	Deploy() error
 	Destroy() error
 }
+
+// StepResult reports the outcome of a single step run by a Deployer
+type StepResult struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Err        error
+}
+
+// StepObserver is called by a Deployer after each step it runs
+type StepObserver func(StepResult)
+
+// Spec describes the deployment of an instance a Deployer is created for
+type Spec struct {
+	// Target names the cluster target to deploy to, empty for the cluster
+	// configured by the Kubernetes settings
+	Target    string
+	Playbook  *Playbook
+	Variables map[string]string
+	Manifests map[string]*Manifest
+	Observer  StepObserver
+	Context   context.Context
+}
+
+// Factory creates the Deployer of a Spec
+type Factory func(Spec) (Deployer, error)
+
+// KubernetesFactory returns a Factory creating KubernetesDeployments to the
+// clusters returned by clusters for each target
+func KubernetesFactory(clusters func(target string) (*Cluster, error)) Factory {
+	return func(s Spec) (Deployer, error) {
+		cluster, err := clusters(s.Target)
+		if err != nil {
+			return nil, err
+		}
+		d := NewKubernetesDeployment(cluster, s.Playbook, s.Variables, s.Manifests)
+		d.Observer = s.Observer
+		d.Context = s.Context
+		return d, nil
+	}
+}
+
+// defaultVariables sets the playbook variables missing from variables to the
+// empty string
+func defaultVariables(playbook *Playbook, variables map[string]string) {
+	for _, v := range playbook.Vars {
+		if _, ok := variables[v]; !ok {
+			variables[v] = ""
+		}
+	}
+}
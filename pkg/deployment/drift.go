@@ -0,0 +1,23 @@
+package deployment
+
+// Ways an object deployed from a manifest can drift from it
+const (
+	// DriftMissing means the object is gone from the cluster
+	DriftMissing = "missing"
+	// DriftChanged means the live object no longer matches its manifest
+	DriftChanged = "changed"
+)
+
+// Drift describes an object deployed from a manifest that drifted from it
+type Drift struct {
+	Manifest string
+	Kind     string
+	Name     string
+	State    string
+}
+
+// DriftDetector declares a Deployer that can compare what it deploys with
+// what's live
+type DriftDetector interface {
+	Drift() ([]Drift, error)
+}
@@ -0,0 +1,163 @@
+package deployment
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Actions recorded by a Fake
+const (
+	FakeDeploy  = "deploy"
+	FakeDestroy = "destroy"
+)
+
+// FakeObject is an object a FakeDeployment rendered from a manifest instead
+// of deploying or destroying it
+type FakeObject struct {
+	Action     string
+	Target     string
+	PlaybookID string
+	InstanceID string
+	Manifest   string
+	Rendered   string
+}
+
+// Fake creates FakeDeployments, which only render their manifests. It records
+// the objects they render and fails the steps it's told to, so broadway can
+// run without a cluster.
+type Fake struct {
+	mu       sync.Mutex
+	failures map[string]error
+	objects  []FakeObject
+}
+
+// NewFake creates a Fake whose steps all succeed
+func NewFake() *Fake {
+	return &Fake{failures: map[string]error{}}
+}
+
+// Fail makes the steps of the named manifest fail with err, or succeed again
+// if err is nil
+func (f *Fake) Fail(manifest string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, manifest)
+		return
+	}
+	f.failures[manifest] = err
+}
+
+// Objects returns the objects rendered so far, oldest first
+func (f *Fake) Objects() []FakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeObject{}, f.objects...)
+}
+
+// Factory is a Factory creating FakeDeployments recorded by f
+func (f *Fake) Factory(s Spec) (Deployer, error) {
+	defaultVariables(s.Playbook, s.Variables)
+	return &FakeDeployment{Spec: s, fake: f}, nil
+}
+
+// step renders the named manifest for action, unless it's told to fail
+func (f *Fake) step(action string, s Spec, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err, ok := f.failures[name]; ok {
+		return err
+	}
+	m, ok := s.Manifests[name]
+	if !ok {
+		return fmt.Errorf("manifest %s is missing", name)
+	}
+	o := FakeObject{
+		Action:     action,
+		Target:     s.Target,
+		PlaybookID: s.Variables["playbook_id"],
+		InstanceID: s.Variables["instance_id"],
+		Manifest:   name,
+		Rendered:   m.Execute(s.Variables),
+	}
+	glog.Infof("Fake %s of %s for %s/%s:\n%s", action, name, o.PlaybookID, o.InstanceID, o.Rendered)
+	f.objects = append(f.objects, o)
+	return nil
+}
+
+// FakeDeployment is a Deployer created by a Fake
+type FakeDeployment struct {
+	Spec
+	fake *Fake
+}
+
+// Deploy renders the manifests of the playbook
+func (d *FakeDeployment) Deploy() error {
+	return d.run(FakeDeploy)
+}
+
+// Destroy renders the manifests of the playbook
+func (d *FakeDeployment) Destroy() error {
+	return d.run(FakeDestroy)
+}
+
+// Drift returns no drift, since nothing changes what a Fake deploys
+func (d *FakeDeployment) Drift() ([]Drift, error) {
+	return []Drift{}, nil
+}
+
+// Logs returns a made up line of logs for each manifest
+func (d *FakeDeployment) Logs(opts LogOptions) ([]PodLog, error) {
+	logs := []PodLog{}
+	for _, name := range d.Playbook.Manifests {
+		container := opts.Container
+		if container == "" {
+			container = name
+		}
+		line := fmt.Sprintf("Fake logs of %s for %s/%s\n", name, d.Variables["playbook_id"], d.Variables["instance_id"])
+		logs = append(logs, PodLog{Pod: name, Container: container, Stream: ioutil.NopCloser(strings.NewReader(line))})
+	}
+	return logs, nil
+}
+
+// Status reports the manifests whose last recorded action is a deploy as
+// running, and the others as missing
+func (d *FakeDeployment) Status() ([]ObjectStatus, error) {
+	deployed := map[string]bool{}
+	for _, o := range d.fake.Objects() {
+		if o.Target == d.Target && o.PlaybookID == d.Variables["playbook_id"] && o.InstanceID == d.Variables["instance_id"] {
+			deployed[o.Manifest] = o.Action == FakeDeploy
+		}
+	}
+	statuses := []ObjectStatus{}
+	for _, name := range d.Playbook.Manifests {
+		status := ObjectStatus{Manifest: name, Kind: "Fake", Name: name, Missing: !deployed[name]}
+		if deployed[name] {
+			status.Pods = []PodStatus{{Name: name, Phase: "Running", Ready: true}}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (d *FakeDeployment) run(action string) error {
+	for _, name := range d.Playbook.Manifests {
+		if d.Context != nil && d.Context.Err() != nil {
+			return d.Context.Err()
+		}
+		started := time.Now()
+		err := d.fake.step(action, d.Spec, name)
+		if d.Observer != nil {
+			d.Observer(StepResult{Name: name, StartedAt: started, FinishedAt: time.Now(), Err: err})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
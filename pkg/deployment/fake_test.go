@@ -0,0 +1,76 @@
+package deployment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFake(t *testing.T) {
+	playbook := &Playbook{ID: "web", Vars: []string{"version"}, Manifests: []string{"web-rc", "web-service"}}
+	manifests := map[string]*Manifest{}
+	for name, text := range map[string]string{
+		"web-rc":      "kind: ReplicationController\nimage: web:{{.version}}\n",
+		"web-service": "kind: Service\nname: {{.instance_id}}\n",
+	} {
+		m, err := NewManifest(name, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifests[name] = m
+	}
+	vars := func() map[string]string {
+		return map[string]string{"playbook_id": "web", "instance_id": "pr-1", "version": "42"}
+	}
+
+	f := NewFake()
+	steps := []StepResult{}
+	d, err := f.Factory(Spec{Target: "staging", Playbook: playbook, Variables: vars(), Manifests: manifests, Observer: func(r StepResult) {
+		steps = append(steps, r)
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, d.Deploy())
+	assert.Equal(t, []FakeObject{
+		{Action: FakeDeploy, Target: "staging", PlaybookID: "web", InstanceID: "pr-1", Manifest: "web-rc", Rendered: "kind: ReplicationController\nimage: web:42\n"},
+		{Action: FakeDeploy, Target: "staging", PlaybookID: "web", InstanceID: "pr-1", Manifest: "web-service", Rendered: "kind: Service\nname: pr-1\n"},
+	}, f.Objects())
+	assert.Len(t, steps, 2)
+
+	f.Fail("web-service", errors.New("boom"))
+	d, _ = f.Factory(Spec{Playbook: playbook, Variables: vars(), Manifests: manifests})
+	assert.EqualError(t, d.Destroy(), "boom")
+	assert.Len(t, f.Objects(), 3, "steps before the failing one still run")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d, _ = f.Factory(Spec{Playbook: playbook, Variables: vars(), Manifests: manifests, Context: ctx})
+	assert.Equal(t, context.Canceled, d.Deploy())
+	assert.Len(t, f.Objects(), 3)
+}
+
+func TestFakeStatus(t *testing.T) {
+	playbook := &Playbook{ID: "web", Manifests: []string{"web-rc"}}
+	m, err := NewManifest("web-rc", "kind: ReplicationController\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFake()
+	spec := Spec{Playbook: playbook, Variables: map[string]string{"playbook_id": "web", "instance_id": "pr-1"}, Manifests: map[string]*Manifest{"web-rc": m}}
+	d, _ := f.Factory(spec)
+	reader := d.(StatusReader)
+
+	statuses, err := reader.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, []ObjectStatus{{Manifest: "web-rc", Kind: "Fake", Name: "web-rc", Missing: true}}, statuses)
+
+	assert.Nil(t, d.Deploy())
+	statuses, _ = reader.Status()
+	assert.Equal(t, []PodStatus{{Name: "web-rc", Phase: "Running", Ready: true}}, statuses[0].Pods)
+	assert.False(t, statuses[0].Missing)
+
+	assert.Nil(t, d.Destroy())
+	statuses, _ = reader.Status()
+	assert.True(t, statuses[0].Missing)
+}
@@ -1,16 +1,15 @@
 package deployment
 
 import (
+	"time"
+
 	"github.com/golang/glog"
-	"github.com/namely/broadway/pkg/cfg"
+	"golang.org/x/net/context"
 
 	"k8s.io/kubernetes/pkg/api/meta"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/api/v1"
-	coreclient "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1"
-	"k8s.io/kubernetes/pkg/client/restclient"
 	"k8s.io/kubernetes/pkg/runtime"
-	"k8s.io/kubernetes/pkg/runtime/serializer"
 
 	// Install API
 	_ "k8s.io/kubernetes/pkg/api/install"
@@ -22,55 +21,35 @@ var groupVersionKind = unversioned.GroupVersionKind{
 	Kind:    meta.AnyKind,
 }
 
-var client coreclient.CoreInterface
-var deserializer runtime.Decoder
-var namespace string
-var scheme *runtime.Scheme
-
 // Step represents a deployment step
 type Step interface {
 	Deploy() error
 	Destroy() error
 }
 
-// SetupKubernetes configures kubernetes with an injected configuration
-func SetupKubernetes(cfg cfg.Type) {
-	scheme = runtime.NewScheme()
-	v1.AddToScheme(scheme)
-	factory := serializer.NewCodecFactory(scheme)
-	deserializer = factory.UniversalDeserializer()
-
-	namespace = cfg.K8sNamespace
-}
-
 // KubernetesDeployment represents a deployment of an instance
 type KubernetesDeployment struct {
+	Cluster   *Cluster
 	Playbook  *Playbook
 	Variables map[string]string
 	Manifests map[string]*Manifest
+	Observer  StepObserver
+	// Context cancels the deployment: steps that haven't started are skipped
+	// and the step running stops waiting on Kubernetes
+	Context context.Context
 }
 
-// NewKubernetesDeployment creates a new kuberentes deployment
-func NewKubernetesDeployment(config *restclient.Config, playbook *Playbook, variables map[string]string, manifests map[string]*Manifest) (*KubernetesDeployment, error) {
-	var err error
-	client, err = coreclient.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-
+// NewKubernetesDeployment creates a new kuberentes deployment to cluster
+func NewKubernetesDeployment(cluster *Cluster, playbook *Playbook, variables map[string]string, manifests map[string]*Manifest) *KubernetesDeployment {
 	// Default all missing playbook variables to empty string
-	for _, v := range playbook.Vars {
-		_, ok := variables[v]
-		if !ok {
-			variables[v] = ""
-		}
-	}
+	defaultVariables(playbook, variables)
 
 	return &KubernetesDeployment{
+		Cluster:   cluster,
 		Playbook:  playbook,
 		Variables: variables,
 		Manifests: manifests,
-	}, nil
+	}
 }
 
 // Deploy executes the deployment
@@ -81,7 +60,11 @@ func (d *KubernetesDeployment) Deploy() error {
 	}
 
 	for i, step := range steps {
-		err := step.Deploy()
+		if err := d.context().Err(); err != nil {
+			glog.Warningf("Skipping the remaining %d steps: %s", len(steps)-i, err)
+			return err
+		}
+		err := d.observe(i, step.Deploy)
 		if err != nil {
 			glog.Warning("%d. step failed: %s", i, err.Error())
 			return err
@@ -99,8 +82,12 @@ func (d *KubernetesDeployment) Destroy() error {
 	}
 
 	for i, step := range steps {
+		if err := d.context().Err(); err != nil {
+			glog.Warningf("Skipping the remaining %d steps: %s", len(steps)-i, err)
+			return err
+		}
 		glog.Infof("%d. Destroying Resources.", i)
-		err := step.Destroy()
+		err := d.observe(i, step.Destroy)
 		if err != nil {
 			glog.Warning("%d. step failed: %s", i, err.Error())
 			return err
@@ -109,25 +96,74 @@ func (d *KubernetesDeployment) Destroy() error {
 	return nil
 }
 
+// Drift compares the objects rendered from the manifests with the live ones,
+// returning the objects that went missing or changed
+func (d *KubernetesDeployment) Drift() ([]Drift, error) {
+	steps, err := d.steps()
+	if err != nil {
+		return nil, err
+	}
+
+	drift := []Drift{}
+	for i, step := range steps {
+		s, ok := step.(*ManifestStep)
+		if !ok {
+			continue
+		}
+		state, err := s.drift()
+		if err != nil {
+			return nil, err
+		}
+		if state == "" {
+			continue
+		}
+		m, err := meta.Accessor(s.object)
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, Drift{
+			Manifest: d.Playbook.Manifests[i],
+			Kind:     s.object.GetObjectKind().GroupVersionKind().Kind,
+			Name:     m.GetName(),
+			State:    state,
+		})
+	}
+	return drift, nil
+}
+
+// observe runs the i-th step's action and reports its outcome to the Observer
+func (d *KubernetesDeployment) observe(i int, action func() error) error {
+	started := time.Now()
+	err := action()
+	if d.Observer != nil {
+		d.Observer(StepResult{
+			Name:       d.Playbook.Manifests[i],
+			StartedAt:  started,
+			FinishedAt: time.Now(),
+			Err:        err,
+		})
+	}
+	return err
+}
+
 func (d *KubernetesDeployment) steps() ([]Step, error) {
 	var steps = []Step{}
 	for _, name := range d.Playbook.Manifests {
 		m := d.Manifests[name]
 		rendered := m.Execute(d.Variables)
-		object, err := deserialize(rendered)
+		object, err := d.Cluster.deserialize(rendered)
 		if err != nil {
 			glog.Warningf("Failed to parse manifest %s", name)
 			return steps, err
 		}
-		steps = append(steps, NewManifestStep(object))
+		steps = append(steps, &ManifestStep{object: object, cluster: d.Cluster, ctx: d.context()})
 	}
 	return steps, nil
 }
 
-func deserialize(manifest string) (runtime.Object, error) {
-	object, _, err := deserializer.Decode([]byte(manifest), &groupVersionKind, nil)
-	if err != nil {
-		return nil, err
+func (d *KubernetesDeployment) context() context.Context {
+	if d.Context == nil {
+		return context.Background()
 	}
-	return object, nil
+	return d.Context
 }
@@ -1,16 +1,22 @@
 package deployment
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/namely/broadway/pkg/testutils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1/fake"
 	"k8s.io/kubernetes/pkg/client/testing/core"
+	"k8s.io/kubernetes/pkg/runtime"
 )
 
+var testCluster = NewClusterWithClient(&fake.FakeCore{&core.Fake{}}, testutils.TestCfg.K8sNamespace)
+
 func init() {
-	client = &fake.FakeCore{&core.Fake{}}
 	Setup(testutils.TestCfg)
 }
 
@@ -49,7 +55,7 @@ func TestDeploy(t *testing.T) {
 
 	for _, c := range cases {
 		// Reset client
-		client.(*fake.FakeCore).Fake.ClearActions()
+		testCluster.Client.(*fake.FakeCore).Fake.ClearActions()
 
 		p := &Playbook{
 			ID:        "test",
@@ -60,6 +66,7 @@ func TestDeploy(t *testing.T) {
 		}
 
 		d := &KubernetesDeployment{
+			Cluster:   testCluster,
 			Playbook:  p,
 			Variables: vars,
 			Manifests: manifests,
@@ -70,6 +77,40 @@ func TestDeploy(t *testing.T) {
 	}
 }
 
+func TestDeployToSeparateClusters(t *testing.T) {
+	m, _ := NewManifest("test", mtemplate)
+	clusters := []*Cluster{
+		NewClusterWithClient(&fake.FakeCore{&core.Fake{}}, "staging"),
+		NewClusterWithClient(&fake.FakeCore{&core.Fake{}}, "production"),
+	}
+	for _, c := range clusters {
+		// Empty clusters, where the RC is new and only gets created:
+		c.Client.(*fake.FakeCore).Fake.AddReactor("get", "replicationcontrollers", func(a core.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewNotFound(api.Resource("replicationcontrollers"), "test")
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range clusters {
+		wg.Add(1)
+		go func(c *Cluster) {
+			defer wg.Done()
+			d := NewKubernetesDeployment(c, &Playbook{ID: "test", Manifests: []string{"test"}}, map[string]string{}, map[string]*Manifest{"test": m})
+			assert.Nil(t, d.Deploy())
+		}(c)
+	}
+	wg.Wait()
+
+	for _, c := range clusters {
+		verbs := []string{}
+		for _, a := range c.Client.(*fake.FakeCore).Fake.Actions() {
+			verbs = append(verbs, a.GetVerb())
+			assert.Equal(t, c.Namespace, a.GetNamespace(), "each deployment only uses its own cluster")
+		}
+		assert.Equal(t, []string{"get", "create"}, verbs, c.Namespace)
+	}
+}
+
 func TestDestroy(t *testing.T) {
 	cases := []struct {
 		Name      string
@@ -104,7 +145,7 @@ func TestDestroy(t *testing.T) {
 
 	for _, c := range cases {
 		// Reset client
-		client.(*fake.FakeCore).Fake.ClearActions()
+		testCluster.Client.(*fake.FakeCore).Fake.ClearActions()
 
 		p := &Playbook{
 			ID:        "test",
@@ -115,6 +156,7 @@ func TestDestroy(t *testing.T) {
 		}
 
 		d := &KubernetesDeployment{
+			Cluster:   testCluster,
 			Playbook:  p,
 			Variables: vars,
 			Manifests: manifests,
@@ -122,7 +164,7 @@ func TestDestroy(t *testing.T) {
 
 		err := d.Destroy()
 		assert.Nil(t, err, c.Name+" deployment should not return with error")
-		f := client.(*fake.FakeCore).Fake
+		f := testCluster.Client.(*fake.FakeCore).Fake
 		assert.Equal(t, c.Expected, len(f.Actions()), c.Name+" should trigger actions.")
 	}
 }
@@ -144,3 +186,25 @@ spec:
       - name: redis
         image: kubernetes/redis:v1
 `
+
+func TestDeployCancelled(t *testing.T) {
+	m, _ := NewManifest("test", mtemplate)
+	testCluster.Client.(*fake.FakeCore).Fake.ClearActions()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	observed := []StepResult{}
+	d := &KubernetesDeployment{
+		Cluster:   testCluster,
+		Playbook:  &Playbook{ID: "test", Manifests: []string{"test", "test"}},
+		Variables: map[string]string{"test": "ok"},
+		Manifests: map[string]*Manifest{"test": m},
+		Observer:  func(r StepResult) { observed = append(observed, r) },
+		Context:   ctx,
+	}
+
+	assert.Equal(t, context.Canceled, d.Deploy())
+	assert.Empty(t, observed, "steps that haven't started are skipped")
+	assert.Empty(t, testCluster.Client.(*fake.FakeCore).Fake.Actions(), "Kubernetes is left alone")
+	assert.Equal(t, context.Canceled, d.Destroy())
+}
@@ -0,0 +1,56 @@
+package deployment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/namely/broadway/pkg/instance"
+)
+
+// Lifecycle actions taken on instances that outlive their playbook's policy
+const (
+	// LifecycleStop deletes an instance's workloads but keeps its record
+	LifecycleStop = "stop"
+	// LifecycleDelete deletes an instance's workloads and its record
+	LifecycleDelete = "delete"
+)
+
+// Lifetime is a duration written as in "3d", "12h" or "90m"
+type Lifetime time.Duration
+
+// UnmarshalYAML parses a lifetime with instance.ParseLifetime
+func (l *Lifetime) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	d, err := instance.ParseLifetime(s)
+	if err != nil {
+		return err
+	}
+	*l = Lifetime(d)
+	return nil
+}
+
+// Lifecycle is a playbook's policy for cleaning up its instances. Zero
+// lifetimes don't apply.
+type Lifecycle struct {
+	TTL       Lifetime `yaml:"ttl"`        // how long instances live after they are created
+	DeployTTL Lifetime `yaml:"deploy_ttl"` // how long instances live after their last deploy
+	IdleTTL   Lifetime `yaml:"idle_ttl"`   // how long new or errored instances are kept around
+	Action    string   `yaml:"action"`     // stop (the default) or delete
+}
+
+// Validate checks the lifecycle's action
+func (l Lifecycle) Validate() error {
+	switch l.Action {
+	case "", LifecycleStop, LifecycleDelete:
+		return nil
+	}
+	return fmt.Errorf("Playbook lifecycle action must be %s or %s, not %s", LifecycleStop, LifecycleDelete, l.Action)
+}
+
+// Deletes returns true if instances are deleted rather than only stopped
+func (l Lifecycle) Deletes() bool {
+	return l.Action == LifecycleDelete
+}
@@ -0,0 +1,35 @@
+package deployment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlaybookLifecycle(t *testing.T) {
+	p, err := ParsePlaybook([]byte(`---
+id: lifecycle-playbook
+name: Lifecycle
+lifecycle:
+  ttl: 5d
+  deploy_ttl: 2d
+  idle_ttl: 12h
+  action: delete
+manifests:
+  - hello
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, Lifetime(5*24*time.Hour), p.Lifecycle.TTL)
+	assert.Equal(t, Lifetime(2*24*time.Hour), p.Lifecycle.DeployTTL)
+	assert.Equal(t, Lifetime(12*time.Hour), p.Lifecycle.IdleTTL)
+	assert.True(t, p.Lifecycle.Deletes())
+	assert.Nil(t, p.Lifecycle.Validate())
+
+	_, err = ParsePlaybook([]byte("lifecycle:\n  ttl: soon\n"))
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, Lifecycle{Action: "archive"}.Validate())
+	assert.Nil(t, Lifecycle{}.Validate())
+	assert.False(t, Lifecycle{}.Deletes(), "instances are only stopped by default")
+}
@@ -0,0 +1,123 @@
+package deployment
+
+import (
+	"fmt"
+	"io"
+
+	"k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+// LogOptions selects the logs read by a LogReader
+type LogOptions struct {
+	// Container names the container to read, instead of each pod's first one
+	Container string
+	// TailLines limits the logs to their last lines, 0 reads them all
+	TailLines int64
+	// Follow keeps streaming the logs as they're written
+	Follow bool
+}
+
+// PodLog is the log of a container of a pod
+type PodLog struct {
+	Pod       string
+	Container string
+	Stream    io.ReadCloser
+}
+
+// LogReader declares a Deployer that can read the logs of the pods it deployed
+type LogReader interface {
+	Logs(opts LogOptions) ([]PodLog, error)
+}
+
+// Logs opens the logs of the pods deployed from the manifests, directly or
+// through their replication controllers. Pods without the requested
+// container are skipped.
+func (d *KubernetesDeployment) Logs(opts LogOptions) ([]PodLog, error) {
+	steps, err := d.steps()
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []PodLog{}
+	for _, step := range steps {
+		s, ok := step.(*ManifestStep)
+		if !ok {
+			continue
+		}
+		pods, err := s.pods()
+		if err != nil {
+			closeLogs(logs)
+			return nil, err
+		}
+		for _, pod := range pods {
+			container := logContainer(pod, opts.Container)
+			if container == "" {
+				continue
+			}
+			podOpts := &v1.PodLogOptions{Container: container, Follow: opts.Follow}
+			if opts.TailLines > 0 {
+				podOpts.TailLines = &opts.TailLines
+			}
+			stream, err := d.Cluster.Client.Pods(d.Cluster.Namespace).GetLogs(pod.ObjectMeta.Name, podOpts).Stream()
+			if err != nil {
+				closeLogs(logs)
+				return nil, err
+			}
+			logs = append(logs, PodLog{Pod: pod.ObjectMeta.Name, Container: container, Stream: stream})
+		}
+	}
+	return logs, nil
+}
+
+// logContainer returns the container of pod to read the logs of, or an empty
+// string if pod has no container named container
+func logContainer(pod v1.Pod, container string) string {
+	for _, c := range pod.Spec.Containers {
+		if container == "" || c.Name == container {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+func closeLogs(logs []PodLog) {
+	for _, l := range logs {
+		l.Stream.Close()
+	}
+}
+
+// pods returns the live pods the step deployed: the pod itself, or the pods
+// matching the selector of a replication controller
+func (s *ManifestStep) pods() ([]v1.Pod, error) {
+	client := s.cluster.Client.Pods(s.cluster.Namespace)
+	switch o := s.object.(type) {
+	case *v1.ReplicationController:
+		selector := o.Spec.Selector
+		if len(selector) == 0 && o.Spec.Template != nil {
+			// Kubernetes defaults the selector to the template's labels:
+			selector = o.Spec.Template.ObjectMeta.Labels
+		}
+		if len(selector) == 0 {
+			// An empty selector would match every pod in the namespace
+			return nil, fmt.Errorf("replication controller %s selects no pods", o.ObjectMeta.Name)
+		}
+		list, err := client.List(api.ListOptions{LabelSelector: labels.SelectorFromSet(selector)})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	case *v1.Pod:
+		pod, err := client.Get(o.ObjectMeta.Name)
+		if apierrors.IsNotFound(err) {
+			return []v1.Pod{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []v1.Pod{*pod}, nil
+	}
+	return []v1.Pod{}, nil
+}
@@ -0,0 +1,94 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1/fake"
+	"k8s.io/kubernetes/pkg/client/testing/core"
+)
+
+var podt1 = `apiVersion: v1
+kind: Pod
+metadata:
+  name: redis-1
+  labels:
+    name: redis
+spec:
+  containers:
+  - name: redis
+    image: kubernetes/redis:v1
+  - name: sidecar
+    image: busybox
+`
+
+func TestManifestStepPods(t *testing.T) {
+	cluster := newTestCluster()
+	f := cluster.Client.(*fake.FakeCore).Fake
+	o := core.NewObjects(api.Scheme, api.Codecs.UniversalDecoder())
+	if err := o.Add(mustDeserialize(podt1)); err != nil {
+		panic(err)
+	}
+	f.AddReactor("*", "*", core.ObjectReaction(o, api.RESTMapper))
+
+	pods, err := NewManifestStep(cluster, mustDeserialize(rct1)).(*ManifestStep).pods()
+	assert.Nil(t, err)
+	if assert.Len(t, pods, 1) {
+		assert.Equal(t, "redis-1", pods[0].ObjectMeta.Name)
+	}
+	if list, ok := f.Actions()[0].(core.ListAction); assert.True(t, ok, "RCs list their pods") {
+		assert.Equal(t, "name=redis", list.GetListRestrictions().Labels.String())
+	}
+
+	pods, err = NewManifestStep(cluster, mustDeserialize(podt1)).(*ManifestStep).pods()
+	assert.Nil(t, err)
+	assert.Len(t, pods, 1, "pods are their own pods")
+}
+
+var rcNoSelector = `apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: test2
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        name: redis
+    spec:
+      containers:
+      - name: redis
+        image: kubernetes/redis:v1
+`
+
+func TestManifestStepPodsWithoutSelector(t *testing.T) {
+	cluster := newTestCluster()
+	f := cluster.Client.(*fake.FakeCore).Fake
+	o := core.NewObjects(api.Scheme, api.Codecs.UniversalDecoder())
+	if err := o.Add(mustDeserialize(podt1)); err != nil {
+		panic(err)
+	}
+	f.AddReactor("*", "*", core.ObjectReaction(o, api.RESTMapper))
+
+	_, err := NewManifestStep(cluster, mustDeserialize(rcNoSelector)).(*ManifestStep).pods()
+	assert.Nil(t, err)
+	if list, ok := f.Actions()[0].(core.ListAction); assert.True(t, ok) {
+		assert.Equal(t, "name=redis", list.GetListRestrictions().Labels.String(), "the template's labels select the pods")
+	}
+
+	rc := mustDeserialize(rcNoSelector).(*v1.ReplicationController)
+	rc.Spec.Template.ObjectMeta.Labels = nil
+	_, err = NewManifestStep(cluster, rc).(*ManifestStep).pods()
+	assert.EqualError(t, err, "replication controller test2 selects no pods")
+	assert.Len(t, f.Actions(), 1, "nothing is listed without labels")
+}
+
+func TestLogContainer(t *testing.T) {
+	pod := *mustDeserialize(podt1).(*v1.Pod)
+	assert.Equal(t, "redis", logContainer(pod, ""), "the first container by default")
+	assert.Equal(t, "sidecar", logContainer(pod, "sidecar"))
+	assert.Equal(t, "", logContainer(pod, "web"))
+}
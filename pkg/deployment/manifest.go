@@ -2,6 +2,9 @@ package deployment
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"strings"
 	"text/template"
 	"time"
@@ -49,3 +52,16 @@ func (m *Manifest) Execute(vars map[string]string) string {
 	}
 	return b.String()
 }
+
+// RenderedHash returns a hex encoded SHA-256 of a playbook's manifests rendered
+// with vars, in playbook order
+func RenderedHash(playbook *Playbook, manifests map[string]*Manifest, vars map[string]string) string {
+	h := sha256.New()
+	for _, name := range playbook.Manifests {
+		io.WriteString(h, name+"\n")
+		if m, ok := manifests[name]; ok {
+			io.WriteString(h, m.Execute(vars))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/meta"
 	"k8s.io/kubernetes/pkg/api/v1"
 	"k8s.io/kubernetes/pkg/runtime"
@@ -13,15 +15,36 @@ import (
 
 // ManifestStep implements a deployment step
 type ManifestStep struct {
-	object runtime.Object
+	object  runtime.Object
+	cluster *Cluster
+	ctx     context.Context
 }
 
 var _ Step = &ManifestStep{}
 
-// NewManifestStep creates a default step
-func NewManifestStep(object runtime.Object) Step {
+// NewManifestStep creates a default step deploying object to cluster
+func NewManifestStep(cluster *Cluster, object runtime.Object) Step {
 	return &ManifestStep{
-		object: object,
+		object:  object,
+		cluster: cluster,
+	}
+}
+
+// context returns the context cancelling the step's waits on Kubernetes
+func (s *ManifestStep) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// wait sleeps for d unless ctx is cancelled first
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
 }
 
@@ -116,7 +139,7 @@ func (s *ManifestStep) Deploy() error {
 		return deployRC(s)
 	case "Pod":
 		o := s.object.(*v1.Pod)
-		pod, err := client.Pods(namespace).Get(o.ObjectMeta.Name)
+		pod, err := s.cluster.Client.Pods(s.cluster.Namespace).Get(o.ObjectMeta.Name)
 
 		if err == nil && pod != nil {
 			if comparePods(pod, o) {
@@ -124,11 +147,14 @@ func (s *ManifestStep) Deploy() error {
 				return nil
 			}
 			glog.Info("Deleting old pod", o.ObjectMeta.Name)
-			err = client.Pods(namespace).Delete(o.ObjectMeta.Name, nil)
+			err = s.cluster.Client.Pods(s.cluster.Namespace).Delete(o.ObjectMeta.Name, nil)
 
 			for k := 1; err == nil && k < 20; k++ {
-				time.Sleep(200 * time.Millisecond) // Wait for Kubernetes to delete the resource
-				_, err = client.Pods(namespace).Get(o.ObjectMeta.Name)
+				// Wait for Kubernetes to delete the resource
+				if err := wait(s.context(), 200*time.Millisecond); err != nil {
+					return err
+				}
+				_, err = s.cluster.Client.Pods(s.cluster.Namespace).Get(o.ObjectMeta.Name)
 			}
 			if err != nil {
 				glog.Error("delete old pods: ", err)
@@ -136,23 +162,23 @@ func (s *ManifestStep) Deploy() error {
 		}
 
 		glog.Info("Creating new pod: ", o.ObjectMeta.Name)
-		_, err = client.Pods(namespace).Create(o)
+		_, err = s.cluster.Client.Pods(s.cluster.Namespace).Create(o)
 		if err != nil {
 			glog.Info("Create or Update failed: ", err)
 			return err
 		}
 	case "Service":
 		o := s.object.(*v1.Service)
-		service, err := client.Services(namespace).Get(o.ObjectMeta.Name)
+		service, err := s.cluster.Client.Services(s.cluster.Namespace).Get(o.ObjectMeta.Name)
 
 		if err != nil {
 			glog.Info("Creating new service: ", o.ObjectMeta.Name)
-			_, err = client.Services(namespace).Create(o)
+			_, err = s.cluster.Client.Services(s.cluster.Namespace).Create(o)
 		} else {
 			glog.Info("Updating service", o.ObjectMeta.Name)
 			o.ObjectMeta.ResourceVersion = service.ObjectMeta.ResourceVersion
 			o.Spec.ClusterIP = service.Spec.ClusterIP
-			_, err = client.Services(namespace).Update(o)
+			_, err = s.cluster.Client.Services(s.cluster.Namespace).Update(o)
 		}
 		if err != nil {
 			glog.Info("Create or Update failed: ", err)
@@ -164,6 +190,33 @@ func (s *ManifestStep) Deploy() error {
 	return nil
 }
 
+// drift compares the live object with the step's, returning DriftMissing or
+// DriftChanged if they differ and an empty string if they match. Services are
+// only checked for existence, since Kubernetes fills in much of their spec.
+func (s *ManifestStep) drift() (string, error) {
+	var err error
+	switch o := s.object.(type) {
+	case *v1.ReplicationController:
+		var rc *v1.ReplicationController
+		if rc, err = s.cluster.Client.ReplicationControllers(s.cluster.Namespace).Get(o.ObjectMeta.Name); err == nil && !compareRCs(rc, o) {
+			return DriftChanged, nil
+		}
+	case *v1.Pod:
+		var pod *v1.Pod
+		if pod, err = s.cluster.Client.Pods(s.cluster.Namespace).Get(o.ObjectMeta.Name); err == nil && !comparePods(pod, o) {
+			return DriftChanged, nil
+		}
+	case *v1.Service:
+		_, err = s.cluster.Client.Services(s.cluster.Namespace).Get(o.ObjectMeta.Name)
+	default:
+		return "", errors.New("Kubernetes resource is not recognized: " + s.object.GetObjectKind().GroupVersionKind().Kind)
+	}
+	if apierrors.IsNotFound(err) {
+		return DriftMissing, nil
+	}
+	return "", err
+}
+
 // Destroy deletes kubernetes resource
 func (s *ManifestStep) Destroy() error {
 	var err error
@@ -174,11 +227,15 @@ func (s *ManifestStep) Destroy() error {
 	}
 	switch oGVK.Kind {
 	case "ReplicationController":
-		deleteRC(namespace, meta.GetName())
+		// Other errors, such as the RC being gone already, don't fail the
+		// destruction:
+		if err := deleteRC(s.context(), s.cluster, meta.GetName()); err != nil && s.context().Err() != nil {
+			return err
+		}
 	case "Service":
-		client.Services(namespace).Delete(meta.GetName(), nil)
+		s.cluster.Client.Services(s.cluster.Namespace).Delete(meta.GetName(), nil)
 	case "Pod":
-		client.Pods(namespace).Delete(meta.GetName(), nil)
+		s.cluster.Client.Pods(s.cluster.Namespace).Delete(meta.GetName(), nil)
 	}
 	return err
 }
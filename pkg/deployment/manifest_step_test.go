@@ -2,8 +2,10 @@ package deployment
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/v1"
@@ -12,12 +14,13 @@ import (
 	"k8s.io/kubernetes/pkg/runtime"
 )
 
-func init() {
-	namespace = "test"
+// newTestCluster creates a cluster backed by a fake client
+func newTestCluster() *Cluster {
+	return NewClusterWithClient(&fake.FakeCore{&core.Fake{}}, "test")
 }
 
 func mustDeserialize(manifest string) runtime.Object {
-	o, err := deserialize(manifest)
+	o, err := newTestCluster().deserialize(manifest)
 	if err != nil {
 		panic(err)
 	}
@@ -77,10 +80,9 @@ func TestManifestStepDeploy(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		// Reset client
-		client = &fake.FakeCore{&core.Fake{}}
-		f := client.(*fake.FakeCore).Fake
-		step := NewManifestStep(c.Object)
+		cluster := newTestCluster()
+		f := cluster.Client.(*fake.FakeCore).Fake
+		step := NewManifestStep(cluster, c.Object)
 		f.ReactionChain = nil
 		c.Before(f)
 		f.ClearActions()
@@ -108,6 +110,49 @@ func TestManifestStepDeploy(t *testing.T) {
 	}
 }
 
+func TestManifestStepDrift(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Live     string
+		Expected string
+	}{
+		{"RC identical", rct1, ""},
+		{"RC changed", rct2, DriftChanged},
+		// The fake's objects are found by kind whatever their name, so a
+		// missing RC is one that was never added:
+		{"RC missing", "", DriftMissing},
+	}
+
+	for _, c := range cases {
+		cluster := newTestCluster()
+		f := cluster.Client.(*fake.FakeCore).Fake
+		o := core.NewObjects(api.Scheme, api.Codecs.UniversalDecoder())
+		if c.Live != "" {
+			if err := o.Add(mustDeserialize(c.Live)); err != nil {
+				panic(err)
+			}
+		}
+		f.AddReactor("*", "*", core.ObjectReaction(o, api.RESTMapper))
+
+		state, err := NewManifestStep(cluster, mustDeserialize(rct1)).(*ManifestStep).drift()
+		assert.Nil(t, err, c.Name)
+		assert.Equal(t, c.Expected, state, c.Name)
+		for _, a := range f.Actions() {
+			assert.Equal(t, "get", a.GetVerb(), c.Name+" only looks at the cluster")
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	assert.Nil(t, wait(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	started := time.Now()
+	assert.Equal(t, context.Canceled, wait(ctx, time.Hour))
+	assert.True(t, time.Since(started) < time.Second, "cancelling stops the wait")
+}
+
 func TestManifestStepDestroy(t *testing.T) {
 	cases := []struct {
 		Name     string
@@ -124,12 +169,11 @@ func TestManifestStepDestroy(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		// Reset client
-		client = &fake.FakeCore{&core.Fake{}}
-		f := client.(*fake.FakeCore).Fake
-		step := NewManifestStep(c.Object)
+		cluster := newTestCluster()
+		f := cluster.Client.(*fake.FakeCore).Fake
+		step := NewManifestStep(cluster, c.Object)
 		c.Before()
-		client.(*fake.FakeCore).Fake.ClearActions()
+		f.ClearActions()
 		assert.Equal(t, 0, len(f.Actions()), c.Name+" action count did not reset")
 		err := step.Destroy()
 		assert.Nil(t, err, c.Name+" deploy returned with nil")
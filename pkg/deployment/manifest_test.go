@@ -34,3 +34,17 @@ func TestExecute(t *testing.T) {
 		assert.Equal(t, c.expected, out, c.scenario+" case does not match output")
 	}
 }
+
+func TestRenderedHash(t *testing.T) {
+	m, err := NewManifest("web", `image: web:{{ .version }}`)
+	assert.Nil(t, err)
+	pb := &Playbook{ID: "web", Manifests: []string{"web"}}
+	ms := map[string]*Manifest{"web": m}
+
+	h1 := RenderedHash(pb, ms, map[string]string{"version": "1"})
+	h2 := RenderedHash(pb, ms, map[string]string{"version": "1"})
+	h3 := RenderedHash(pb, ms, map[string]string{"version": "2"})
+	assert.Equal(t, h1, h2)
+	assert.NotEqual(t, h1, h3)
+	assert.Len(t, h1, 64)
+}
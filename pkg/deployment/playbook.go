@@ -1,6 +1,9 @@
 package deployment
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +32,12 @@ type Playbook struct {
 	Vars      []string          `yaml:"vars"`
 	Manifests []string          `yaml:"manifests"`
 	Messages  map[string]string `yaml:"messages"`
+	Lifecycle Lifecycle         `yaml:"lifecycle"`
+	Workers   int               `yaml:"workers"`
+	Target    string            `yaml:"target"`
+	// ReapplyDrift has drifted instances deployed again rather than only
+	// reported
+	ReapplyDrift bool `yaml:"reapply_drift"`
 }
 
 // AllPlaybooks is a map of playbook id's to playbooks
@@ -66,6 +75,12 @@ func (p *Playbook) Validate() error {
 			return fmt.Errorf("Playbook had an invalid message template: \"%s\"", value)
 		}
 	}
+	if err := p.Lifecycle.Validate(); err != nil {
+		return err
+	}
+	if p.Workers < 0 {
+		return errors.New("Playbook workers can't be negative")
+	}
 	return p.ValidateManifests()
 }
 
@@ -125,3 +140,14 @@ func LoadPlaybookFolder(dir string) (map[string]*Playbook, error) {
 	}
 	return playbooks, nil
 }
+
+// Hash returns a hex encoded SHA-256 of the playbook's content
+func (p *Playbook) Hash() string {
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		glog.Errorf("Failed to encode playbook %s for hashing: %s", p.ID, err)
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
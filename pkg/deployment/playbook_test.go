@@ -202,6 +202,16 @@ func TestValidatePlaybookFailures(t *testing.T) {
 			},
 			"Playbook missing required Name",
 		},
+		{
+			"Validate Playbook With Negative Workers",
+			&Playbook{
+				ID:        "playbook id 1",
+				Name:      "playbook 1",
+				Manifests: []string{"hello"},
+				Workers:   -1,
+			},
+			"Playbook workers can't be negative",
+		},
 	}
 
 	for _, testcase := range testcases {
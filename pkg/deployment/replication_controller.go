@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/v1"
@@ -18,25 +19,29 @@ func deployRC(s *ManifestStep) error {
 		o = s.object.(*v1.ReplicationController)
 	case *api.ReplicationController:
 		rr := s.object.(*api.ReplicationController)
-		if err := scheme.Convert(rr, o); err != nil {
+		if err := s.cluster.scheme.Convert(rr, o); err != nil {
 			glog.Error("API object conversion failed.")
 			return err
 		}
 	}
 
-	if rc, err := client.ReplicationControllers(namespace).Get(o.ObjectMeta.Name); err == nil && rc != nil {
+	rcs := s.cluster.Client.ReplicationControllers(s.cluster.Namespace)
+	if rc, err := rcs.Get(o.ObjectMeta.Name); err == nil && rc != nil {
 		if compareRCs(rc, o) {
 			glog.Info("Existing RC is identical, skipping deployment")
 			return nil
 		}
 
-		if err := deleteRC(namespace, o.ObjectMeta.Name); err != nil {
+		if err := deleteRC(s.context(), s.cluster, o.ObjectMeta.Name); err != nil {
+			if s.context().Err() != nil {
+				return err
+			}
 			glog.Error(err)
 		}
 	}
 
 	glog.Info("Creating new replication controller: ", o.ObjectMeta.Name)
-	if _, err := client.ReplicationControllers(namespace).Create(o); err != nil {
+	if _, err := rcs.Create(o); err != nil {
 		glog.Error("Create or Update failed: ", err)
 		return err
 	}
@@ -44,19 +49,24 @@ func deployRC(s *ManifestStep) error {
 	return nil
 }
 
-// deleteRC scales down an RC and then deletes it
-func deleteRC(namespace, metaName string) error {
+// deleteRC scales down an RC of cluster and then deletes it, giving up if ctx
+// is cancelled while waiting for the pods to go away
+func deleteRC(ctx context.Context, cluster *Cluster, metaName string) error {
+	rcs := cluster.Client.ReplicationControllers(cluster.Namespace)
 	// SCALE RC DOWN TO 0
-	rc, err := client.ReplicationControllers(namespace).Get(metaName)
+	rc, err := rcs.Get(metaName)
 	if err != nil {
 		return err
 	}
 	// The i variable needs to be declared as a int32 for the Replicas type
 	var i int32
 	rc.Spec.Replicas = &i // Replicas type is *int32 ... so this is *int32(0)
-	client.ReplicationControllers(namespace).Update(rc)
-	time.Sleep(15 * time.Second) // Wait for Kubernetes to delete pods
-	rc, err = client.ReplicationControllers(namespace).Get(metaName)
+	rcs.Update(rc)
+	// Wait for Kubernetes to delete pods
+	if err := wait(ctx, 15*time.Second); err != nil {
+		return err
+	}
+	rc, err = rcs.Get(metaName)
 	if err != nil {
 		return err
 	}
@@ -95,5 +105,5 @@ func deleteRC(namespace, metaName string) error {
 	// 	attempt++
 	// }
 	// DELETE RC
-	return client.ReplicationControllers(namespace).Delete(metaName, nil)
+	return rcs.Delete(metaName, nil)
 }
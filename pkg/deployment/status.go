@@ -0,0 +1,166 @@
+package deployment
+
+import (
+	"errors"
+
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/meta"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// ObjectStatus is the live state of an object deployed from a manifest
+type ObjectStatus struct {
+	Manifest string `json:"manifest"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	// Missing means the object isn't in the cluster
+	Missing bool `json:"missing,omitempty"`
+	// Replicas are set for replication controllers
+	Replicas *ReplicaStatus `json:"replicas,omitempty"`
+	// Pods are set for pods and replication controllers
+	Pods []PodStatus `json:"pods,omitempty"`
+	// ClusterIP and Ports are set for services
+	ClusterIP string        `json:"cluster_ip,omitempty"`
+	Ports     []ServicePort `json:"ports,omitempty"`
+}
+
+// ReplicaStatus counts the replicas of a replication controller
+type ReplicaStatus struct {
+	Desired int32 `json:"desired"`
+	Current int32 `json:"current"`
+	Ready   int32 `json:"ready"`
+}
+
+// PodStatus is the live state of a pod
+type PodStatus struct {
+	Name     string `json:"name"`
+	Phase    string `json:"phase"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	// Waiting maps the containers that aren't running yet to the reason,
+	// such as CrashLoopBackOff or ImagePullBackOff
+	Waiting map[string]string `json:"waiting,omitempty"`
+}
+
+// ServicePort is a port of a service
+type ServicePort struct {
+	Name     string `json:"name,omitempty"`
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port"`
+	NodePort int32  `json:"node_port,omitempty"`
+}
+
+// StatusReader declares a Deployer that can report the live state of what it
+// deployed
+type StatusReader interface {
+	Status() ([]ObjectStatus, error)
+}
+
+// Status reads the live state of the objects rendered from the manifests
+func (d *KubernetesDeployment) Status() ([]ObjectStatus, error) {
+	steps, err := d.steps()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []ObjectStatus{}
+	for i, step := range steps {
+		s, ok := step.(*ManifestStep)
+		if !ok {
+			continue
+		}
+		m, err := meta.Accessor(s.object)
+		if err != nil {
+			return nil, err
+		}
+		status := ObjectStatus{
+			Manifest: d.Playbook.Manifests[i],
+			Kind:     s.object.GetObjectKind().GroupVersionKind().Kind,
+			Name:     m.GetName(),
+		}
+		if err := s.status(&status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// status fills in status with the live state of the step's object
+func (s *ManifestStep) status(status *ObjectStatus) error {
+	var err error
+	switch o := s.object.(type) {
+	case *v1.ReplicationController:
+		var rc *v1.ReplicationController
+		if rc, err = s.cluster.Client.ReplicationControllers(s.cluster.Namespace).Get(o.ObjectMeta.Name); err == nil {
+			status.Replicas = &ReplicaStatus{Current: rc.Status.Replicas}
+			if rc.Spec.Replicas != nil {
+				status.Replicas.Desired = *rc.Spec.Replicas
+			}
+			err = s.podStatuses(status)
+		}
+	case *v1.Pod:
+		var pod *v1.Pod
+		if pod, err = s.cluster.Client.Pods(s.cluster.Namespace).Get(o.ObjectMeta.Name); err == nil {
+			status.Pods = []PodStatus{podStatus(*pod)}
+		}
+	case *v1.Service:
+		var service *v1.Service
+		if service, err = s.cluster.Client.Services(s.cluster.Namespace).Get(o.ObjectMeta.Name); err == nil {
+			status.ClusterIP = service.Spec.ClusterIP
+			for _, p := range service.Spec.Ports {
+				status.Ports = append(status.Ports, ServicePort{
+					Name:     p.Name,
+					Protocol: string(p.Protocol),
+					Port:     p.Port,
+					NodePort: p.NodePort,
+				})
+			}
+		}
+	default:
+		return errors.New("Kubernetes resource is not recognized: " + s.object.GetObjectKind().GroupVersionKind().Kind)
+	}
+	if apierrors.IsNotFound(err) {
+		status.Missing = true
+		return nil
+	}
+	return err
+}
+
+// podStatuses adds the state of the replication controller's pods to status,
+// counting the ready ones as ready replicas
+func (s *ManifestStep) podStatuses(status *ObjectStatus) error {
+	pods, err := s.pods()
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		ps := podStatus(pod)
+		if ps.Ready {
+			status.Replicas.Ready++
+		}
+		status.Pods = append(status.Pods, ps)
+	}
+	return nil
+}
+
+// podStatus summarizes the status of pod's containers. Pods are ready once
+// they have containers and they're all ready.
+func podStatus(pod v1.Pod) PodStatus {
+	ps := PodStatus{
+		Name:  pod.ObjectMeta.Name,
+		Phase: string(pod.Status.Phase),
+		Ready: len(pod.Status.ContainerStatuses) > 0,
+	}
+	for _, c := range pod.Status.ContainerStatuses {
+		ps.Restarts += c.RestartCount
+		ps.Ready = ps.Ready && c.Ready
+		if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
+			if ps.Waiting == nil {
+				ps.Waiting = map[string]string{}
+			}
+			ps.Waiting[c.Name] = c.State.Waiting.Reason
+		}
+	}
+	return ps
+}
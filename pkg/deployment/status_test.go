@@ -0,0 +1,162 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/release_1_3/typed/core/v1/fake"
+	"k8s.io/kubernetes/pkg/client/testing/core"
+)
+
+var rcLive = `apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: test2
+spec:
+  replicas: 2
+  selector:
+    name: redis
+  template:
+    metadata:
+      labels:
+        name: redis
+    spec:
+      containers:
+      - name: redis
+        image: kubernetes/redis:v1
+status:
+  replicas: 1
+`
+
+var podLive = `apiVersion: v1
+kind: Pod
+metadata:
+  name: redis-1
+  labels:
+    name: redis
+spec:
+  containers:
+  - name: redis
+    image: kubernetes/redis:v1
+  - name: sidecar
+    image: busybox
+status:
+  phase: Running
+  containerStatuses:
+  - name: redis
+    ready: true
+    restartCount: 1
+  - name: sidecar
+    ready: false
+    restartCount: 4
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+`
+
+var svct1 = `apiVersion: v1
+kind: Service
+metadata:
+  name: redis
+spec:
+  type: NodePort
+  clusterIP: 10.0.0.12
+  ports:
+  - port: 6379
+    protocol: TCP
+    nodePort: 30379
+  selector:
+    name: redis
+`
+
+func TestManifestStepStatus(t *testing.T) {
+	crashing := PodStatus{
+		Name:     "redis-1",
+		Phase:    "Running",
+		Restarts: 5,
+		Waiting:  map[string]string{"sidecar": "CrashLoopBackOff"},
+	}
+	cases := []struct {
+		Name     string
+		Live     []string
+		Object   string
+		Expected ObjectStatus
+	}{
+		{
+			Name:   "RC",
+			Live:   []string{rcLive, podLive},
+			Object: rct1,
+			Expected: ObjectStatus{
+				Replicas: &ReplicaStatus{Desired: 2, Current: 1, Ready: 0},
+				Pods:     []PodStatus{crashing},
+			},
+		},
+		{
+			Name:   "RC without a selector",
+			Live:   []string{rcLive, podLive},
+			Object: rcNoSelector,
+			Expected: ObjectStatus{
+				Replicas: &ReplicaStatus{Desired: 2, Current: 1, Ready: 0},
+				Pods:     []PodStatus{crashing},
+			},
+		},
+		{
+			Name:     "RC missing",
+			Live:     []string{podLive},
+			Object:   rct1,
+			Expected: ObjectStatus{Missing: true},
+		},
+		{
+			Name:     "Pod",
+			Live:     []string{podLive},
+			Object:   podt1,
+			Expected: ObjectStatus{Pods: []PodStatus{crashing}},
+		},
+		{
+			Name:   "Service",
+			Live:   []string{svct1},
+			Object: svct1,
+			Expected: ObjectStatus{
+				ClusterIP: "10.0.0.12",
+				Ports:     []ServicePort{{Protocol: "TCP", Port: 6379, NodePort: 30379}},
+			},
+		},
+		{
+			Name:     "Service missing",
+			Object:   svct1,
+			Expected: ObjectStatus{Missing: true},
+		},
+	}
+
+	for _, c := range cases {
+		cluster := newTestCluster()
+		f := cluster.Client.(*fake.FakeCore).Fake
+		o := core.NewObjects(api.Scheme, api.Codecs.UniversalDecoder())
+		for _, live := range c.Live {
+			if err := o.Add(mustDeserialize(live)); err != nil {
+				panic(err)
+			}
+		}
+		f.AddReactor("*", "*", core.ObjectReaction(o, api.RESTMapper))
+
+		status := ObjectStatus{}
+		err := NewManifestStep(cluster, mustDeserialize(c.Object)).(*ManifestStep).status(&status)
+		assert.Nil(t, err, c.Name)
+		assert.Equal(t, c.Expected, status, c.Name)
+		for _, a := range f.Actions() {
+			assert.Contains(t, []string{"get", "list"}, a.GetVerb(), c.Name+" only looks at the cluster")
+		}
+	}
+}
+
+func TestPodStatus(t *testing.T) {
+	pod := *mustDeserialize(podLive).(*v1.Pod)
+	pod.Status.ContainerStatuses[1] = v1.ContainerStatus{Name: "sidecar", Ready: true}
+	assert.Equal(t, PodStatus{Name: "redis-1", Phase: "Running", Ready: true, Restarts: 1}, podStatus(pod))
+
+	pod.Status = v1.PodStatus{Phase: v1.PodPending}
+	assert.Equal(t, PodStatus{Name: "redis-1", Phase: "Pending"}, podStatus(pod), "pods without containers aren't ready")
+}
@@ -0,0 +1,139 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// Actions recorded in an instance's history
+const (
+	ActionDeploy   = "deploy"
+	ActionStop     = "stop"
+	ActionRollback = "rollback"
+)
+
+// Path represents the store path holding the history of an instance
+type Path struct {
+	RootPath   string
+	PlaybookID string
+	InstanceID string
+}
+
+func (p Path) String() string {
+	return fmt.Sprintf("%s/history/%s/%s", p.RootPath, p.PlaybookID, p.InstanceID)
+}
+
+// Step is the outcome of a single deployment step
+type Step struct {
+	Name       string `json:"name"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Record describes a single deploy or stop attempt of an instance
+type Record struct {
+	ID           string            `json:"id"`
+	PlaybookID   string            `json:"playbook_id"`
+	InstanceID   string            `json:"instance_id"`
+	Action       string            `json:"action"`
+	Actor        string            `json:"actor"`
+	StartedAt    int64             `json:"started_at"`
+	FinishedAt   int64             `json:"finished_at"`
+	Vars         map[string]string `json:"vars"`
+	ManifestHash string            `json:"manifest_hash"`
+	Revision     int               `json:"revision,omitempty"`
+	Steps        []Step            `json:"steps"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// NewRecord starts a Record for an action on an instance at time now
+func NewRecord(playbookID, instanceID, action, actor string, vars map[string]string, now time.Time) *Record {
+	vs := make(map[string]string, len(vars))
+	for k, v := range vars {
+		vs[k] = v
+	}
+	return &Record{
+		ID:         store.TimeKey(now),
+		PlaybookID: playbookID,
+		InstanceID: instanceID,
+		Action:     action,
+		Actor:      actor,
+		StartedAt:  now.Unix(),
+		Vars:       vs,
+		Steps:      []Step{},
+	}
+}
+
+// Finish marks the record as done at time now with the final error, if any
+func (r *Record) Finish(err error, now time.Time) {
+	r.FinishedAt = now.Unix()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Succeeded returns true if the recorded action finished without error
+func (r *Record) Succeeded() bool {
+	return r.FinishedAt != 0 && r.Error == ""
+}
+
+// Save stores a record under the instance's history path
+func Save(ctx context.Context, s store.Store, root string, r *Record) error {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	p := Path{RootPath: root, PlaybookID: r.PlaybookID, InstanceID: r.InstanceID}
+	return s.SetValue(ctx, p.String()+"/"+r.ID, string(encoded))
+}
+
+// List returns the history of an instance, newest first
+func List(ctx context.Context, s store.Store, p Path) ([]*Record, error) {
+	values, err := s.Values(ctx, p.String())
+	if err != nil {
+		return nil, err
+	}
+	records := []*Record{}
+	for _, v := range values {
+		r := &Record{}
+		if err := json.Unmarshal([]byte(v), r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	sort.Sort(sort.Reverse(byID(records)))
+	return records, nil
+}
+
+// Prune deletes all but the newest keep records of an instance. A keep of
+// zero or less retains everything.
+func Prune(ctx context.Context, s store.Store, p Path, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	records, err := List(ctx, s, p)
+	if err != nil {
+		return err
+	}
+	if len(records) <= keep {
+		return nil
+	}
+	for _, r := range records[keep:] {
+		if err := s.Delete(ctx, p.String()+"/"+r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byID []*Record
+
+func (rr byID) Len() int           { return len(rr) }
+func (rr byID) Less(i, j int) bool { return rr[i].ID < rr[j].ID }
+func (rr byID) Swap(i, j int)      { rr[i], rr[j] = rr[j], rr[i] }
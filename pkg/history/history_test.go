@@ -0,0 +1,72 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSaveAndList(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	p := Path{RootPath: "/broadwaytest", PlaybookID: "hello", InstanceID: "test"}
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	vars := map[string]string{"version": "1"}
+	r1 := NewRecord("hello", "test", ActionDeploy, "slack:bill", vars, t0)
+	r1.Finish(nil, t0.Add(time.Minute))
+	vars["version"] = "2"
+	r2 := NewRecord("hello", "test", ActionDeploy, "api:token", vars, t0.Add(time.Hour))
+	r2.Finish(errors.New("boom"), t0.Add(time.Hour+time.Minute))
+
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", r1))
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", r2))
+
+	records, err := List(ctx, s, p)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, r2.ID, records[0].ID, "newest record comes first")
+	assert.Equal(t, "boom", records[0].Error)
+	assert.False(t, records[0].Succeeded())
+	assert.Equal(t, "1", records[1].Vars["version"], "vars are snapshotted")
+	assert.True(t, records[1].Succeeded())
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	p := Path{RootPath: "/broadwaytest", PlaybookID: "hello", InstanceID: "test"}
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	for k := 0; k < 5; k++ {
+		r := NewRecord("hello", "test", ActionDeploy, "slack:bill", nil, t0.Add(time.Duration(k)*time.Hour))
+		assert.Nil(t, Save(ctx, s, "/broadwaytest", r))
+	}
+
+	assert.Nil(t, Prune(ctx, s, p, 0))
+	records, _ := List(ctx, s, p)
+	assert.Len(t, records, 5, "a retention of 0 keeps everything")
+
+	assert.Nil(t, Prune(ctx, s, p, 2))
+	records, _ = List(ctx, s, p)
+	assert.Len(t, records, 2)
+	assert.Equal(t, t0.Add(4*time.Hour).Unix(), records[0].StartedAt)
+	assert.Equal(t, t0.Add(3*time.Hour).Unix(), records[1].StartedAt)
+}
+
+func TestSameTimeRecords(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	p := Path{RootPath: "/broadwaytest", PlaybookID: "hello", InstanceID: "test"}
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", NewRecord("hello", "test", ActionDeploy, "slack:bill", nil, now)))
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", NewRecord("hello", "test", ActionStop, "api:token", nil, now)))
+	records, err := List(ctx, s, p)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2, "records started at the same time are all kept")
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
 )
 
 // NewExpiredAt builds a new ExpiredAt
@@ -15,6 +18,23 @@ func NewExpiredAt(daysToExpire int, from time.Time) time.Time {
 	return expiredAt
 }
 
+// ParseLifetime parses a lifetime such as "3d", "12h" or "90m". Besides the
+// units understood by time.ParseDuration it accepts whole days with "d".
+func ParseLifetime(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("broadway/instance: invalid lifetime %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("broadway/instance: invalid lifetime %q", s)
+	}
+	return d, nil
+}
+
 // NotFoundError instance not found error
 type NotFoundError string
 
@@ -53,14 +73,53 @@ type Instance struct {
 	Created    int64             `json:"created_time"`
 	ExpiredAt  int64             `json:"expired_at"`
 	Vars       map[string]string `json:"vars"`
+	Revision   int               `json:"revision"`
+	Pinned     bool              `json:"pinned"`
 	Status     `json:"status"`
+	Timestamps map[string]int64 `json:"timestamps"`
+	LastError  string           `json:"last_error,omitempty"`
+	Owner      string           `json:"owner,omitempty"`
+	Heartbeat  int64            `json:"heartbeat,omitempty"`
+	Operation  string           `json:"operation,omitempty"`
+	WarnedFor  int64            `json:"warned_for,omitempty"`
+	Target     string           `json:"target,omitempty"`
+	Drift      []Drift          `json:"drift,omitempty"`
+	DriftedAt  int64            `json:"drifted_at,omitempty"`
 	Path
 }
 
+// Drift describes an object of a deployed instance that went missing from
+// the cluster or no longer matches its manifest
+type Drift struct {
+	Manifest string `json:"manifest"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	State    string `json:"state"`
+}
+
 func (i *Instance) String() string {
 	return fmt.Sprintf("%s (%s)", i.Path.String(), i.Status)
 }
 
+// Extend pushes the expiration of i back by d, counting from now if i has
+// already expired
+func (i *Instance) Extend(d time.Duration, now time.Time) {
+	from := time.Unix(i.ExpiredAt, 0)
+	if from.Before(now) {
+		from = now
+	}
+	i.ExpiredAt = from.Add(d).Unix()
+}
+
+// ExpiresIn returns how long i has left before it expires. It returns false if
+// i never expires, either because it is pinned or has no expiration.
+func (i *Instance) ExpiresIn(now time.Time) (time.Duration, bool) {
+	if i.Pinned || i.ExpiredAt == 0 {
+		return 0, false
+	}
+	return time.Unix(i.ExpiredAt, 0).Sub(now), true
+}
+
 // Status for an instance
 type Status string
 
@@ -73,16 +132,21 @@ const (
 	StatusDeployed Status = "deployed"
 	// StatusDeleting represents an instance that has begun deltion
 	StatusDeleting Status = "deleting"
+	// StatusStopped represents an instance whose resources were deleted
+	StatusStopped Status = "stopped"
 	// StatusError represents an instance that broke
 	StatusError Status = "error"
 )
 
 // FindByPath find an instance based on it's path
-func FindByPath(store store.Store, path Path) (*Instance, error) {
-	i := store.Value(path.String())
-	if i == "" {
+func FindByPath(ctx context.Context, s store.Store, path Path) (*Instance, error) {
+	i, err := s.Value(ctx, path.String())
+	if err == store.ErrNotFound || (err == nil && i == "") {
 		return nil, NotFoundError(path.String())
 	}
+	if err != nil {
+		return nil, err
+	}
 	instance, err := fromJSON(i)
 	if err != nil {
 		return nil, err
@@ -91,39 +155,44 @@ func FindByPath(store store.Store, path Path) (*Instance, error) {
 }
 
 // FindByPlaybookPath find all the instances for an specified playbook path
-func FindByPlaybookPath(store store.Store, playbookPath PlaybookPath) ([]*Instance, error) {
-	return retrieveInstancesByKey(store, playbookPath.String())
+func FindByPlaybookPath(ctx context.Context, store store.Store, playbookPath PlaybookPath) ([]*Instance, error) {
+	return retrieveInstancesByKey(ctx, store, playbookPath.String())
 }
 
-// AllDeployedAndExpired find all instances from in the store
-func AllDeployedAndExpired(store store.Store, path string, expirationDate time.Time) ([]*Instance, error) {
+// AllDeployedAndExpired find all deployed, unpinned instances in the store
+// that expired by expirationDate
+func AllDeployedAndExpired(ctx context.Context, store store.Store, path string, expirationDate time.Time) ([]*Instance, error) {
 	var expiredInstances []*Instance
-	instances, err := retrieveInstancesByKey(store, path)
+	instances, err := retrieveInstancesByKey(ctx, store, path)
 	if err != nil {
 		return nil, err
 	}
+	unpinned := false
+	q := Query{
+		Statuses:  []Status{StatusDeployed},
+		Pinned:    &unpinned,
+		ExpiresTo: expirationDate.Unix(),
+	}
 	for _, i := range instances {
-		if i.Status == StatusDeployed {
-			if i.ExpiredAt != 0 && i.ExpiredAt <= expirationDate.Unix() {
-				expiredInstances = append(expiredInstances, i)
-			}
+		if q.Matches(i) {
+			expiredInstances = append(expiredInstances, i)
 		}
 	}
 	return expiredInstances, nil
 }
 
 // Save an instance into the Store
-func Save(store store.Store, instance *Instance) error {
+func Save(ctx context.Context, store store.Store, instance *Instance) error {
 	encoded, err := toJSON(instance)
 	if err != nil {
 		return err
 	}
-	return store.SetValue(instance.Path.String(), encoded)
+	return store.SetValue(ctx, instance.Path.String(), encoded)
 }
 
 // Delete an instance from the store
-func Delete(store store.Store, path Path) error {
-	return store.Delete(path.String())
+func Delete(ctx context.Context, store store.Store, path Path) error {
+	return store.Delete(ctx, path.String())
 }
 
 func fromJSON(jsonData string) (*Instance, error) {
@@ -132,6 +201,10 @@ func fromJSON(jsonData string) (*Instance, error) {
 	if err != nil {
 		return nil, ErrMalformedSaveData
 	}
+	// Path's ids are shadowed by the instance's own, restore them so the
+	// instance can be saved back where it came from:
+	instance.Path.PlaybookID = instance.PlaybookID
+	instance.Path.ID = instance.ID
 	return &instance, nil
 }
 
@@ -143,8 +216,11 @@ func toJSON(instance *Instance) (string, error) {
 	return string(encoded), nil
 }
 
-func retrieveInstancesByKey(store store.Store, key string) ([]*Instance, error) {
-	data := store.Values(key)
+func retrieveInstancesByKey(ctx context.Context, store store.Store, key string) ([]*Instance, error) {
+	data, err := store.Values(ctx, key)
+	if err != nil {
+		return nil, err
+	}
 	instances := []*Instance{}
 	for _, value := range data {
 		instance, err := fromJSON(value)
@@ -6,6 +6,7 @@ import (
 
 	"github.com/namely/broadway/pkg/store"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestNewExpiredAt(t *testing.T) {
@@ -38,6 +39,7 @@ func TestNewExpiredAt(t *testing.T) {
 }
 
 func TestFindByPath(t *testing.T) {
+	unavailable := &store.UnavailableError{Op: "get", Path: "etcdPath/instances/test/id", Err: context.DeadlineExceeded}
 	testcases := []struct {
 		Scenario           string
 		Path               Path
@@ -49,8 +51,8 @@ func TestFindByPath(t *testing.T) {
 			Scenario: "When the instance is properly save",
 			Path:     Path{"etcdPath", "test", "id"},
 			Store: &store.FakeStore{
-				MockValue: func(path string) string {
-					return `{"playbook_id":"test", "id": "id", "status": "deployed"}`
+				MockValue: func(path string) (string, error) {
+					return `{"playbook_id":"test", "id": "id", "status": "deployed"}`, nil
 				},
 			},
 			ExpectedPlaybookID: "test",
@@ -60,8 +62,8 @@ func TestFindByPath(t *testing.T) {
 			Scenario: "When the instance was not properly save",
 			Path:     Path{"etcdPath", "test", "id"},
 			Store: &store.FakeStore{
-				MockValue: func(path string) string {
-					return `{"playbook_id":}`
+				MockValue: func(path string) (string, error) {
+					return `{"playbook_id":}`, nil
 				},
 			},
 			ExpectedPlaybookID: "",
@@ -71,20 +73,32 @@ func TestFindByPath(t *testing.T) {
 			Scenario: "When the instance does not exist",
 			Path:     Path{"etcdPath", "test", "id"},
 			Store: &store.FakeStore{
-				MockValue: func(path string) string {
-					return ""
+				MockValue: func(path string) (string, error) {
+					return "", store.ErrNotFound
 				},
 			},
 			ExpectedPlaybookID: "",
 			ExpectedError:      NotFoundError("etcdPath/instances/test/id"),
 		},
+		{
+			Scenario: "When the store is unavailable",
+			Path:     Path{"etcdPath", "test", "id"},
+			Store: &store.FakeStore{
+				MockValue: func(path string) (string, error) {
+					return "", unavailable
+				},
+			},
+			ExpectedPlaybookID: "",
+			ExpectedError:      unavailable,
+		},
 	}
 
 	for _, tc := range testcases {
-		returnedInstance, err := FindByPath(tc.Store, tc.Path)
+		returnedInstance, err := FindByPath(context.Background(), tc.Store, tc.Path)
 		assert.Equal(t, tc.ExpectedError, err, tc.Scenario)
 		if err == nil {
 			assert.Equal(t, tc.ExpectedPlaybookID, returnedInstance.PlaybookID)
+			assert.Equal(t, "test/id", returnedInstance.Path.PlaybookID+"/"+returnedInstance.Path.ID, tc.Scenario)
 		}
 	}
 }
@@ -100,25 +114,25 @@ func TestFindByPlaybookID(t *testing.T) {
 		{
 			Scenario: "When instances exist in the store",
 			Store: &store.FakeStore{
-				MockValues: func(string) map[string]string {
+				MockValues: func(string) (map[string]string, error) {
 					return map[string]string{
 						"rootPath/instances/test":  `{"playbook_id": "test", "id": "id", "status": "deployed"}`,
 						"rootPath/instances/test1": `{"playbook_id": "test1", "id": "id", "status": "deployed"}`,
-					}
+					}, nil
 				},
 			},
 			PlaybookPath: PlaybookPath{"rootPath", "test"},
 			ExpectedInstances: map[string]Instance{
-				"test/id":  Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed},
-				"test1/id": Instance{PlaybookID: "test1", ID: "id", Status: StatusDeployed},
+				"test/id":  Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed, Path: Path{"", "test", "id"}},
+				"test1/id": Instance{PlaybookID: "test1", ID: "id", Status: StatusDeployed, Path: Path{"", "test1", "id"}},
 			},
 			ExpectedError: nil,
 		},
 		{
 			Scenario: "When instances does not exist in the store",
 			Store: &store.FakeStore{
-				MockValues: func(string) map[string]string {
-					return nil
+				MockValues: func(string) (map[string]string, error) {
+					return nil, nil
 				},
 			},
 			PlaybookPath:      PlaybookPath{"rootPath", "test"},
@@ -128,11 +142,11 @@ func TestFindByPlaybookID(t *testing.T) {
 		{
 			Scenario: "When the data is malformed",
 			Store: &store.FakeStore{
-				MockValues: func(string) map[string]string {
+				MockValues: func(string) (map[string]string, error) {
 					return map[string]string{
 						"rootPath/instances/test":  `{"playbook_id": "test", "id": "id", "status": "deployed"}`,
 						"rootPath/instances/test1": `{"playbook_id":`,
-					}
+					}, nil
 				},
 			},
 			PlaybookPath:      PlaybookPath{"rootPath", "test"},
@@ -142,7 +156,7 @@ func TestFindByPlaybookID(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		instances, err := FindByPlaybookPath(tc.Store, tc.PlaybookPath)
+		instances, err := FindByPlaybookPath(context.Background(), tc.Store, tc.PlaybookPath)
 		assert.Equal(t, tc.ExpectedError, err, tc.Scenario)
 		if err == nil {
 			actual := map[string]Instance{}
@@ -183,7 +197,7 @@ func TestSave(t *testing.T) {
 		},
 	}
 	for _, tc := range testcases {
-		err := Save(tc.Store, tc.Instance)
+		err := Save(context.Background(), tc.Store, tc.Instance)
 		assert.Equal(t, tc.ExpectedError, err, tc.Scenario)
 	}
 }
@@ -208,7 +222,7 @@ func TestDelete(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		err := Delete(tc.Store, tc.Path)
+		err := Delete(context.Background(), tc.Store, tc.Path)
 		assert.Equal(t, tc.ExpectedError, err, tc.Scenario)
 	}
 }
@@ -227,14 +241,14 @@ func TestAllDeployedAndExpired(t *testing.T) {
 			Path:           "broadwaytest/instances",
 			ExpirationDate: time.Date(2016, 8, 5, 00, 00, 00, 651387237, time.UTC),
 			Store: &store.FakeStore{
-				MockValues: func(path string) map[string]string {
+				MockValues: func(path string) (map[string]string, error) {
 					return map[string]string{
 						"etcdPath/instances": `{"playbook_id":"test", "id": "id", "status": "deployed", "expired_at": 10}`,
-					}
+					}, nil
 				},
 			},
 			ExpectedInstances: []*Instance{
-				&Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed, ExpiredAt: 10},
+				&Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed, ExpiredAt: 10, Path: Path{"", "test", "id"}},
 			},
 			ExpectedError: nil,
 		},
@@ -243,22 +257,77 @@ func TestAllDeployedAndExpired(t *testing.T) {
 			Path:           "broadwaytest/instances",
 			ExpirationDate: time.Date(2016, 8, 5, 00, 00, 00, 651387237, time.UTC),
 			Store: &store.FakeStore{
-				MockValues: func(path string) map[string]string {
+				MockValues: func(path string) (map[string]string, error) {
 					return map[string]string{
 						"etcdPath/instances": `{"playbook_id":"test", "id": "id", "status": "deployed", "expired_at": 1470355200}`,
-					}
+					}, nil
 				},
 			},
 			ExpectedInstances: []*Instance{
-				&Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed, ExpiredAt: 1470355200},
+				&Instance{PlaybookID: "test", ID: "id", Status: StatusDeployed, ExpiredAt: 1470355200, Path: Path{"", "test", "id"}},
 			},
 			ExpectedError: nil,
 		},
+		{
+			Scenario:       "AllDeployedAndExpired: When an expired instance is pinned",
+			Path:           "broadwaytest/instances",
+			ExpirationDate: time.Date(2016, 8, 5, 00, 00, 00, 651387237, time.UTC),
+			Store: &store.FakeStore{
+				MockValues: func(path string) (map[string]string, error) {
+					return map[string]string{
+						"etcdPath/instances": `{"playbook_id":"test", "id": "id", "status": "deployed", "expired_at": 10, "pinned": true}`,
+					}, nil
+				},
+			},
+			ExpectedInstances: nil,
+			ExpectedError:     nil,
+		},
 	}
 
 	for _, tc := range testcases {
-		instances, err := AllDeployedAndExpired(tc.Store, tc.Path, tc.ExpirationDate)
+		instances, err := AllDeployedAndExpired(context.Background(), tc.Store, tc.Path, tc.ExpirationDate)
 		assert.Equal(t, tc.ExpectedError, err, tc.Scenario)
 		assert.Equal(t, tc.ExpectedInstances, instances, tc.Scenario)
 	}
 }
+
+func TestParseLifetime(t *testing.T) {
+	testcases := []struct {
+		Scenario string
+		Input    string
+		Expected time.Duration
+		Err      bool
+	}{
+		{"days", "3d", 72 * time.Hour, false},
+		{"hours", "12h", 12 * time.Hour, false},
+		{"minutes", "90m", 90 * time.Minute, false},
+		{"bad days", "xd", 0, true},
+		{"garbage", "soon", 0, true},
+	}
+
+	for _, tc := range testcases {
+		d, err := ParseLifetime(tc.Input)
+		assert.Equal(t, tc.Err, err != nil, tc.Scenario)
+		assert.Equal(t, tc.Expected, d, tc.Scenario)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	i := &Instance{ExpiredAt: now.Add(24 * time.Hour).Unix()}
+	i.Extend(48*time.Hour, now)
+	assert.Equal(t, now.Add(72*time.Hour).Unix(), i.ExpiredAt, "extends from the current expiration")
+
+	i = &Instance{ExpiredAt: now.Add(-24 * time.Hour).Unix()}
+	i.Extend(48*time.Hour, now)
+	assert.Equal(t, now.Add(48*time.Hour).Unix(), i.ExpiredAt, "extends from now once expired")
+
+	left, ok := i.ExpiresIn(now)
+	assert.True(t, ok)
+	assert.Equal(t, 48*time.Hour, left)
+
+	i.Pinned = true
+	_, ok = i.ExpiresIn(now)
+	assert.False(t, ok, "pinned instances never expire")
+}
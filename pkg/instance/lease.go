@@ -0,0 +1,54 @@
+package instance
+
+import (
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// Transitional returns true for statuses an instance only holds while a
+// broadway process works on it
+func (s Status) Transitional() bool {
+	return s == StatusDeploying || s == StatusDeleting
+}
+
+// Claim records that owner is running operation, such as a deploy or a
+// delete, on i as of now
+func (i *Instance) Claim(owner, operation string, now time.Time) {
+	i.Owner = owner
+	i.Operation = operation
+	i.Heartbeat = now.Unix()
+}
+
+// Release clears the lease on i
+func (i *Instance) Release() {
+	i.Owner = ""
+	i.Operation = ""
+	i.Heartbeat = 0
+}
+
+// Abandoned returns true if i is in a transitional status but its owner hasn't
+// renewed the lease within ttl of now
+func (i *Instance) Abandoned(ttl time.Duration, now time.Time) bool {
+	if !i.Status.Transitional() {
+		return false
+	}
+	return time.Unix(i.Heartbeat, 0).Add(ttl).Before(now)
+}
+
+// AllAbandoned returns the instances under path whose operation was abandoned
+// by the process running it
+func AllAbandoned(ctx context.Context, store store.Store, path string, ttl time.Duration, now time.Time) ([]*Instance, error) {
+	var abandoned []*Instance
+	instances, err := retrieveInstancesByKey(ctx, store, path)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range instances {
+		if i.Abandoned(ttl, now) {
+			abandoned = append(abandoned, i)
+		}
+	}
+	return abandoned, nil
+}
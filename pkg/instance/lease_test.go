@@ -0,0 +1,77 @@
+package instance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAbandoned(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	ttl := 90 * time.Second
+	testcases := []struct {
+		Scenario  string
+		Instance  *Instance
+		Abandoned bool
+	}{
+		{
+			"a deploy with a fresh heartbeat is still running",
+			&Instance{Status: StatusDeploying, Owner: "a/1", Heartbeat: now.Add(-30 * time.Second).Unix()},
+			false,
+		},
+		{
+			"a deploy with a stale heartbeat was abandoned",
+			&Instance{Status: StatusDeploying, Owner: "a/1", Heartbeat: now.Add(-5 * time.Minute).Unix()},
+			true,
+		},
+		{
+			"a stop that never had a lease was abandoned",
+			&Instance{Status: StatusDeleting},
+			true,
+		},
+		{
+			"deployed instances are never abandoned",
+			&Instance{Status: StatusDeployed},
+			false,
+		},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.Abandoned, tc.Instance.Abandoned(ttl, now), tc.Scenario)
+	}
+}
+
+func TestAllAbandoned(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for _, i := range []*Instance{
+		{PlaybookID: "hello", ID: "stuck", Status: StatusDeploying, Heartbeat: now.Add(-time.Hour).Unix()},
+		{PlaybookID: "hello", ID: "running", Status: StatusDeploying, Heartbeat: now.Unix()},
+		{PlaybookID: "hello", ID: "done", Status: StatusDeployed},
+	} {
+		i.Path = Path{"/broadwaytest", i.PlaybookID, i.ID}
+		assert.Nil(t, Save(ctx, s, i))
+	}
+
+	instances, err := AllAbandoned(ctx, s, "/broadwaytest/instances", time.Minute, now)
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "stuck", instances[0].ID)
+	}
+}
+
+func TestTransitionReleasesLease(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	i := &Instance{Status: StatusDeploying}
+	i.Claim("a/1", "deploy", now)
+	assert.Equal(t, "deploy", i.Operation)
+
+	assert.Nil(t, i.Transition(StatusDeployed, now, nil))
+	assert.Equal(t, "", i.Owner)
+	assert.Equal(t, "", i.Operation)
+	assert.Equal(t, int64(0), i.Heartbeat)
+}
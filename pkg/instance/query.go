@@ -0,0 +1,178 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// Sort orders accepted by Query, prefix them with "-" to reverse them
+const (
+	SortPlaybook = "playbook"
+	SortID       = "id"
+	SortCreated  = "created"
+	SortExpires  = "expires"
+)
+
+// InvalidSort indicates a query asked for an unknown sort order
+type InvalidSort string
+
+func (e InvalidSort) Error() string {
+	return fmt.Sprintf("broadway/instance: can't sort by %s", string(e))
+}
+
+// Query selects instances across playbooks. Zero values don't filter, except
+// for a non-nil empty PlaybookIDs which matches nothing. Time ranges are
+// inclusive unix timestamps, and instances that never expire don't match an
+// expiration range.
+type Query struct {
+	PlaybookIDs []string
+	Statuses    []Status
+	Vars        map[string]string
+	Pinned      *bool
+	Drifted     *bool
+	CreatedFrom int64
+	CreatedTo   int64
+	ExpiresFrom int64
+	ExpiresTo   int64
+	Sort        string
+	Offset      int
+	Limit       int
+}
+
+// Matches returns true if i satisfies every filter of q
+func (q Query) Matches(i *Instance) bool {
+	if q.PlaybookIDs != nil && !containsString(q.PlaybookIDs, i.PlaybookID) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !containsStatus(q.Statuses, i.Status) {
+		return false
+	}
+	for k, v := range q.Vars {
+		if iv, ok := i.Vars[k]; !ok || iv != v {
+			return false
+		}
+	}
+	if q.Pinned != nil && i.Pinned != *q.Pinned {
+		return false
+	}
+	if q.Drifted != nil && (len(i.Drift) > 0) != *q.Drifted {
+		return false
+	}
+	if !inRange(i.Created, q.CreatedFrom, q.CreatedTo) {
+		return false
+	}
+	if q.ExpiresFrom != 0 || q.ExpiresTo != 0 {
+		if i.ExpiredAt == 0 || !inRange(i.ExpiredAt, q.ExpiresFrom, q.ExpiresTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the instances under path matching q, sorted and paginated,
+// along with how many matched in total
+func Select(ctx context.Context, store store.Store, path string, q Query) ([]*Instance, int, error) {
+	less, err := sortFunc(q.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	instances, err := retrieveInstancesByKey(ctx, store, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	matched := []*Instance{}
+	for _, i := range instances {
+		if q.Matches(i) {
+			matched = append(matched, i)
+		}
+	}
+	sort.Sort(instanceSorter{matched, less})
+
+	total := len(matched)
+	if q.Offset >= total {
+		return []*Instance{}, total, nil
+	}
+	if q.Offset > 0 {
+		matched = matched[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	return matched, total, nil
+}
+
+func sortFunc(order string) (func(a, b *Instance) bool, error) {
+	byPlaybook := func(a, b *Instance) bool {
+		if a.PlaybookID != b.PlaybookID {
+			return a.PlaybookID < b.PlaybookID
+		}
+		return a.ID < b.ID
+	}
+	var less func(a, b *Instance) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "", SortPlaybook:
+		less = byPlaybook
+	case SortID:
+		less = func(a, b *Instance) bool {
+			if a.ID != b.ID {
+				return a.ID < b.ID
+			}
+			return a.PlaybookID < b.PlaybookID
+		}
+	case SortCreated:
+		less = func(a, b *Instance) bool {
+			if a.Created != b.Created {
+				return a.Created < b.Created
+			}
+			return byPlaybook(a, b)
+		}
+	case SortExpires:
+		less = func(a, b *Instance) bool {
+			if a.ExpiredAt != b.ExpiredAt {
+				return a.ExpiredAt < b.ExpiredAt
+			}
+			return byPlaybook(a, b)
+		}
+	default:
+		return nil, InvalidSort(order)
+	}
+	if strings.HasPrefix(order, "-") {
+		return func(a, b *Instance) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+type instanceSorter struct {
+	list []*Instance
+	less func(a, b *Instance) bool
+}
+
+func (s instanceSorter) Len() int           { return len(s.list) }
+func (s instanceSorter) Less(i, j int) bool { return s.less(s.list[i], s.list[j]) }
+func (s instanceSorter) Swap(i, j int)      { s.list[i], s.list[j] = s.list[j], s.list[i] }
+
+func inRange(v, from, to int64) bool {
+	return (from == 0 || v >= from) && (to == 0 || v <= to)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(ss []Status, s Status) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
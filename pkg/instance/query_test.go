@@ -0,0 +1,92 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestQueryMatches(t *testing.T) {
+	pinned := true
+	drifted := true
+	i := &Instance{
+		PlaybookID: "web",
+		ID:         "master",
+		Status:     StatusError,
+		Vars:       map[string]string{"owner": "bill"},
+		Created:    100,
+		ExpiredAt:  500,
+	}
+	testcases := []struct {
+		Scenario string
+		Query    Query
+		Matches  bool
+	}{
+		{"an empty query matches everything", Query{}, true},
+		{"matching playbook", Query{PlaybookIDs: []string{"api", "web"}}, true},
+		{"other playbook", Query{PlaybookIDs: []string{"api"}}, false},
+		{"no playbooks at all", Query{PlaybookIDs: []string{}}, false},
+		{"matching status", Query{Statuses: []Status{StatusDeployed, StatusError}}, true},
+		{"other status", Query{Statuses: []Status{StatusDeployed}}, false},
+		{"matching var", Query{Vars: map[string]string{"owner": "bill"}}, true},
+		{"other var value", Query{Vars: map[string]string{"owner": "ted"}}, false},
+		{"missing var", Query{Vars: map[string]string{"branch": ""}}, false},
+		{"pinned only", Query{Pinned: &pinned}, false},
+		{"drifted only", Query{Drifted: &drifted}, false},
+		{"created in range", Query{CreatedFrom: 100, CreatedTo: 200}, true},
+		{"created before range", Query{CreatedFrom: 101}, false},
+		{"expiring in range", Query{ExpiresTo: 500}, true},
+		{"expiring after range", Query{ExpiresTo: 499}, false},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.Matches, tc.Query.Matches(i), tc.Scenario)
+	}
+
+	never := &Instance{Status: StatusDeployed}
+	assert.False(t, Query{ExpiresFrom: 1}.Matches(never), "instances that never expire don't match an expiration range")
+}
+
+func TestSelect(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	for _, i := range []*Instance{
+		{PlaybookID: "web", ID: "master", Status: StatusDeployed, Created: 3},
+		{PlaybookID: "api", ID: "master", Status: StatusError, Created: 1},
+		{PlaybookID: "api", ID: "feature", Status: StatusDeployed, Created: 2},
+	} {
+		i.Path = Path{"/broadwaytest", i.PlaybookID, i.ID}
+		assert.Nil(t, Save(ctx, s, i))
+	}
+	ids := func(instances []*Instance) []string {
+		list := []string{}
+		for _, i := range instances {
+			list = append(list, i.PlaybookID+"/"+i.ID)
+		}
+		return list
+	}
+
+	instances, total, err := Select(ctx, s, "/broadwaytest/instances", Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total, "instances sharing an id across playbooks are all found")
+	assert.Equal(t, []string{"api/feature", "api/master", "web/master"}, ids(instances))
+
+	instances, total, err = Select(ctx, s, "/broadwaytest/instances", Query{Statuses: []Status{StatusDeployed}, Sort: "-created"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"web/master", "api/feature"}, ids(instances))
+
+	instances, total, err = Select(ctx, s, "/broadwaytest/instances", Query{Sort: SortCreated, Offset: 1, Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"api/feature"}, ids(instances))
+
+	instances, _, err = Select(ctx, s, "/broadwaytest/instances", Query{Offset: 5})
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+
+	_, _, err = Select(ctx, s, "/broadwaytest/instances", Query{Sort: "color"})
+	assert.Equal(t, InvalidSort("color"), err)
+}
@@ -0,0 +1,84 @@
+package instance
+
+import (
+	"fmt"
+	"time"
+)
+
+// transitions lists the statuses an instance may move to from each status
+var transitions = map[Status][]Status{
+	StatusNew:       {StatusDeploying, StatusDeleting},
+	StatusDeploying: {StatusDeployed, StatusError},
+	StatusDeployed:  {StatusDeploying, StatusDeleting},
+	StatusDeleting:  {StatusStopped, StatusError},
+	StatusStopped:   {StatusDeploying, StatusDeleting},
+	StatusError:     {StatusDeploying, StatusDeleting},
+}
+
+// InvalidTransition indicates an instance can't move between two statuses
+type InvalidTransition struct {
+	From Status
+	To   Status
+}
+
+func (e *InvalidTransition) Error() string {
+	return fmt.Sprintf("broadway/instance: can't go from %s to %s", e.From, e.To)
+}
+
+func (s Status) String() string {
+	if s == StatusNew {
+		return "new"
+	}
+	return string(s)
+}
+
+// CanTransition returns true if an instance in status from may move to to
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves i to status to at time now, recording when it happened.
+// Moving to StatusError records cause as the instance's LastError, and
+// reaching StatusDeployed or StatusStopped clears it. Leaving a transitional
+// status releases the instance's lease.
+func (i *Instance) Transition(to Status, now time.Time, cause error) error {
+	if !CanTransition(i.Status, to) {
+		return &InvalidTransition{From: i.Status, To: to}
+	}
+	i.Status = to
+	i.Stamp(now)
+	if !to.Transitional() {
+		i.Release()
+	}
+	switch to {
+	case StatusError:
+		if cause != nil {
+			i.LastError = cause.Error()
+		}
+	case StatusDeployed, StatusStopped:
+		i.LastError = ""
+	}
+	return nil
+}
+
+// StatusSince returns when i entered its current status, if known
+func (i *Instance) StatusSince() (time.Time, bool) {
+	at, ok := i.Timestamps[i.Status.String()]
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(at, 0), true
+}
+
+// Stamp records now as the time i entered its current status
+func (i *Instance) Stamp(now time.Time) {
+	if i.Timestamps == nil {
+		i.Timestamps = map[string]int64{}
+	}
+	i.Timestamps[i.Status.String()] = now.Unix()
+}
@@ -0,0 +1,68 @@
+package instance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransition(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		Scenario string
+		From     Status
+		To       Status
+		Allowed  bool
+	}{
+		{"new instances can be deployed", StatusNew, StatusDeploying, true},
+		{"deployments finish", StatusDeploying, StatusDeployed, true},
+		{"deployments fail", StatusDeploying, StatusError, true},
+		{"deploying twice is rejected", StatusDeploying, StatusDeploying, false},
+		{"stopping while deploying is rejected", StatusDeploying, StatusDeleting, false},
+		{"deployed instances can be stopped", StatusDeployed, StatusDeleting, true},
+		{"deleting twice is rejected", StatusDeleting, StatusDeleting, false},
+		{"stops finish", StatusDeleting, StatusStopped, true},
+		{"stopped instances can be redeployed", StatusStopped, StatusDeploying, true},
+		{"broken instances can be redeployed", StatusError, StatusDeploying, true},
+		{"new instances can't be deployed without deploying", StatusNew, StatusDeployed, false},
+	}
+
+	for _, tc := range testcases {
+		i := &Instance{Status: tc.From}
+		err := i.Transition(tc.To, now, nil)
+		if tc.Allowed {
+			assert.Nil(t, err, tc.Scenario)
+			assert.Equal(t, tc.To, i.Status, tc.Scenario)
+			assert.Equal(t, now.Unix(), i.Timestamps[tc.To.String()], tc.Scenario)
+		} else {
+			assert.Equal(t, &InvalidTransition{From: tc.From, To: tc.To}, err, tc.Scenario)
+			assert.Equal(t, tc.From, i.Status, tc.Scenario)
+		}
+	}
+}
+
+func TestTransitionLastError(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	i := &Instance{Status: StatusDeploying}
+
+	assert.Nil(t, i.Transition(StatusError, now, errors.New("image not found")))
+	assert.Equal(t, "image not found", i.LastError)
+
+	assert.Nil(t, i.Transition(StatusDeploying, now.Add(time.Minute), nil))
+	assert.Equal(t, "image not found", i.LastError, "the error is kept until the instance recovers")
+
+	assert.Nil(t, i.Transition(StatusDeployed, now.Add(2*time.Minute), nil))
+	assert.Equal(t, "", i.LastError)
+
+	since, ok := i.StatusSince()
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(2*time.Minute).Unix(), since.Unix())
+	assert.Equal(t, now.Unix(), i.Timestamps["error"])
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "new", StatusNew.String())
+	assert.Equal(t, "stopped", StatusStopped.String())
+}
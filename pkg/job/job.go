@@ -0,0 +1,177 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/namely/broadway/pkg/history"
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// Actions run as jobs
+const (
+	ActionDeploy   = "deploy"
+	ActionStop     = "stop"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+)
+
+// States of a job
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// NotFoundError indicates a job does not exist
+type NotFoundError string
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("broadway/job: %s was not found", string(e))
+}
+
+// Path represents the store path holding a job
+type Path struct {
+	RootPath string
+	ID       string
+}
+
+func (p Path) String() string {
+	return fmt.Sprintf("%s/jobs/%s", p.RootPath, p.ID)
+}
+
+// Job tracks an action on an instance running in the background
+type Job struct {
+	ID          string         `json:"id"`
+	Action      string         `json:"action"`
+	PlaybookID  string         `json:"playbook_id"`
+	InstanceID  string         `json:"instance_id"`
+	Actor       string         `json:"actor"`
+	State       string         `json:"state"`
+	CreatedAt   int64          `json:"created_at"`
+	StartedAt   int64          `json:"started_at,omitempty"`
+	FinishedAt  int64          `json:"finished_at,omitempty"`
+	TotalSteps  int            `json:"total_steps"`
+	Steps       []history.Step `json:"steps"`
+	Error       string         `json:"error,omitempty"`
+	CancelledBy string         `json:"cancelled_by,omitempty"`
+	Position    int            `json:"position,omitempty"`
+	// Coalesced counts the deploys requested while this one was queued,
+	// which it runs in their stead
+	Coalesced int `json:"coalesced,omitempty"`
+}
+
+// New creates a queued Job for an action on an instance at time now
+func New(action, playbookID, instanceID, actor string, now time.Time) *Job {
+	return &Job{
+		ID:         store.TimeKey(now),
+		Action:     action,
+		PlaybookID: playbookID,
+		InstanceID: instanceID,
+		Actor:      actor,
+		State:      StateQueued,
+		CreatedAt:  now.Unix(),
+		Steps:      []history.Step{},
+	}
+}
+
+// Start marks the job as running at time now
+func (j *Job) Start(now time.Time) {
+	j.State = StateRunning
+	j.StartedAt = now.Unix()
+}
+
+// Finish marks the job as done at time now with the final error, if any
+func (j *Job) Finish(err error, now time.Time) {
+	j.FinishedAt = now.Unix()
+	j.State = StateSucceeded
+	if err != nil {
+		j.State = StateFailed
+		j.Error = err.Error()
+	}
+}
+
+// Cancel marks the job as cancelled by actor at time now
+func (j *Job) Cancel(actor string, now time.Time) {
+	j.FinishedAt = now.Unix()
+	j.State = StateCancelled
+	j.CancelledBy = actor
+	j.Error = "cancelled by " + actor
+}
+
+// Done returns true if the job has finished, successfully or not
+func (j *Job) Done() bool {
+	return j.State == StateSucceeded || j.State == StateFailed || j.State == StateCancelled
+}
+
+// Save stores a job under the root path
+func Save(ctx context.Context, s store.Store, root string, j *Job) error {
+	encoded, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return s.SetValue(ctx, Path{RootPath: root, ID: j.ID}.String(), string(encoded))
+}
+
+// Find returns the job with the given id
+func Find(ctx context.Context, s store.Store, root, id string) (*Job, error) {
+	p := Path{RootPath: root, ID: id}
+	v, err := s.Value(ctx, p.String())
+	if err == store.ErrNotFound || (err == nil && v == "") {
+		return nil, NotFoundError(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &Job{}
+	if err := json.Unmarshal([]byte(v), j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// List returns every job under the root path, oldest first
+func List(ctx context.Context, s store.Store, root string) ([]*Job, error) {
+	values, err := s.Values(ctx, fmt.Sprintf("%s/jobs", root))
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*Job{}
+	for _, v := range values {
+		j := &Job{}
+		if err := json.Unmarshal([]byte(v), j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Sort(byID(jobs))
+	return jobs, nil
+}
+
+// Prune deletes the jobs that finished before the given time
+func Prune(ctx context.Context, s store.Store, root string, before time.Time) error {
+	jobs, err := List(ctx, s, root)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if !j.Done() || j.FinishedAt >= before.Unix() {
+			continue
+		}
+		if err := s.Delete(ctx, Path{RootPath: root, ID: j.ID}.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byID []*Job
+
+func (jj byID) Len() int           { return len(jj) }
+func (jj byID) Less(i, j int) bool { return jj[i].ID < jj[j].ID }
+func (jj byID) Swap(i, j int)      { jj[i], jj[j] = jj[j], jj[i] }
@@ -0,0 +1,87 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSaveAndFind(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	j := New(ActionDeploy, "hello", "test", "slack:bill", t0)
+	assert.Equal(t, StateQueued, j.State)
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", j))
+
+	found, err := Find(ctx, s, "/broadwaytest", j.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, j, found)
+
+	j.Start(t0.Add(time.Second))
+	assert.Equal(t, StateRunning, j.State)
+	assert.False(t, j.Done())
+	j.Finish(errors.New("boom"), t0.Add(time.Minute))
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", j))
+
+	found, err = Find(ctx, s, "/broadwaytest", j.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StateFailed, found.State)
+	assert.Equal(t, "boom", found.Error)
+	assert.Equal(t, t0.Add(time.Minute).Unix(), found.FinishedAt)
+	assert.True(t, found.Done())
+
+	_, err = Find(ctx, s, "/broadwaytest", "missing")
+	assert.Equal(t, NotFoundError("missing"), err)
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	old := New(ActionDeploy, "hello", "old", "api:token", t0)
+	old.Finish(nil, t0.Add(time.Minute))
+	recent := New(ActionStop, "hello", "recent", "api:token", t0.Add(time.Hour))
+	recent.Finish(nil, t0.Add(2*time.Hour))
+	running := New(ActionDeploy, "hello", "running", "api:token", t0.Add(-time.Hour))
+	running.Start(t0)
+	for _, j := range []*Job{old, recent, running} {
+		assert.Nil(t, Save(ctx, s, "/broadwaytest", j))
+	}
+
+	assert.Nil(t, Prune(ctx, s, "/broadwaytest", t0.Add(time.Hour)))
+	jobs, err := List(ctx, s, "/broadwaytest")
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, running.ID, jobs[0].ID, "unfinished jobs are kept, oldest first")
+	assert.Equal(t, recent.ID, jobs[1].ID)
+}
+
+func TestCancel(t *testing.T) {
+	t0 := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	j := New(ActionDeploy, "hello", "test", "api:token", t0)
+	j.Start(t0)
+	j.Cancel("slack:bill", t0.Add(time.Minute))
+	assert.Equal(t, StateCancelled, j.State)
+	assert.Equal(t, "slack:bill", j.CancelledBy)
+	assert.Equal(t, "cancelled by slack:bill", j.Error)
+	assert.True(t, j.Done())
+}
+
+func TestSameTimeJobs(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", New(ActionDeploy, "hello", "one", "api:token", now)))
+	assert.Nil(t, Save(ctx, s, "/broadwaytest", New(ActionDeploy, "hello", "two", "api:token", now)))
+	jobs, err := List(ctx, s, "/broadwaytest")
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2, "jobs started at the same time are all kept")
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of a deploy, stop or step
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+)
+
+var (
+	operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "operations_total",
+		Help:      "Deploys, stops and rollbacks run, by playbook, action and outcome.",
+	}, []string{"playbook", "action", "outcome"})
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "broadway",
+		Name:      "operation_duration_seconds",
+		Help:      "How long deploys, stops and rollbacks took, by playbook, action and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"playbook", "action", "outcome"})
+	stepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "broadway",
+		Name:      "step_duration_seconds",
+		Help:      "How long the manifest steps of deploys and stops took, by playbook, action, step and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"playbook", "action", "step", "outcome"})
+	instances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "broadway",
+		Name:      "instances",
+		Help:      "Instances, by playbook and status.",
+	}, []string{"playbook", "status"})
+	cleanupRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "cleanup_runs_total",
+		Help:      "Runs of the cleanup worker, by outcome.",
+	}, []string{"outcome"})
+	cleanupRemovals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "cleanup_removals_total",
+		Help:      "Instances stopped or deleted by the cleanup worker, by playbook, reason and action.",
+	}, []string{"playbook", "reason", "action"})
+	notificationFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "notification_failures_total",
+		Help:      "Slack notifications that failed to send.",
+	})
+	storeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "broadway",
+		Name:      "store_request_duration_seconds",
+		Help:      "How long store requests took, by operation.",
+	}, []string{"operation"})
+	storeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "store_errors_total",
+		Help:      "Store requests that failed, by operation.",
+	}, []string{"operation"})
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	slackCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "broadway",
+		Name:      "slack_commands_total",
+		Help:      "Slack commands run, by command and outcome.",
+	}, []string{"command", "outcome"})
+)
+
+func init() {
+	for _, c := range []prometheus.Collector{
+		operations, operationDuration, stepDuration, instances, cleanupRuns, cleanupRemovals,
+		notificationFailures, storeDuration, storeErrors, httpRequests, slackCommands,
+	} {
+		prometheus.MustRegister(c)
+	}
+}
+
+// Operation records a deploy, stop or rollback of a playbook's instance
+func Operation(playbook, action, outcome string, took time.Duration) {
+	operations.WithLabelValues(playbook, action, outcome).Inc()
+	operationDuration.WithLabelValues(playbook, action, outcome).Observe(took.Seconds())
+}
+
+// Step records a manifest step of a deploy, stop or rollback
+func Step(playbook, action, step, outcome string, took time.Duration) {
+	stepDuration.WithLabelValues(playbook, action, step, outcome).Observe(took.Seconds())
+}
+
+// InstanceCount counts the instances of a playbook with a status
+type InstanceCount struct {
+	Playbook string
+	Status   string
+	Count    int
+}
+
+// Instances replaces the instance counts with counts
+func Instances(counts []InstanceCount) {
+	instances.Reset()
+	for _, c := range counts {
+		instances.WithLabelValues(c.Playbook, c.Status).Set(float64(c.Count))
+	}
+}
+
+// CleanupRun records a run of the cleanup worker
+func CleanupRun(outcome string) {
+	cleanupRuns.WithLabelValues(outcome).Inc()
+}
+
+// CleanupRemoval records an instance stopped or deleted by the cleanup worker
+func CleanupRemoval(playbook, reason, action string) {
+	cleanupRemovals.WithLabelValues(playbook, reason, action).Inc()
+}
+
+// NotificationFailure records a notification that failed to send
+func NotificationFailure() {
+	notificationFailures.Inc()
+}
+
+// HTTPRequest records an HTTP request served
+func HTTPRequest(method, route string, code int) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+}
+
+// SlackCommand records a Slack command run
+func SlackCommand(command, outcome string) {
+	slackCommands.WithLabelValues(command, outcome).Inc()
+}
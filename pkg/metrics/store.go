@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// instrumentedStore records the latency and errors of a Store's requests
+type instrumentedStore struct {
+	store store.Store
+}
+
+// InstrumentStore returns a Store recording the latency and errors of the
+// requests to s
+func InstrumentStore(s store.Store) store.Store {
+	return &instrumentedStore{store: s}
+}
+
+// observe records a request for operation that started at started. Missing
+// keys aren't errors.
+func observe(operation string, started time.Time, err error) {
+	storeDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil && err != store.ErrNotFound {
+		storeErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (s *instrumentedStore) SetValue(ctx context.Context, path, value string) error {
+	started := time.Now()
+	err := s.store.SetValue(ctx, path, value)
+	observe("set", started, err)
+	return err
+}
+
+func (s *instrumentedStore) Value(ctx context.Context, path string) (string, error) {
+	started := time.Now()
+	v, err := s.store.Value(ctx, path)
+	observe("get", started, err)
+	return v, err
+}
+
+func (s *instrumentedStore) Values(ctx context.Context, path string) (map[string]string, error) {
+	started := time.Now()
+	vs, err := s.store.Values(ctx, path)
+	observe("list", started, err)
+	return vs, err
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, path string) error {
+	started := time.Now()
+	err := s.store.Delete(ctx, path)
+	observe("delete", started, err)
+	return err
+}
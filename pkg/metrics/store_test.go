@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/namely/broadway/pkg/store"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func storeErrorCount(t *testing.T, operation string) float64 {
+	m := &dto.Metric{}
+	if err := storeErrors.WithLabelValues(operation).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestInstrumentStore(t *testing.T) {
+	ctx := context.Background()
+	s := InstrumentStore(store.NewMemory())
+
+	assert.Nil(t, s.SetValue(ctx, "/a", "1"))
+	v, err := s.Value(ctx, "/a")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", v)
+	_, err = s.Value(ctx, "/missing")
+	assert.Equal(t, store.ErrNotFound, err)
+	assert.Zero(t, storeErrorCount(t, "get"), "missing keys aren't errors")
+
+	boom := errors.New("boom")
+	failing := InstrumentStore(&store.FakeStore{MockDelete: func(string) error { return boom }})
+	assert.Equal(t, boom, failing.Delete(ctx, "/a"))
+	assert.Equal(t, float64(1), storeErrorCount(t, "delete"))
+}
@@ -3,10 +3,12 @@ package notification
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/golang/glog"
 	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/metrics"
 )
 
 // Message represents a JSON payload sent to slack
@@ -15,7 +17,10 @@ type Message struct {
 	ResponseType string       `json:"response_type"`
 	Attachments  []Attachment `json:"attachments"`
 	Text         string       `json:"text"`
-	Cfg          cfg.Type
+	LinkNames    bool         `json:"link_names,omitempty"` // turns @names in Text into mentions
+	Channel      string       `json:"channel,omitempty"`    // overrides the webhook's channel
+	// Cfg is how the message is sent, never part of it: it holds credentials
+	Cfg cfg.Type `json:"-"`
 }
 
 // NewMessage crafts a new Slack message. If ephemeral is true, the message gets
@@ -73,5 +78,14 @@ func (message *Message) Send() error {
 	glog.Infof("Sending Slack message to %s", message.Cfg.SlackWebhook)
 	resp, err := http.Post(message.Cfg.SlackWebhook, "application/json", bytes.NewReader(value))
 	glog.Info("Slack returned: ", resp)
-	return err
+	if err != nil {
+		metrics.NotificationFailure()
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		metrics.NotificationFailure()
+		return fmt.Errorf("Slack returned %s", resp.Status)
+	}
+	return nil
 }
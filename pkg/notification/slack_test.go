@@ -37,3 +37,51 @@ func TestSend(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, requestBody, "successful")
 }
+
+func TestSendWithMentions(t *testing.T) {
+	requestBody := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Fatal("No request Body received")
+		}
+
+		requestBody = string(contents)
+		fmt.Fprintln(w, "")
+	}))
+	defer ts.Close()
+
+	message := NewMessage(cfg.Type{SlackWebhook: ts.URL}, false, "@bill, hello")
+	message.LinkNames = true
+	err := message.Send()
+	assert.Nil(t, err)
+	assert.Contains(t, requestBody, `"link_names":true`)
+}
+
+func TestSendWithoutCfg(t *testing.T) {
+	requestBody := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Fatal("No request Body received")
+		}
+
+		requestBody = string(contents)
+		fmt.Fprintln(w, "")
+	}))
+	defer ts.Close()
+
+	c := cfg.Type{
+		SlackWebhook:    ts.URL,
+		SlackToken:      "slacksecret",
+		AuthBearerToken: "apisecret",
+		MetricsToken:    "metricssecret",
+		Targets:         map[string]cfg.Target{"prod": {Token: "clustersecret", Password: "clusterpassword"}},
+	}
+	err := NewMessage(c, false, "hello").Send()
+	assert.Nil(t, err)
+	assert.Contains(t, requestBody, "hello")
+	for _, secret := range []string{"slacksecret", "apisecret", "metricssecret", "clustersecret", "clusterpassword"} {
+		assert.NotContains(t, requestBody, secret)
+	}
+}
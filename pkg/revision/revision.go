@@ -0,0 +1,125 @@
+package revision
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// NotFoundError revision not found error
+type NotFoundError string
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("broadway/revision: %s was not found", string(e))
+}
+
+// Path represents the store path holding the revisions of an instance
+type Path struct {
+	RootPath   string
+	PlaybookID string
+	InstanceID string
+}
+
+func (p Path) String() string {
+	return fmt.Sprintf("%s/revisions/%s/%s", p.RootPath, p.PlaybookID, p.InstanceID)
+}
+
+// Revision is a snapshot of what a successful deploy of an instance used
+type Revision struct {
+	Number       int               `json:"number"`
+	PlaybookID   string            `json:"playbook_id"`
+	InstanceID   string            `json:"instance_id"`
+	Vars         map[string]string `json:"vars"`
+	PlaybookHash string            `json:"playbook_hash"`
+	ManifestHash string            `json:"manifest_hash"`
+	DeployedAt   int64             `json:"deployed_at"`
+	Actor        string            `json:"actor"`
+}
+
+// Save stores a revision under the instance's revisions path
+func Save(ctx context.Context, s store.Store, p Path, r *Revision) error {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.SetValue(ctx, fmt.Sprintf("%s/%d", p, r.Number), string(encoded))
+}
+
+// Find returns revision number n of an instance
+func Find(ctx context.Context, s store.Store, p Path, n int) (*Revision, error) {
+	key := fmt.Sprintf("%s/%d", p, n)
+	v, err := s.Value(ctx, key)
+	if err == store.ErrNotFound {
+		return nil, NotFoundError(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &Revision{}
+	if err := json.Unmarshal([]byte(v), r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns all stored revisions of an instance, newest first
+func List(ctx context.Context, s store.Store, p Path) ([]*Revision, error) {
+	values, err := s.Values(ctx, p.String())
+	if err != nil {
+		return nil, err
+	}
+	revisions := []*Revision{}
+	for k, v := range values {
+		if _, err := strconv.Atoi(k); err != nil {
+			continue
+		}
+		r := &Revision{}
+		if err := json.Unmarshal([]byte(v), r); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	sort.Sort(sort.Reverse(byNumber(revisions)))
+	return revisions, nil
+}
+
+// Latest returns the number of the newest stored revision, or 0 if there is
+// none
+func Latest(ctx context.Context, s store.Store, p Path) (int, error) {
+	revisions, err := List(ctx, s, p)
+	if err != nil || len(revisions) == 0 {
+		return 0, err
+	}
+	return revisions[0].Number, nil
+}
+
+// Prune deletes all but the newest keep revisions of an instance. A keep of
+// zero or less retains everything.
+func Prune(ctx context.Context, s store.Store, p Path, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	revisions, err := List(ctx, s, p)
+	if err != nil {
+		return err
+	}
+	if len(revisions) <= keep {
+		return nil
+	}
+	for _, r := range revisions[keep:] {
+		if err := s.Delete(ctx, fmt.Sprintf("%s/%d", p, r.Number)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byNumber []*Revision
+
+func (rr byNumber) Len() int           { return len(rr) }
+func (rr byNumber) Less(i, j int) bool { return rr[i].Number < rr[j].Number }
+func (rr byNumber) Swap(i, j int)      { rr[i], rr[j] = rr[j], rr[i] }
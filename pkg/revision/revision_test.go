@@ -0,0 +1,42 @@
+package revision
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSaveFindAndList(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	p := Path{RootPath: "/broadwaytest", PlaybookID: "hello", InstanceID: "test"}
+
+	n, err := Latest(ctx, s, p)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	for k := 1; k <= 11; k++ {
+		r := &Revision{Number: k, PlaybookID: "hello", InstanceID: "test", Vars: map[string]string{"version": strconv.Itoa(k)}}
+		assert.Nil(t, Save(ctx, s, p, r))
+	}
+
+	n, err = Latest(ctx, s, p)
+	assert.Nil(t, err)
+	assert.Equal(t, 11, n, "revisions are ordered numerically")
+
+	r, err := Find(ctx, s, p, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", r.Vars["version"])
+
+	_, err = Find(ctx, s, p, 12)
+	assert.Equal(t, NotFoundError("/broadwaytest/revisions/hello/test/12"), err)
+
+	assert.Nil(t, Prune(ctx, s, p, 3))
+	revisions, err := List(ctx, s, p)
+	assert.Nil(t, err)
+	assert.Len(t, revisions, 3)
+	assert.Equal(t, 9, revisions[2].Number)
+}
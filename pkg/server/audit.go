@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/audit"
+	"github.com/namely/broadway/pkg/services"
+)
+
+// getAudit returns the audit log entries matching the query parameters,
+// newest first, as a JSON array or as JSON Lines with format=jsonl
+func (s *Server) getAudit(c *gin.Context) {
+	values := c.Request.URL.Query()
+	format := values.Get("format")
+	values.Del("format")
+	if format != "" && format != "json" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, CustomError("format must be json or jsonl"))
+		return
+	}
+	q, err := services.ParseAuditQuery(values)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, CustomError(err.Error()))
+		return
+	}
+
+	entries, err := services.NewInstanceService(s.Cfg, s.store).Audit(c.Request.Context(), q)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if format != "jsonl" {
+		c.JSON(http.StatusOK, entries)
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if err := audit.WriteJSONLines(c.Writer, entries); err != nil {
+		glog.Errorf("Failed to export the audit log: %s", err)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/services"
+)
+
+// getHealthz tells Kubernetes Broadway is alive: it answers as long as it
+// serves requests
+func (s *Server) getHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// getReadyz tells Kubernetes whether Broadway can serve requests, returning
+// 503 along with the failed checks when it can't
+func (s *Server) getReadyz(c *gin.Context) {
+	checks := []services.Check{{Name: "init", Error: "the server isn't initialized"}}
+	if s.ds != nil {
+		checks = s.ds.Readiness(c.Request.Context())
+	}
+	code := http.StatusOK
+	if !services.Ready(checks) {
+		glog.Errorf("Not ready: %+v", checks)
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, map[string]interface{}{
+		"ready":  code == http.StatusOK,
+		"checks": checks,
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/services"
+	"golang.org/x/net/context"
+)
+
+func (s *Server) getLogs(c *gin.Context) {
+	opts := deployment.LogOptions{Container: c.Query("container"), Follow: c.Query("follow") == "true"}
+	if tail := c.Query("tail"); tail != "" {
+		n, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, CustomError("tail must be a number of lines"))
+			return
+		}
+		opts.TailLines = n
+	}
+
+	ctx := c.Request.Context()
+	is := services.NewInstanceService(s.Cfg, s.store)
+	i, err := is.Show(ctx, c.Param("playbookID"), c.Param("instanceID"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	logs, err := s.ds.Logs(ctx, i, opts)
+	if err != nil {
+		glog.Error(err)
+		if _, ok := err.(*services.NoLogs); ok {
+			c.JSON(http.StatusNotFound, CustomError(err.Error()))
+			return
+		}
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	copyLogs(ctx, c.Writer, logs, opts.Follow)
+}
+
+// copyLogs writes the lines of logs to w, prefixed with their pod and
+// container when there are several. Followed logs are read at once and each
+// line is flushed as it comes, otherwise they're written one after the other.
+// The logs are closed once they end or ctx is done.
+func copyLogs(ctx context.Context, w gin.ResponseWriter, logs []deployment.PodLog, follow bool) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		for _, l := range logs {
+			l.Stream.Close()
+		}
+	}()
+
+	lines := make(chan string)
+	read := func(l deployment.PodLog) {
+		scanner := bufio.NewScanner(l.Stream)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if len(logs) > 1 {
+				line = fmt.Sprintf("[%s/%s] %s", l.Pod, l.Container, line)
+			}
+			select {
+			case lines <- line:
+			case <-done:
+				return
+			}
+		}
+	}
+	go func() {
+		defer close(lines)
+		if !follow {
+			for _, l := range logs {
+				read(l)
+			}
+			return
+		}
+		var wg sync.WaitGroup
+		for _, l := range logs {
+			wg.Add(1)
+			go func(l deployment.PodLog) {
+				defer wg.Done()
+				read(l)
+			}(l)
+		}
+		wg.Wait()
+	}()
+
+	for line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return
+		}
+		if follow {
+			w.Flush()
+		}
+	}
+}
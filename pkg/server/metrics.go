@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/metrics"
+	"github.com/namely/broadway/pkg/services"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unroutedKey marks requests no route matched
+const unroutedKey = "unrouted"
+
+// metricsHandler serves the registered metrics
+var metricsHandler = prometheus.Handler()
+
+// countRequests records every request once it's served
+func countRequests(c *gin.Context) {
+	c.Next()
+	route := "unrouted"
+	if _, ok := c.Get(unroutedKey); !ok {
+		route = routeOf(c)
+	}
+	metrics.HTTPRequest(c.Request.Method, route, c.Writer.Status())
+}
+
+func unrouted(c *gin.Context) {
+	c.Set(unroutedKey, true)
+}
+
+// routeOf returns the route that served a request, putting the names of its
+// params back in place of their values
+func routeOf(c *gin.Context) string {
+	segments := strings.Split(c.Request.URL.Path, "/")
+	params := c.Params
+	for i, segment := range segments {
+		if len(params) > 0 && segment == params[0].Value {
+			segments[i] = ":" + params[0].Key
+			params = params[1:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// countCommand records a Slack command that ran with err
+func countCommand(command services.SlackCommand, err error) {
+	// *services.deployCommand is counted as deploy:
+	name := strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", command), "*services."), "Command")
+	outcome := metrics.OutcomeSucceeded
+	if err != nil {
+		outcome = metrics.OutcomeFailed
+	}
+	metrics.SlackCommand(name, outcome)
+}
+
+// metricsAuth requires the metrics token, if one is configured
+func (s *Server) metricsAuth(c *gin.Context) {
+	if s.Cfg.MetricsToken == "" {
+		return
+	}
+	a := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+	if a != s.Cfg.MetricsToken {
+		c.String(http.StatusUnauthorized, "Wrong or Missing Authorization")
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+func (s *Server) getMetrics(c *gin.Context) {
+	is := services.NewInstanceService(s.Cfg, s.store)
+	counts, err := is.Counts(c.Request.Context())
+	if err != nil {
+		// The other metrics are still worth serving:
+		glog.Errorf("Failed to count instances for metrics: %s", err)
+	} else {
+		metrics.Instances(counts)
+	}
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
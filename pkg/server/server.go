@@ -2,17 +2,21 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
 	"github.com/namely/broadway/pkg/notification"
+	"github.com/namely/broadway/pkg/revision"
 	"github.com/namely/broadway/pkg/services"
 	"github.com/namely/broadway/pkg/store"
-	"github.com/namely/broadway/pkg/store/etcdstore"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -24,9 +28,16 @@ type Server struct {
 	slackToken string
 	playbooks  map[string]*deployment.Playbook
 	manifests  map[string]*deployment.Manifest
-	deployer   deployment.Deployer
+	ds         *services.DeploymentService
+	jobs       *services.JobService
 	engine     *gin.Engine
 	Cfg        cfg.Type
+	// Deployers replaces the factory of the deployment service's deployers
+	// if it's set before Init
+	Deployers deployment.Factory
+
+	stop    chan struct{}
+	workers sync.WaitGroup
 }
 
 // ErrorResponse represents a JSON response to be returned in failure cases
@@ -41,6 +52,8 @@ var (
 	NotFoundError = ErrorResponse{"error": "Not Found"}
 	// InternalError represents a JSON response for status 500
 	InternalError = ErrorResponse{"error": "Internal Server Error"}
+	// UnavailableError represents a JSON response for status 503
+	UnavailableError = ErrorResponse{"error": "Service Unavailable"}
 )
 
 // CustomError creates an ErrorResponse with a custom message
@@ -48,6 +61,19 @@ func CustomError(message string) ErrorResponse {
 	return ErrorResponse{"error": message}
 }
 
+// respondWithError maps errors returned by the services to a JSON response:
+// missing instances and jobs become 404s and an unreachable store becomes a 503
+func respondWithError(c *gin.Context, err error) {
+	switch err.(type) {
+	case instance.NotFoundError, job.NotFoundError:
+		c.JSON(http.StatusNotFound, NotFoundError)
+	case *store.UnavailableError:
+		c.JSON(http.StatusServiceUnavailable, UnavailableError)
+	default:
+		c.JSON(http.StatusInternalServerError, InternalError)
+	}
+}
+
 // New instantiates a new Server and binds its handlers. The Server will look
 // for playbooks and instances in store `s`
 func New(cfg cfg.Type, s store.Store) *Server {
@@ -60,7 +86,8 @@ func New(cfg cfg.Type, s store.Store) *Server {
 	return srvr
 }
 
-// Init initializes manifests and playbooks for the server.
+// Init initializes manifests and playbooks for the server, and starts its
+// background workers, which run until Close.
 func (s *Server) Init() {
 	var err error
 	s.manifests, err = deployment.LoadManifestFolder(s.Cfg.ManifestsPath, s.Cfg.ManifestsExtension)
@@ -70,21 +97,29 @@ func (s *Server) Init() {
 
 	s.playbooks = deployment.AllPlaybooks
 	glog.Infof("Server Playbooks: %+v", s.playbooks)
+	if err := services.ValidateTargets(s.Cfg, s.playbooks); err != nil {
+		glog.Fatal(err)
+	}
 
-	glog.Info("Initialize deployed instances cleanup worker")
 	ds := services.NewDeploymentService(s.Cfg, s.store, s.playbooks, s.manifests)
-	go func() {
-		for {
-			time.Sleep(time.Second * time.Duration(s.Cfg.InstanceCleanup))
-			ds.RemoveExpiredInstances(time.Now())
-		}
-	}()
+	if s.Deployers != nil {
+		ds.Deployers = s.Deployers
+	}
+	// Handlers share the deployment service, and with it the Kubernetes client:
+	s.ds = ds
+	s.jobs = services.NewJobService(ds)
+	s.startWorkers(s.Cfg)
 }
 
 func (s *Server) setupHandlers() {
 	s.engine = gin.Default()
 	gin.SetMode(gin.ReleaseMode) // Comment this to use debug mode for more verbose output
+	s.engine.Use(countRequests)
+	s.engine.NoRoute(unrouted)
 	// Define routes:
+	s.engine.GET("/metrics", s.metricsAuth, s.getMetrics)
+	s.engine.GET("/healthz", s.getHealthz)
+	s.engine.GET("/readyz", s.getReadyz)
 	s.engine.POST("/command", s.postCommand)
 	s.engine.GET("/command", s.getCommand)
 	// Protect subsequent routes with middleware:
@@ -92,10 +127,26 @@ func (s *Server) setupHandlers() {
 	s.engine.GET("/", s.home)
 	s.engine.POST("/instances", s.createInstance)
 	s.engine.GET("/instance/:playbookID/:instanceID", s.getInstance)
+	s.engine.GET("/instances", s.queryInstances)
+	s.engine.GET("/playbooks", s.getPlaybooks)
+	s.engine.GET("/cleanup", s.getCleanup)
+	s.engine.GET("/playbooks/:playbookID", s.getPlaybook)
 	s.engine.GET("/instances/:playbookID", s.getInstances)
 	s.engine.GET("/status/:playbookID/:instanceID", s.getStatus)
 	s.engine.POST("/deploy/:playbookID/:instanceID", s.deployInstance)
+	s.engine.GET("/jobs/:jobID", s.getJob)
+	s.engine.DELETE("/jobs/:jobID", s.cancelJob)
+	s.engine.POST("/cancel/:playbookID/:instanceID", s.cancelInstance)
+	s.engine.GET("/queue", s.getQueue)
+	s.engine.GET("/audit", s.getAudit)
+	s.engine.POST("/rollback/:playbookID/:instanceID", s.rollbackInstance)
+	s.engine.POST("/extend/:playbookID/:instanceID", s.extendInstance)
+	s.engine.POST("/pin/:playbookID/:instanceID", s.pinInstance(true))
+	s.engine.POST("/unpin/:playbookID/:instanceID", s.pinInstance(false))
 	s.engine.DELETE("/instances/:playbookID/:instanceID", s.deleteInstance)
+	s.engine.GET("/instances/:playbookID/:instanceID/history", s.getHistory)
+	s.engine.GET("/instances/:playbookID/:instanceID/logs", s.getLogs)
+	s.engine.POST("/instances/:playbookID/:instanceID/clone", s.cloneInstance)
 }
 
 // Handler returns a reference to the Gin engine that powers Server
@@ -118,6 +169,9 @@ func (s *Server) genAuthMiddleware() func(c *gin.Context) {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		// There is a single shared token, so every API request acts as it
+		api := actor.Actor{Source: actor.SourceAPI, Name: "token"}
+		c.Request = c.Request.WithContext(actor.NewContext(c.Request.Context(), api))
 		c.Next()
 	}
 }
@@ -134,12 +188,19 @@ func (s *Server) createInstance(c *gin.Context) {
 		return
 	}
 
-	service := services.NewInstanceService(s.Cfg, etcdstore.New())
-	i, err := service.CreateOrUpdate(i)
+	service := services.NewInstanceService(s.Cfg, s.store)
+	i, err := service.CreateOrUpdate(c.Request.Context(), i)
 
 	if err != nil {
 		glog.Error(err)
-		c.JSON(http.StatusInternalServerError, InternalError)
+		switch err.(type) {
+		case *services.UnknownTarget:
+			c.JSON(http.StatusBadRequest, CustomError(err.Error()))
+		case *services.TargetInUse:
+			c.JSON(http.StatusConflict, CustomError(err.Error()))
+		default:
+			respondWithError(c, err)
+		}
 		return
 	}
 
@@ -148,49 +209,152 @@ func (s *Server) createInstance(c *gin.Context) {
 
 func (s *Server) getInstance(c *gin.Context) {
 	service := services.NewInstanceService(s.Cfg, s.store)
-	i, err := service.Show(c.Param("playbookID"), c.Param("instanceID"))
+	i, err := service.Show(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"))
 
 	if err != nil {
-		switch err.(type) {
-		case instance.NotFoundError:
-			c.JSON(http.StatusNotFound, NotFoundError)
-			return
-		default:
-			c.JSON(http.StatusInternalServerError, InternalError)
-			return
-		}
+		respondWithError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, i)
 }
 
 func (s *Server) getInstances(c *gin.Context) {
 	service := services.NewInstanceService(s.Cfg, s.store)
-	instances, err := service.AllWithPlaybookID(c.Param("playbookID"))
+	instances, err := service.AllWithPlaybookID(c.Request.Context(), c.Param("playbookID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, InternalError)
+		respondWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, instances)
 	return
 }
 
-func (s *Server) getStatus(c *gin.Context) {
+func (s *Server) queryInstances(c *gin.Context) {
+	q, err := services.ParseQuery(c.Request.URL.Query(), s.playbooks)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, CustomError(err.Error()))
+		return
+	}
+
 	service := services.NewInstanceService(s.Cfg, s.store)
-	i, err := service.Show(c.Param("playbookID"), c.Param("instanceID"))
+	instances, total, err := service.Query(c.Request.Context(), q)
+	if err != nil {
+		if _, ok := err.(instance.InvalidSort); ok {
+			c.JSON(http.StatusBadRequest, CustomError(err.Error()))
+			return
+		}
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"instances": instances,
+		"total":     total,
+		"offset":    q.Offset,
+		"limit":     q.Limit,
+	})
+}
 
+func (s *Server) getPlaybooks(c *gin.Context) {
+	service := services.NewPlaybookService(s.Cfg, s.store, s.playbooks)
+	playbooks, err := service.All(c.Request.Context())
 	if err != nil {
-		switch err.(type) {
-		case instance.NotFoundError:
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, playbooks)
+}
+
+func (s *Server) getPlaybook(c *gin.Context) {
+	service := services.NewPlaybookService(s.Cfg, s.store, s.playbooks)
+	playbook, err := service.Show(c.Request.Context(), c.Param("playbookID"))
+	if err != nil {
+		if _, ok := err.(*services.PlaybookNotFound); ok {
 			c.JSON(http.StatusNotFound, NotFoundError)
 			return
+		}
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, playbook)
+}
+
+// getCleanup reports what the cleanup worker would do right now without doing
+// it
+func (s *Server) getCleanup(c *gin.Context) {
+	actions, err := s.ds.Cleanup(c.Request.Context(), time.Now(), true)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, actions)
+}
+
+// getStatus reports the stored status of an instance along with the live
+// state of the objects deployed for it
+func (s *Server) getStatus(c *gin.Context) {
+	service := services.NewInstanceService(s.Cfg, s.store)
+	i, err := service.Show(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"))
+
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	response := map[string]interface{}{
+		"status":     string(i.Status),
+		"last_error": i.LastError,
+		"timestamps": i.Timestamps,
+	}
+	// The stored status is still reported when the cluster can't be read
+	live, err := s.ds.LiveStatus(c.Request.Context(), i)
+	if err != nil {
+		glog.Errorf("Failed to read the live status of %s/%s: %s", i.PlaybookID, i.ID, err)
+		response["live_error"] = err.Error()
+	} else {
+		response["live"] = live
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// cloneRequest is the body of a clone request
+type cloneRequest struct {
+	ID   string            `json:"id" binding:"required"`
+	Vars map[string]string `json:"vars"`
+}
+
+func (s *Server) cloneInstance(c *gin.Context) {
+	req := new(cloneRequest)
+	if err := c.BindJSON(req); err != nil {
+		glog.Error(err)
+		c.JSON(http.StatusBadRequest, CustomError("Missing: "+err.Error()))
+		return
+	}
+
+	service := services.NewInstanceService(s.Cfg, s.store)
+	i, err := service.Clone(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"), req.ID, req.Vars)
+	if err != nil {
+		glog.Error(err)
+		switch err.(type) {
+		case *services.InvalidID, *services.InvalidVar:
+			c.JSON(http.StatusBadRequest, CustomError(err.Error()))
+		case *services.InstanceExists:
+			c.JSON(http.StatusConflict, CustomError(err.Error()))
 		default:
-			c.JSON(http.StatusInternalServerError, InternalError)
-			return
+			respondWithError(c, err)
 		}
+		return
 	}
-	c.JSON(http.StatusOK, map[string]string{
-		"status": string(i.Status),
-	})
+
+	c.JSON(http.StatusCreated, i)
+}
+
+func (s *Server) getHistory(c *gin.Context) {
+	service := services.NewInstanceService(s.Cfg, s.store)
+	records, err := service.History(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
 }
 
 func (s *Server) getCommand(c *gin.Context) {
@@ -232,11 +396,12 @@ func (s *Server) postCommand(c *gin.Context) {
 	}
 
 	is := services.NewInstanceService(s.Cfg, s.store)
-	ds := services.NewDeploymentService(s.Cfg, etcdstore.New(), s.playbooks, s.manifests)
 
-	slackCommand := services.BuildSlackCommand(s.Cfg, form.Text, ds, is, s.playbooks)
+	slackCommand := services.BuildSlackCommand(s.Cfg, form.Text, s.jobs, is, s.playbooks)
 	glog.Infof("Running command: %s", form.Text)
-	msg, err := slackCommand.Execute()
+	a := actor.Actor{Source: actor.SourceSlack, Name: form.UserName}
+	msg, err := slackCommand.Execute(actor.NewContext(c.Request.Context(), a))
+	countCommand(slackCommand, err)
 	if err != nil {
 		c.JSON(http.StatusOK, err)
 		return
@@ -248,61 +413,154 @@ func (s *Server) postCommand(c *gin.Context) {
 	return
 }
 
-func deploy(s *Server, pID string, ID string) (*instance.Instance, error) {
+// respondWithJobError maps errors starting or cancelling a job to a JSON
+// response: an instance that can't make the job's status transition or a job
+// that can't be cancelled becomes a 409, and an instance without jobs to
+// cancel a 404
+func respondWithJobError(c *gin.Context, err error) {
+	glog.Error(err)
+	switch err.(type) {
+	case *instance.InvalidTransition, *services.JobNotCancellable:
+		c.JSON(http.StatusConflict, CustomError(err.Error()))
+	case *services.NoJobs:
+		c.JSON(http.StatusNotFound, CustomError(err.Error()))
+	default:
+		respondWithError(c, err)
+	}
+}
+
+func (s *Server) deployInstance(c *gin.Context) {
+	ctx := c.Request.Context()
 	is := services.NewInstanceService(s.Cfg, s.store)
-	i, err := is.Show(pID, ID)
+	i, err := is.Show(ctx, c.Param("playbookID"), c.Param("instanceID"))
 	if err != nil {
-		return nil, err
+		respondWithError(c, err)
+		return
 	}
 
-	ds := services.NewDeploymentService(s.Cfg, s.store, s.playbooks, s.manifests)
+	j, err := s.jobs.Deploy(ctx, i)
+	if err != nil {
+		respondWithJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, j)
+}
+
+func (s *Server) getQueue(c *gin.Context) {
+	running, queued := s.jobs.Queue()
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"running": running,
+		"queued":  queued,
+		"depth":   len(queued),
+	})
+}
 
-	err = ds.DeployAndNotify(i)
+func (s *Server) getJob(c *gin.Context) {
+	j, err := s.jobs.Show(c.Request.Context(), c.Param("jobID"))
 	if err != nil {
-		return nil, err
+		respondWithError(c, err)
+		return
 	}
-	return i, nil
+	c.JSON(http.StatusOK, j)
 }
 
-func (s *Server) deployInstance(c *gin.Context) {
-	i, err := deploy(s, c.Param("playbookID"), c.Param("instanceID"))
+func (s *Server) cancelJob(c *gin.Context) {
+	j, err := s.jobs.Cancel(c.Request.Context(), c.Param("jobID"))
 	if err != nil {
-		glog.Error(err)
-		switch err.(type) {
-		case instance.NotFoundError:
-			c.JSON(http.StatusNotFound, NotFoundError)
+		respondWithJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, j)
+}
+
+func (s *Server) cancelInstance(c *gin.Context) {
+	jobs, err := s.jobs.CancelInstance(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"))
+	if err != nil {
+		respondWithJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, jobs)
+}
+
+func (s *Server) rollbackInstance(c *gin.Context) {
+	n := 0
+	if r := c.Query("revision"); r != "" {
+		var err error
+		n, err = strconv.Atoi(r)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, CustomError("revision must be a positive number"))
 			return
-		default:
-			c.JSON(http.StatusInternalServerError, InternalError)
+		}
+	}
+
+	ctx := c.Request.Context()
+	is := services.NewInstanceService(s.Cfg, s.store)
+	i, err := is.Show(ctx, c.Param("playbookID"), c.Param("instanceID"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	j, err := s.jobs.Rollback(ctx, i, n)
+	if err != nil {
+		if _, ok := err.(revision.NotFoundError); ok {
+			glog.Error(err)
+			c.JSON(http.StatusNotFound, CustomError(err.Error()))
 			return
 		}
+		if err == services.ErrNoPreviousRevision {
+			glog.Error(err)
+			c.JSON(http.StatusConflict, CustomError(err.Error()))
+			return
+		}
+		respondWithJobError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, i)
+	c.JSON(http.StatusAccepted, j)
 }
 
-func (s *Server) deleteInstance(c *gin.Context) {
+func (s *Server) extendInstance(c *gin.Context) {
+	d, err := instance.ParseLifetime(c.Query("by"))
+	if err != nil || d <= 0 {
+		c.JSON(http.StatusBadRequest, CustomError("by must be a lifetime such as 3d or 12h"))
+		return
+	}
 	is := services.NewInstanceService(s.Cfg, s.store)
-
-	i, err := is.Show(c.Param("playbookID"), c.Param("instanceID"))
+	i, err := is.Extend(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"), d)
 	if err != nil {
-		glog.Errorf("Failed to get instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-		c.JSON(http.StatusNotFound, NotFoundError)
+		respondWithError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, i)
+}
 
-	ds := services.NewDeploymentService(s.Cfg, s.store, s.playbooks, s.manifests)
+func (s *Server) pinInstance(pinned bool) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		is := services.NewInstanceService(s.Cfg, s.store)
+		i, err := is.SetPinned(c.Request.Context(), c.Param("playbookID"), c.Param("instanceID"), pinned)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, i)
+	}
+}
 
-	if err := ds.StopAndNotify(i); err != nil {
-		glog.Errorf("Failed to delete instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-		c.JSON(http.StatusInternalServerError, InternalError)
+func (s *Server) deleteInstance(c *gin.Context) {
+	is := services.NewInstanceService(s.Cfg, s.store)
+
+	ctx := c.Request.Context()
+	i, err := is.Show(ctx, c.Param("playbookID"), c.Param("instanceID"))
+	if err != nil {
+		glog.Errorf("Failed to get instance %s/%s:\n%s\n", c.Param("playbookID"), c.Param("instanceID"), err)
+		respondWithError(c, err)
 		return
 	}
 
-	if err := is.Delete(i); err != nil {
-		glog.Errorf("Failed to delete instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-		c.JSON(http.StatusInternalServerError, InternalError)
+	j, err := s.jobs.Delete(ctx, i)
+	if err != nil {
+		respondWithJobError(c, err)
 		return
 	}
-
-	c.JSON(http.StatusOK, map[string]string{"message": "Instance successfully deleted"})
+	c.JSON(http.StatusAccepted, j)
 }
@@ -8,16 +8,23 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/namely/broadway/pkg/audit"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"github.com/namely/broadway/pkg/revision"
 	"github.com/namely/broadway/pkg/services"
+	"github.com/namely/broadway/pkg/store"
 	"github.com/namely/broadway/pkg/store/etcdstore"
 	"github.com/namely/broadway/pkg/testutils"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 var testToken = "BroadwayTestToken"
@@ -30,7 +37,6 @@ var testCfg = cfg.Type{
 	PlaybooksPath:      "../../examples/playbooks",
 	EtcdEndpoints:      "http://localhost:4001",
 	EtcdPath:           "/broadwaytest",
-	InstanceCleanup:    15,
 }
 
 func init() {
@@ -86,6 +92,35 @@ func TestInstanceCreateWithValidAttributes(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code, "Response code should be 201")
 }
 
+func TestCloneInstance(t *testing.T) {
+	st := etcdstore.New()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCloneInstance", Vars: map[string]string{"word": "gorilla"}}
+	service := services.NewInstanceService(testutils.TestCfg, st)
+	if _, err := service.CreateOrUpdate(context.Background(), i); err != nil {
+		t.Fatal(err)
+	}
+
+	rbody := testutils.JSONFromMap(t, map[string]interface{}{
+		"id":   "TestCloneInstance-copy",
+		"vars": map[string]string{"word": "orangutan"},
+	})
+	req, w := testutils.PostRequest(t, "/instances/helloplaybook/TestCloneInstance/clone", rbody)
+	req = auth(testCfg, req)
+	server := New(testCfg, st)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusCreated, w.Code, "Response code should be 201")
+
+	var clone instance.Instance
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &clone))
+	assert.Equal(t, "TestCloneInstance-copy", clone.ID)
+	assert.Equal(t, "orangutan", clone.Vars["word"])
+
+	req, w = testutils.PostRequest(t, "/instances/helloplaybook/TestCloneInstance/clone", rbody)
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusConflict, w.Code, "Cloning onto an existing instance should be 409")
+}
+
 func TestCreateInstanceWithInvalidAttributes(t *testing.T) {
 	invalidRequests := map[string]map[string]interface{}{
 		"playbook_id": {
@@ -111,7 +146,7 @@ func TestGetInstanceWithValidPath(t *testing.T) {
 	st := etcdstore.New()
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestGetInstanceWithValidPath"}
 	service := services.NewInstanceService(testutils.TestCfg, st)
-	_, err := service.CreateOrUpdate(i)
+	_, err := service.CreateOrUpdate(context.Background(), i)
 	if err != nil {
 		t.Log(err.Error())
 	}
@@ -137,8 +172,8 @@ func TestGetInstancesWithFullPlaybook(t *testing.T) {
 	testInstance1 := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestGetInstancesWithFullPlaybook1"}
 	testInstance2 := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestGetInstancesWithFullPlaybook2"}
 	service := services.NewInstanceService(testutils.TestCfg, etcdstore.New())
-	_, err := service.CreateOrUpdate(testInstance1)
-	_, err = service.CreateOrUpdate(testInstance2)
+	_, err := service.CreateOrUpdate(context.Background(), testInstance1)
+	_, err = service.CreateOrUpdate(context.Background(), testInstance2)
 	if err != nil {
 		t.Log(err.Error())
 	}
@@ -151,6 +186,90 @@ func TestGetInstancesWithFullPlaybook(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code, "Response code should be 200 OK")
 }
 
+func TestQueryInstances(t *testing.T) {
+	st := store.NewMemory()
+	for _, i := range []*instance.Instance{
+		{PlaybookID: "helloplaybook", ID: "TestQueryInstances1", Status: instance.StatusError},
+		{PlaybookID: "helloplaybook", ID: "TestQueryInstances2", Status: instance.StatusDeployed},
+	} {
+		i.Path = instance.Path{testCfg.EtcdPath, i.PlaybookID, i.ID}
+		if err := instance.Save(context.Background(), st, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := New(testCfg, st)
+
+	req, w := testutils.GetRequest(t, "/instances?status=error")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Instances []instance.Instance `json:"instances"`
+		Total     int                 `json:"total"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Total)
+	if assert.Len(t, response.Instances, 1) {
+		assert.Equal(t, "TestQueryInstances1", response.Instances[0].ID)
+	}
+
+	req, w = testutils.GetRequest(t, "/instances?sort=color")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetPlaybooks(t *testing.T) {
+	server := New(testCfg, store.NewMemory())
+
+	req, w := testutils.GetRequest(t, "/playbooks")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"helloplaybook"`)
+
+	req, w = testutils.GetRequest(t, "/playbooks/helloplaybook")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var playbook services.PlaybookInfo
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &playbook))
+	assert.Equal(t, []string{"word", "bird"}, playbook.Vars)
+
+	req, w = testutils.GetRequest(t, "/playbooks/vanished")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetCleanup(t *testing.T) {
+	st := store.NewMemory()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestGetCleanup", Status: instance.StatusDeployed, ExpiredAt: 10}
+	i.Path = instance.Path{RootPath: testCfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+	if err := instance.Save(context.Background(), st, i); err != nil {
+		t.Fatal(err)
+	}
+	server := New(testCfg, st)
+
+	req, w := testutils.GetRequest(t, "/cleanup")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var actions []services.CleanupAction
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &actions))
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, services.CleanupExpired, actions[0].Reason)
+		assert.True(t, actions[0].Stop)
+	}
+
+	ii, err := instance.FindByPath(context.Background(), st, i.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusDeployed, ii.Status, "the report doesn't stop anything")
+}
+
 func TestGetStatusFailures(t *testing.T) {
 	invalidRequests := []struct {
 		method  string
@@ -188,7 +307,7 @@ func TestGetStatusWithGoodPath(t *testing.T) {
 		ID:         "TestGetStatusWithGoodPath",
 		Status:     instance.StatusDeployed}
 	is := services.NewInstanceService(testutils.TestCfg, etcdstore.New())
-	_, err := is.CreateOrUpdate(testInstance1)
+	_, err := is.CreateOrUpdate(context.Background(), testInstance1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,11 +318,110 @@ func TestGetStatusWithGoodPath(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var statusResponse map[string]string
+	var statusResponse map[string]interface{}
 
 	err = json.Unmarshal(w.Body.Bytes(), &statusResponse)
 	assert.Nil(t, err)
-	assert.Contains(t, statusResponse["status"], "deployed")
+	assert.Equal(t, "deployed", statusResponse["status"])
+	assert.Contains(t, statusResponse, "timestamps")
+}
+
+func TestGetStatusLive(t *testing.T) {
+	s := store.NewMemory()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "live", Status: instance.StatusDeployed, Path: instance.Path{testCfg.EtcdPath, "helloplaybook", "live"}}
+	assert.Nil(t, instance.Save(context.Background(), s, i))
+	server := New(testCfg, s)
+	server.Deployers = deployment.NewFake().Factory
+
+	req, w := testutils.GetRequest(t, "/status/helloplaybook/live")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var statusResponse struct {
+		Status string                    `json:"status"`
+		Live   []deployment.ObjectStatus `json:"live"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &statusResponse))
+	assert.Equal(t, "deployed", statusResponse.Status)
+	if assert.Len(t, statusResponse.Live, 1) {
+		assert.Equal(t, "hello", statusResponse.Live[0].Manifest)
+		assert.True(t, statusResponse.Live[0].Missing, "the fake deployed nothing yet")
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	config := testCfg
+	config.Deployer = "fake"
+	server := New(config, store.NewMemory())
+
+	req, w := testutils.GetRequest(t, "/healthz")
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code, "liveness doesn't take the token")
+
+	req, w = testutils.GetRequest(t, "/readyz")
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code, "readiness doesn't take the token")
+	var readiness struct {
+		Ready  bool             `json:"ready"`
+		Checks []services.Check `json:"checks"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+	assert.True(t, readiness.Ready)
+	assert.NotEmpty(t, readiness.Checks)
+
+	// Init's workers list instances from the store too:
+	unavailable := &store.FakeStore{
+		MockValue: func(path string) (string, error) {
+			return "", &store.UnavailableError{Op: "get", Path: path, Err: context.DeadlineExceeded}
+		},
+		MockValues: func(path string) (map[string]string, error) {
+			return nil, &store.UnavailableError{Op: "list", Path: path, Err: context.DeadlineExceeded}
+		},
+	}
+	req, w = testutils.GetRequest(t, "/readyz")
+	makeRequest(New(config, unavailable), req, w)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"ready":false`)
+}
+
+func TestGetAudit(t *testing.T) {
+	server := New(testCfg, store.NewMemory())
+	rbody := testutils.JSONFromMap(t, map[string]interface{}{
+		"playbook_id": "helloplaybook",
+		"id":          "audited",
+		"vars":        map[string]string{"word": "gorilla"},
+	})
+	req, w := testutils.PostRequest(t, "/instances", rbody)
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req, w = testutils.GetRequest(t, "/audit?action=create&instance=audited")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []audit.Entry
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "token", entries[0].Actor)
+		assert.Equal(t, "api", entries[0].Source)
+		assert.Equal(t, "gorilla", entries[0].After.Vars["word"])
+	}
+
+	req, w = testutils.GetRequest(t, "/audit?format=jsonl")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if assert.Len(t, lines, 1) {
+		var e audit.Entry
+		assert.Nil(t, json.Unmarshal([]byte(lines[0]), &e))
+		assert.Equal(t, "create", e.Action)
+	}
+
+	for _, path := range []string{"/audit?format=csv", "/audit?since=yesterday"} {
+		req, w = testutils.GetRequest(t, path)
+		makeRequest(server, auth(testCfg, req), w)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
 }
 
 func helperSetupServer(cfg cfg.Type) (*httptest.ResponseRecorder, *Server, http.Handler) {
@@ -279,7 +497,7 @@ func TestSlackCommandSetvar(t *testing.T) {
 
 	i := &instance.Instance{PlaybookID: "boing", ID: "bar", Vars: map[string]string{"var1": "val2"}}
 	is := services.NewInstanceService(testutils.TestCfg, etcdstore.New())
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 	if err != nil {
 		t.Log(err)
 	}
@@ -299,7 +517,7 @@ func TestSlackCommandDelete(t *testing.T) {
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "forserver"}
 	is := services.NewInstanceService(testCfg, etcdstore.New())
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 	if err != nil {
 		t.Log(err)
 	}
@@ -344,7 +562,7 @@ func TestDeleteExisting(t *testing.T) {
 		ID:         "TestDeleteInstance",
 		Status:     instance.StatusDeployed}
 	is := services.NewInstanceService(testutils.TestCfg, ets)
-	_, err := is.CreateOrUpdate(testInstance1)
+	_, err := is.CreateOrUpdate(context.Background(), testInstance1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -360,8 +578,115 @@ func TestDeleteExisting(t *testing.T) {
 	e := s.Handler()
 	e.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code, "Expected DELETE /instances to return 200")
-	assert.Contains(t, w.Body.String(), "Instance successfully deleted")
+	assert.Equal(t, http.StatusAccepted, w.Code, "Expected DELETE /instances to return 202")
+	var j job.Job
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &j))
+	assert.Equal(t, job.ActionDelete, j.Action)
+	assert.Equal(t, testInstance1.ID, j.InstanceID)
+}
+
+func TestDeployRefused(t *testing.T) {
+	st := store.NewMemory()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestDeployRefused", Status: instance.StatusDeleting}
+	i.Path = instance.Path{RootPath: testCfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+	if err := instance.Save(context.Background(), st, i); err != nil {
+		t.Fatal(err)
+	}
+	server := New(testCfg, st)
+
+	req, err := http.NewRequest("POST", "/deploy/helloplaybook/TestDeployRefused", nil)
+	assert.Nil(t, err)
+	req = auth(testCfg, req)
+	w := httptest.NewRecorder()
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRollbackInstance(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestRollbackInstance", Status: instance.StatusDeployed, Revision: 2}
+	i.Path = instance.Path{RootPath: testCfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+	fresh := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestRollbackFresh", Status: instance.StatusDeployed, Revision: 1}
+	fresh.Path = instance.Path{RootPath: testCfg.EtcdPath, PlaybookID: fresh.PlaybookID, ID: fresh.ID}
+	assert.Nil(t, instance.Save(ctx, st, i))
+	assert.Nil(t, instance.Save(ctx, st, fresh))
+	p := revision.Path{RootPath: testCfg.EtcdPath, PlaybookID: i.PlaybookID, InstanceID: i.ID}
+	for n := 1; n <= 2; n++ {
+		assert.Nil(t, revision.Save(ctx, st, p, &revision.Revision{Number: n, PlaybookID: i.PlaybookID, InstanceID: i.ID}))
+	}
+	server := New(testCfg, st)
+	server.Deployers = deployment.NewFake().Factory
+	server.Init()
+
+	cases := []struct {
+		Scenario string
+		Path     string
+		Expected int
+	}{
+		{"missing revision", "/rollback/helloplaybook/TestRollbackInstance?revision=3", http.StatusNotFound},
+		{"no previous revision", "/rollback/helloplaybook/TestRollbackFresh", http.StatusConflict},
+		{"previous revision", "/rollback/helloplaybook/TestRollbackInstance", http.StatusAccepted},
+	}
+	for _, c := range cases {
+		req, w := testutils.PostRequest(t, c.Path, nil)
+		server.Handler().ServeHTTP(w, auth(testCfg, req))
+		assert.Equal(t, c.Expected, w.Code, c.Scenario)
+		if w.Code == http.StatusAccepted {
+			var j job.Job
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &j))
+			assert.Equal(t, job.ActionRollback, j.Action, "rollbacks run as jobs")
+		}
+	}
+	server.jobs.Wait()
+}
+
+func TestGetJob(t *testing.T) {
+	st := store.NewMemory()
+	j := job.New(job.ActionDeploy, "helloplaybook", "TestGetJob", "api:token", time.Now())
+	if err := job.Save(context.Background(), st, testCfg.EtcdPath, j); err != nil {
+		t.Fatal(err)
+	}
+	server := New(testCfg, st)
+
+	req, w := testutils.GetRequest(t, "/jobs/"+j.ID)
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found job.Job
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, j.ID, found.ID)
+	assert.Equal(t, job.StateQueued, found.State)
+
+	req, w = testutils.GetRequest(t, "/jobs/missing")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCancelJob(t *testing.T) {
+	st := store.NewMemory()
+	j := job.New(job.ActionDeploy, "helloplaybook", "TestCancelJob", "api:token", time.Now())
+	j.Finish(nil, time.Now())
+	if err := job.Save(context.Background(), st, testCfg.EtcdPath, j); err != nil {
+		t.Fatal(err)
+	}
+	server := New(testCfg, st)
+
+	req, w := testutils.DeleteRequest(t, "/jobs/"+j.ID)
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusConflict, w.Code, "finished jobs can't be cancelled")
+
+	req, w = testutils.DeleteRequest(t, "/jobs/missing")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, w = testutils.PostRequest(t, "/cancel/helloplaybook/TestCancelJob", nil)
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusNotFound, w.Code, "the instance has no job to cancel")
 }
 
 func TestDeleteWhenNonExistantInstance(t *testing.T) {
@@ -373,3 +698,108 @@ func TestDeleteWhenNonExistantInstance(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code, "Expected DELETE /instances to return 404 when missing instance")
 }
+
+func TestGetInstanceWithUnavailableStore(t *testing.T) {
+	fs := &store.FakeStore{
+		MockValue: func(path string) (string, error) {
+			return "", &store.UnavailableError{Op: "get", Path: path, Err: context.DeadlineExceeded}
+		},
+	}
+	req, w := testutils.GetRequest(t, "/instance/helloplaybook/TestGetInstanceWithUnavailableStore")
+	req = auth(testCfg, req)
+	server := New(testCfg, fs)
+	server.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestGetQueue(t *testing.T) {
+	server := New(testCfg, store.NewMemory())
+
+	req, w := testutils.GetRequest(t, "/queue")
+	req = auth(testCfg, req)
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var queue struct {
+		Running []job.Job `json:"running"`
+		Queued  []job.Job `json:"queued"`
+		Depth   int       `json:"depth"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	assert.Empty(t, queue.Running)
+	assert.Empty(t, queue.Queued)
+	assert.Equal(t, 0, queue.Depth)
+}
+
+func TestGetMetrics(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "measured", Status: instance.StatusDeployed, Path: instance.Path{testCfg.EtcdPath, "helloplaybook", "measured"}}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	server := New(testCfg, s)
+
+	req, w := testutils.GetRequest(t, "/instance/helloplaybook/measured")
+	makeRequest(server, auth(testCfg, req), w)
+	req, w = testutils.GetRequest(t, "/metrics")
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code, "metrics are unauthenticated without a metrics token")
+	assert.Contains(t, w.Body.String(), `broadway_instances{playbook="helloplaybook",status="deployed"} 1`)
+	assert.Contains(t, w.Body.String(), `broadway_http_requests_total{code="200",method="GET",route="/instance/:playbookID/:instanceID"}`)
+
+	cfg := testCfg
+	cfg.MetricsToken = "metricstoken"
+	server = New(cfg, s)
+	req, w = testutils.GetRequest(t, "/metrics")
+	makeRequest(server, auth(cfg, req), w)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the API token isn't the metrics token")
+	req, w = testutils.GetRequest(t, "/metrics")
+	req.Header.Set("Authorization", "Bearer metricstoken")
+	makeRequest(server, req, w)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetLogs(t *testing.T) {
+	s := store.NewMemory()
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "logged", Path: instance.Path{testCfg.EtcdPath, "helloplaybook", "logged"}}
+	assert.Nil(t, instance.Save(context.Background(), s, i))
+	server := New(testCfg, s)
+	server.Deployers = deployment.NewFake().Factory
+
+	req, w := testutils.GetRequest(t, "/instances/helloplaybook/logged/logs?tail=10")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Fake logs of hello for helloplaybook/logged\n", w.Body.String())
+
+	req, w = testutils.GetRequest(t, "/instances/helloplaybook/logged/logs?tail=many")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, w = testutils.GetRequest(t, "/instances/helloplaybook/missing/logs")
+	makeRequest(server, auth(testCfg, req), w)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWorkers(t *testing.T) {
+	st := store.NewMemory()
+	j := job.New(job.ActionDeploy, "helloplaybook", "TestWorkers", "api:token", time.Now())
+	j.Start(time.Now())
+	if err := job.Save(context.Background(), st, testCfg.EtcdPath, j); err != nil {
+		t.Fatal(err)
+	}
+
+	server := New(testCfg, st)
+	server.Init()
+	server.Close()
+	found, err := job.Find(context.Background(), st, testCfg.EtcdPath, j.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateRunning, found.State, "no worker runs without an interval")
+
+	c := testCfg
+	c.InstanceCleanup = 60
+	server = New(c, st)
+	server.Init()
+	server.Close()
+	found, err = job.Find(context.Background(), st, testCfg.EtcdPath, j.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateFailed, found.State, "the cleanup worker fails abandoned jobs first")
+}
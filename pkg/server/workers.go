@@ -0,0 +1,93 @@
+package server
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/cfg"
+	"golang.org/x/net/context"
+)
+
+// startWorkers starts the background workers whose interval is set in c.
+// They run until the server is closed. Workers started before are stopped
+// first.
+func (s *Server) startWorkers(c cfg.Type) {
+	s.Close()
+	s.stop = make(chan struct{})
+	if c.InstanceCleanup > 0 {
+		glog.Info("Initialize deployed instances cleanup worker")
+		s.workers.Add(1)
+		go s.cleanupWorker(c, s.stop)
+	}
+	if c.DriftInterval > 0 {
+		glog.Info("Initialize drift detection worker")
+		s.workers.Add(1)
+		go s.driftWorker(c, s.stop)
+	}
+}
+
+// Close stops the background workers and waits for them to return
+func (s *Server) Close() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.workers.Wait()
+}
+
+// cleanupWorker recovers the jobs and the deploys and stops interrupted by a
+// restart first. Then every InstanceCleanup seconds it recovers abandoned
+// instances, warns about expiring ones, cleans up expired ones and prunes
+// finished jobs.
+func (s *Server) cleanupWorker(c cfg.Type, stop <-chan struct{}) {
+	defer s.workers.Done()
+	if err := s.jobs.FailAbandoned(context.Background(), time.Now()); err != nil {
+		glog.Errorf("Failed to fail abandoned jobs: %s", err)
+	}
+	if err := s.jobs.RecoverAbandoned(context.Background(), time.Now()); err != nil {
+		glog.Errorf("Failed to recover abandoned instances: %s", err)
+	}
+
+	ticker := time.NewTicker(time.Second * time.Duration(c.InstanceCleanup))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := s.jobs.RecoverAbandoned(context.Background(), time.Now()); err != nil {
+			glog.Errorf("Failed to recover abandoned instances: %s", err)
+		}
+		if !c.CleanupDryRun {
+			if _, err := s.ds.WarnExpiring(context.Background(), time.Now()); err != nil {
+				glog.Errorf("Failed to warn about expiring instances: %s", err)
+			}
+		}
+		if _, err := s.jobs.Cleanup(context.Background(), time.Now(), c.CleanupDryRun); err != nil {
+			glog.Errorf("Failed to clean up instances: %s", err)
+		}
+		if c.JobRetention > 0 {
+			if err := s.jobs.Prune(context.Background(), time.Now().Add(-c.JobRetention)); err != nil {
+				glog.Errorf("Failed to prune finished jobs: %s", err)
+			}
+		}
+	}
+}
+
+// driftWorker looks for drifted instances every DriftInterval
+func (s *Server) driftWorker(c cfg.Type, stop <-chan struct{}) {
+	defer s.workers.Done()
+	ticker := time.NewTicker(c.DriftInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.jobs.DetectDrift(context.Background(), time.Now()); err != nil {
+			glog.Errorf("Failed to detect drifted instances: %s", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/audit"
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// newAuditEntry starts an audit entry for an action the actor of ctx took on
+// the instance playbookID/ID
+func newAuditEntry(ctx context.Context, action, playbookID, ID string) *audit.Entry {
+	return audit.NewEntry(actor.FromContext(ctx), action, playbookID, ID, time.Now())
+}
+
+// recordAudit appends e to the audit log. Failing to record it is logged but
+// doesn't fail the action it records, which was taken already.
+func recordAudit(ctx context.Context, c cfg.Type, s store.Store, e *audit.Entry) {
+	if err := audit.Save(ctx, s, c.EtcdPath, e); err != nil {
+		glog.Errorf("Failed to record %s of %s/%s by %s:%s in the audit log: %s", e.Action, e.PlaybookID, e.InstanceID, e.Source, e.Actor, err)
+	}
+}
+
+// summarize returns the state of i recorded in the audit log, or nil if there
+// is no instance
+func summarize(i *instance.Instance) *audit.Summary {
+	if i == nil {
+		return nil
+	}
+	vars := make(map[string]string, len(i.Vars))
+	for k, v := range i.Vars {
+		vars[k] = v
+	}
+	return &audit.Summary{
+		Status:    string(i.Status),
+		Target:    i.Target,
+		Revision:  i.Revision,
+		ExpiredAt: i.ExpiredAt,
+		Pinned:    i.Pinned,
+		Vars:      vars,
+	}
+}
+
+// Audit returns the entries of the audit log matching q, newest first
+func (is *InstanceService) Audit(ctx context.Context, q audit.Query) ([]*audit.Entry, error) {
+	return audit.List(ctx, is.store, is.Cfg.EtcdPath, q)
+}
+
+// ParseAuditQuery builds an audit log query from request parameters. Actions
+// may be given as repeated or comma separated parameters.
+func ParseAuditQuery(values url.Values) (audit.Query, error) {
+	q := audit.Query{}
+	for param, vs := range values {
+		v := vs[len(vs)-1]
+		var err error
+		switch param {
+		case "actor":
+			q.Actor = v
+		case "source":
+			q.Source = v
+		case "action":
+			q.Actions = splitList(vs)
+		case "playbook":
+			q.PlaybookID = v
+		case "instance":
+			q.InstanceID = v
+		case "from":
+			q.From, err = parseQueryTime(v, false)
+		case "to":
+			q.To, err = parseQueryTime(v, true)
+		case "limit":
+			q.Limit, err = strconv.Atoi(v)
+			if err == nil && q.Limit < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		default:
+			return q, &InvalidQuery{param, "unknown parameter"}
+		}
+		if err != nil {
+			return q, &InvalidQuery{param, err.Error()}
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/audit"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAuditTrail(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	s := store.NewMemory()
+	ctx := actor.NewContext(context.Background(), actor.Actor{Source: actor.SourceSlack, Name: "bill"})
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	ds.Deployers = deployment.NewFake().Factory
+	js := NewJobService(ds)
+
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "audited", Vars: map[string]string{"word": "hi"}})
+	assert.Nil(t, err)
+	_, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "audited", Vars: map[string]string{"word": "bye"}})
+	assert.Nil(t, err)
+	command := BuildSlackCommand(testutils.TestCfg, "setvar helloplaybook audited bird=owl", js, is, testPlaybooks)
+	_, err = command.Execute(ctx)
+	assert.Nil(t, err)
+	_, err = is.Extend(ctx, "helloplaybook", "audited", time.Hour)
+	assert.Nil(t, err)
+	_, err = is.SetPinned(ctx, "helloplaybook", "audited", true)
+	assert.Nil(t, err)
+	i, err := is.Show(ctx, "helloplaybook", "audited")
+	assert.Nil(t, err)
+	j, err := js.Deploy(ctx, i)
+	assert.Nil(t, err)
+	js.Wait()
+
+	entries, err := is.Audit(ctx, audit.Query{PlaybookID: "helloplaybook", InstanceID: "audited"})
+	assert.Nil(t, err)
+	actions := []string{}
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+		assert.Equal(t, "bill", e.Actor)
+		assert.Equal(t, actor.SourceSlack, e.Source)
+	}
+	assert.Equal(t, []string{"deploy", "pin", "extend", "setvar", "update", "create"}, actions, "newest first")
+	if len(entries) == 6 {
+		assert.Equal(t, j.ID, entries[0].JobID)
+		assert.Equal(t, "", entries[0].Before.Status, "deploys are recorded when they're requested")
+		assert.True(t, entries[1].After.Pinned)
+		assert.False(t, entries[1].Before.Pinned)
+		assert.True(t, entries[2].After.ExpiredAt > entries[2].Before.ExpiredAt)
+		assert.Equal(t, "", entries[3].Before.Vars["bird"])
+		assert.Equal(t, "owl", entries[3].After.Vars["bird"])
+		assert.Equal(t, "hi", entries[4].Before.Vars["word"])
+		assert.Equal(t, "bye", entries[4].After.Vars["word"])
+		assert.Nil(t, entries[5].Before, "created instances have no prior state")
+	}
+}
+
+func TestAuditExpire(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	s := store.NewMemory()
+	ctx := context.Background()
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	ds.Deployers = deployment.NewFake().Factory
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "expired",
+		Status:     instance.StatusDeployed,
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "expired"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	ds.runCleanup(actor.NewContext(ctx, actor.System), i, &CleanupAction{Reason: CleanupExpired, Stop: true})
+	entries, err := audit.List(ctx, s, testutils.TestCfg.EtcdPath, audit.Query{Actions: []string{audit.ActionExpire}})
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "broadway", entries[0].Actor)
+		assert.Equal(t, actor.SourceSystem, entries[0].Source)
+		assert.Equal(t, "stop expired instance", entries[0].Detail)
+		assert.Equal(t, "deployed", entries[0].Before.Status)
+		assert.Equal(t, "stopped", entries[0].After.Status)
+	}
+}
+
+func TestParseAuditQuery(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Params   string
+		Expected audit.Query
+		Err      string
+	}{
+		{"no filter", "", audit.Query{}, ""},
+		{
+			"every filter",
+			"actor=bill&source=slack&action=deploy,stop&action=delete&playbook=web&instance=master&from=2016-08-05&to=2016-08-05&limit=10",
+			audit.Query{
+				Actor:      "bill",
+				Source:     "slack",
+				Actions:    []string{"deploy", "stop", "delete"},
+				PlaybookID: "web",
+				InstanceID: "master",
+				From:       1470355200,
+				To:         1470441599,
+				Limit:      10,
+			},
+			"",
+		},
+		{"bad time", "from=yesterday", audit.Query{}, "Invalid from: yesterday is not a timestamp or date"},
+		{"bad limit", "limit=-1", audit.Query{}, "Invalid limit: must not be negative"},
+		{"unknown", "status=deployed", audit.Query{}, "Invalid status: unknown parameter"},
+	}
+	for _, c := range cases {
+		values, _ := url.ParseQuery(c.Params)
+		q, err := ParseAuditQuery(values)
+		if c.Err != "" {
+			assert.EqualError(t, err, c.Err, c.Name)
+			continue
+		}
+		assert.Nil(t, err, c.Name)
+		assert.Equal(t, c.Expected, q, c.Name)
+	}
+}
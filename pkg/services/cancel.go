@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/namely/broadway/pkg/job"
+	"golang.org/x/net/context"
+)
+
+// Cancelled indicates a deploy or stop was cancelled while it ran
+type Cancelled struct {
+	By string
+}
+
+func (e *Cancelled) Error() string {
+	return fmt.Sprintf("cancelled by %s", e.By)
+}
+
+// JobNotCancellable indicates a job can't be cancelled from this broadway
+// process, either because it's done or because another process runs it
+type JobNotCancellable struct {
+	ID    string
+	State string
+}
+
+func (e *JobNotCancellable) Error() string {
+	if e.State == job.StateQueued || e.State == job.StateRunning {
+		return fmt.Sprintf("Job %s is %s on another broadway process", e.ID, e.State)
+	}
+	return fmt.Sprintf("Job %s is %s already", e.ID, e.State)
+}
+
+// NoJobs indicates an instance has no job waiting or running
+type NoJobs struct {
+	PlaybookID string
+	InstanceID string
+}
+
+func (e *NoJobs) Error() string {
+	return fmt.Sprintf("%s/%s has no job to cancel", e.PlaybookID, e.InstanceID)
+}
+
+// cancellation lets the deploy or stop run by a job be cancelled, remembering
+// who cancelled it. It's kept apart from the job's context, which must stay
+// usable to record the outcome once the deployer gave up.
+type cancellation struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	by     string
+}
+
+type cancellationKey struct{}
+
+// withCancellation returns a context carrying a new cancellation
+func withCancellation(ctx context.Context) (context.Context, *cancellation) {
+	c := &cancellation{}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return context.WithValue(ctx, cancellationKey{}, c), c
+}
+
+// Cancel cancels the deploy or stop on behalf of actor. Only the first
+// cancellation is remembered.
+func (c *cancellation) Cancel(actor string) {
+	c.mu.Lock()
+	if c.by == "" {
+		c.by = actor
+	}
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// cancelledBy returns who cancelled the deploy or stop, if anyone did
+func (c *cancellation) cancelledBy() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.by, c.ctx.Err() != nil
+}
+
+// deployerContext returns the context cancelling the deployer of a deploy or
+// stop run with ctx
+func deployerContext(ctx context.Context) context.Context {
+	if c, ok := ctx.Value(cancellationKey{}).(*cancellation); ok {
+		return c.ctx
+	}
+	return ctx
+}
+
+// cancelled returns the cancellation of the deploy or stop run with ctx, if it
+// was cancelled
+func cancelled(ctx context.Context) (*Cancelled, bool) {
+	c, ok := ctx.Value(cancellationKey{}).(*cancellation)
+	if !ok {
+		return nil, false
+	}
+	by, ok := c.cancelledBy()
+	if !ok {
+		return nil, false
+	}
+	return &Cancelled{By: by}, true
+}
@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
 	"github.com/namely/broadway/pkg/notification"
 	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
 )
 
 // DeploymentService implements the Broadway logic for deployments
@@ -21,16 +25,46 @@ type DeploymentService struct {
 	store     store.Store
 	playbooks map[string]*deployment.Playbook
 	manifests map[string]*deployment.Manifest
+	// Deployers creates the deployers of instances, KubernetesDeployments
+	// unless it's replaced
+	Deployers deployment.Factory
+
+	mu       sync.Mutex
+	clusters map[string]*deployment.Cluster
+	statuses map[instance.Path]liveStatus
 }
 
 // NewDeploymentService creates a new DeploymentService
 func NewDeploymentService(cfg cfg.Type, s store.Store, ps map[string]*deployment.Playbook, ms map[string]*deployment.Manifest) *DeploymentService {
-	return &DeploymentService{
+	d := &DeploymentService{
 		Cfg:       cfg,
 		store:     s,
 		playbooks: ps,
 		manifests: ms,
+		clusters:  map[string]*deployment.Cluster{},
+		statuses:  map[instance.Path]liveStatus{},
+	}
+	d.Deployers = deployment.KubernetesFactory(d.cluster)
+	return d
+}
+
+// deployer creates the deployer of i, recording its steps in rec
+func (d *DeploymentService) deployer(ctx context.Context, i *instance.Instance, target string, playbook *deployment.Playbook, rec *history.Record) (deployment.Deployer, error) {
+	vars := varMap(i)
+	deployer, err := d.Deployers(deployment.Spec{
+		Target:    target,
+		Playbook:  playbook,
+		Variables: vars,
+		Manifests: d.manifests,
+		Observer:  observeSteps(ctx, rec),
+		Context:   deployerContext(ctx),
+	})
+	if err != nil {
+		return nil, err
 	}
+	// The deployer defaulted the missing vars:
+	rec.ManifestHash = deployment.RenderedHash(playbook, d.manifests, vars)
+	return deployer, nil
 }
 
 func varMap(i *instance.Instance) map[string]string {
@@ -49,7 +83,15 @@ func varMap(i *instance.Instance) map[string]string {
 
 // DeployAndNotify attempts to deploy an instance. It reports success or failure
 // through the notification service as well as returning an error.
-func (d *DeploymentService) DeployAndNotify(i *instance.Instance) error {
+func (d *DeploymentService) DeployAndNotify(ctx context.Context, i *instance.Instance) error {
+	return d.deploy(ctx, i, history.ActionDeploy, fmt.Sprintf("Instance %s/%s deployed successfully", i.PlaybookID, i.ID))
+}
+
+// deploy runs a deployment recorded as action, announcing success with summary
+func (d *DeploymentService) deploy(ctx context.Context, i *instance.Instance, action, summary string) (err error) {
+	rec := d.startRecord(ctx, i, action)
+	defer d.finishRecord(ctx, rec, time.Now(), &err)
+
 	playbook, ok := d.playbooks[i.PlaybookID]
 	if !ok {
 		msg := fmt.Sprintf("Can't deploy %s/%s: Playbook missing", i.PlaybookID, i.ID)
@@ -57,68 +99,65 @@ func (d *DeploymentService) DeployAndNotify(i *instance.Instance) error {
 		return errors.New(msg)
 	}
 
-	config, err := deployment.Config(d.Cfg)
+	target := d.target(i)
+	deployer, err := d.deployer(ctx, i, target, playbook, rec)
 	if err != nil {
-		msg := fmt.Sprintf("Can't deploy %s/%s: Internal error", i.PlaybookID, i.ID)
+		msg := fmt.Sprintf("Can't deploy %s/%s: %s", i.PlaybookID, i.ID, deployerFailure(err))
 		notify(d.Cfg, i, msg)
 		return err
 	}
+	i.Target = target
 
-	deployer, err := deployment.NewKubernetesDeployment(config, playbook, varMap(i), d.manifests)
-	if err != nil {
-		msg := fmt.Sprintf("Can't deploy %s/%s: Internal error", i.PlaybookID, i.ID)
-		notify(d.Cfg, i, msg)
-		return err
-	}
-
-	if i.Status == instance.StatusDeploying {
-		msg := fmt.Sprintf("Can't deploy %s/%s: Instance is being deployed already.", i.PlaybookID, i.ID)
-		notify(d.Cfg, i, msg)
-		return errors.New(msg)
-	}
-
-	if i.Status == instance.StatusDeleting {
-		msg := fmt.Sprintf("Can't deploy %s/%s: Instance is being deleted already.", i.PlaybookID, i.ID)
+	if err := i.Transition(instance.StatusDeploying, time.Now(), nil); err != nil {
+		msg := fmt.Sprintf("Can't deploy %s/%s: %s", i.PlaybookID, i.ID, refusal(i, err))
 		notify(d.Cfg, i, msg)
 		return errors.New(msg)
 	}
-
-	i.Status = instance.StatusDeploying
-	err = instance.Save(d.store, i)
+	i.Claim(owner, jobAction(ctx, job.ActionDeploy), time.Now())
+	err = instance.Save(ctx, d.store, i)
 	if err != nil {
 		glog.Errorf("Failed to save instance status Deploying for %s/%s, continuing deployment. Error: %s\n", i.PlaybookID, i.ID, err.Error())
 	}
 
+	stopHeartbeat := d.heartbeat(ctx, i)
 	errD := deployer.Deploy()
+	stopHeartbeat()
 	if errD != nil {
+		msg := fmt.Sprintf("Deploying %s/%s failed: %s\n", i.PlaybookID, i.ID, errD.Error())
+		if c, ok := cancelled(ctx); ok {
+			errD = c
+			msg = fmt.Sprintf("Deploying %s/%s was cancelled by %s", i.PlaybookID, i.ID, c.By)
+		}
+
 		// Mark the instance as problematic:
-		i.Status = instance.StatusError
-		err := instance.Save(d.store, i)
+		i.Transition(instance.StatusError, time.Now(), errD)
+		err := d.saveOutcome(ctx, i)
 		if err != nil {
 			glog.Errorf("Failed to save instance.StatusError for %s/%s; not sending notification:\n%s\n", i.PlaybookID, i.ID, err.Error())
 			return err
 		}
 
 		// Report the problem:
-		msg := fmt.Sprintf("Deploying %s/%s failed: %s\n", i.PlaybookID, i.ID, errD.Error())
 		glog.Error(msg)
-		m := notification.NewMessage(d.Cfg, false, msg)
-		err = m.Send()
-		if err != nil {
-			return err
-		}
+		notify(d.Cfg, i, msg)
 
 		return errD
 	}
 
 	// It worked, notify success:
-	err = sendDeploymentNotification(d.Cfg, i)
+	err = sendDeploymentNotification(d.Cfg, i, summary)
 	if err != nil {
 		glog.Error(err)
 	}
 
-	i.Status = instance.StatusDeployed
-	err = instance.Save(d.store, i)
+	i.Transition(instance.StatusDeployed, time.Now(), nil)
+	i.Drift, i.DriftedAt = nil, 0
+	renewExpiration(i, playbook, time.Now())
+	if err := d.saveRevision(ctx, i, playbook, rec.ManifestHash); err != nil {
+		glog.Errorf("Failed to save a revision for %s/%s: %s", i.PlaybookID, i.ID, err)
+	}
+	rec.Revision = i.Revision
+	err = d.saveOutcome(ctx, i)
 	if err != nil {
 		glog.Errorf("DeploymentService failed to save instance status Deployed for %s/%s:\n%s\n", i.PlaybookID, i.ID, err.Error())
 		return err
@@ -127,7 +166,7 @@ func (d *DeploymentService) DeployAndNotify(i *instance.Instance) error {
 	return nil
 }
 
-func sendDeploymentNotification(cfg cfg.Type, i *instance.Instance) error {
+func sendDeploymentNotification(cfg cfg.Type, i *instance.Instance, summary string) error {
 	pb, ok := deployment.AllPlaybooks[i.PlaybookID]
 	if !ok {
 		return fmt.Errorf("Failed to lookup playbook for instance %+v", *i)
@@ -135,7 +174,7 @@ func sendDeploymentNotification(cfg cfg.Type, i *instance.Instance) error {
 
 	atts := []notification.Attachment{
 		{
-			Text: fmt.Sprintf("Instance %s/%s deployed successfully", i.PlaybookID, i.ID),
+			Text: withTarget(i, summary),
 		},
 	}
 	tp, ok := pb.Messages["deployed"]
@@ -160,7 +199,10 @@ func sendDeploymentNotification(cfg cfg.Type, i *instance.Instance) error {
 }
 
 // StopAndNotify deletes resources created by deployment
-func (d *DeploymentService) StopAndNotify(i *instance.Instance) error {
+func (d *DeploymentService) StopAndNotify(ctx context.Context, i *instance.Instance) (err error) {
+	rec := d.startRecord(ctx, i, history.ActionStop)
+	defer d.finishRecord(ctx, rec, time.Now(), &err)
+
 	playbook, ok := d.playbooks[i.PlaybookID]
 	if !ok {
 		msg := fmt.Sprintf("Can't stop %s/%s: Playbook missing", i.PlaybookID, i.ID)
@@ -168,79 +210,116 @@ func (d *DeploymentService) StopAndNotify(i *instance.Instance) error {
 		return errors.New(msg)
 	}
 
-	if i.Status == instance.StatusDeleting {
-		msg := fmt.Sprintf("Can't stop %s/%s: Instance is being stopped already.", i.PlaybookID, i.ID)
-		notify(d.Cfg, i, msg)
-		return errors.New(msg)
-	}
-
-	config, err := deployment.Config(d.Cfg)
+	deployer, err := d.deployer(ctx, i, d.target(i), playbook, rec)
 	if err != nil {
-		msg := fmt.Sprintf("Can't stop %s/%s: Internal error", i.PlaybookID, i.ID)
+		msg := fmt.Sprintf("Can't stop %s/%s: %s", i.PlaybookID, i.ID, deployerFailure(err))
 		notify(d.Cfg, i, msg)
 		return err
 	}
 
-	i.Status = instance.StatusDeleting
-	err = instance.Save(d.store, i)
-	if err != nil {
-		glog.Errorf("Failed to save instance status for %s/%s. Error: %s\n", i.PlaybookID, i.ID, err.Error())
-		return err
+	if err := i.Transition(instance.StatusDeleting, time.Now(), nil); err != nil {
+		msg := fmt.Sprintf("Can't stop %s/%s: %s", i.PlaybookID, i.ID, refusal(i, err))
+		notify(d.Cfg, i, msg)
+		return errors.New(msg)
 	}
-
-	deployer, err := deployment.NewKubernetesDeployment(config, playbook, varMap(i), d.manifests)
+	i.Claim(owner, jobAction(ctx, job.ActionStop), time.Now())
+	err = instance.Save(ctx, d.store, i)
 	if err != nil {
-		msg := fmt.Sprintf("Can't stop %s/%s: Internal error", i.PlaybookID, i.ID)
-		notify(d.Cfg, i, msg)
+		glog.Errorf("Failed to save instance status for %s/%s. Error: %s\n", i.PlaybookID, i.ID, err.Error())
 		return err
 	}
 
+	stopHeartbeat := d.heartbeat(ctx, i)
 	errD := deployer.Destroy()
+	stopHeartbeat()
 	if errD != nil {
+		msg := fmt.Sprintf("Stopping %s/%s failed: %s\n", i.PlaybookID, i.ID, errD.Error())
+		if c, ok := cancelled(ctx); ok {
+			errD = c
+			msg = fmt.Sprintf("Stopping %s/%s was cancelled by %s", i.PlaybookID, i.ID, c.By)
+		}
+
 		// Mark the instance as problematic:
-		i.Status = instance.StatusError
-		err := instance.Save(d.store, i)
+		i.Transition(instance.StatusError, time.Now(), errD)
+		err := d.saveOutcome(ctx, i)
 		if err != nil {
 			glog.Errorf("Failed to save instance.StatusError for %s/%s; not sending notification:\n%s\n", i.PlaybookID, i.ID, err.Error())
 			return err
 		}
 
 		// Report the problem:
-		msg := fmt.Sprintf("Stopping %s/%s failed: %s\n", i.PlaybookID, i.ID, errD.Error())
 		glog.Error(msg)
-		m := notification.NewMessage(d.Cfg, false, msg)
-		err = m.Send()
-		if err != nil {
-			return err
-		}
+		notify(d.Cfg, i, msg)
 
 		return errD
 	}
 
-	return nil
+	i.Transition(instance.StatusStopped, time.Now(), nil)
+	i.Drift, i.DriftedAt = nil, 0
+	err = d.saveOutcome(ctx, i)
+	if err != nil {
+		glog.Errorf("DeploymentService failed to save instance status Stopped for %s/%s:\n%s\n", i.PlaybookID, i.ID, err.Error())
+		return err
+	}
 
+	return nil
 }
 
-// RemoveExpiredInstances remove expired instances from the deployment
-func (d *DeploymentService) RemoveExpiredInstances(expirationDate time.Time) error {
-	glog.Info("Starting expired instances cleanup")
-	globalPath := fmt.Sprintf("%s/instances", d.Cfg.EtcdPath)
-	instances, err := instance.AllDeployedAndExpired(d.store, globalPath, expirationDate)
+// saveOutcome saves the status a deploy or stop of i ended in. i was read when
+// the operation started, so the stored instance is read again and only what
+// the operation changes is copied onto it: updates made meanwhile, such as new
+// vars, extending or pinning the instance, are kept. The expiration only moves
+// later, as deploys renew it. i is refreshed with what was saved.
+func (d *DeploymentService) saveOutcome(ctx context.Context, i *instance.Instance) error {
+	stored, err := instance.FindByPath(ctx, d.store, i.Path)
 	if err != nil {
 		return err
 	}
-	glog.Infof("Removing %d instances from kubernetes", len(instances))
-	for _, i := range instances {
-		if err = d.StopAndNotify(i); err != nil {
-			glog.Error(err)
-		}
+	stored.Status = i.Status
+	stored.Timestamps = i.Timestamps
+	stored.LastError = i.LastError
+	stored.Revision = i.Revision
+	stored.Owner, stored.Operation, stored.Heartbeat = i.Owner, i.Operation, i.Heartbeat
+	stored.Target = i.Target
+	stored.Drift, stored.DriftedAt = i.Drift, i.DriftedAt
+	if i.ExpiredAt > stored.ExpiredAt {
+		stored.ExpiredAt = i.ExpiredAt
 	}
-
+	if err := instance.Save(ctx, d.store, stored); err != nil {
+		return err
+	}
+	*i = *stored
 	return nil
 }
 
+// refusal explains why i can't make a requested status transition
+func refusal(i *instance.Instance, err error) string {
+	switch i.Status {
+	case instance.StatusDeploying:
+		return "Instance is being deployed already."
+	case instance.StatusDeleting:
+		return "Instance is being stopped already."
+	}
+	return err.Error()
+}
+
+// RemoveExpiredInstances applies the playbooks' lifecycle policies to every
+// instance as of expirationDate
+func (d *DeploymentService) RemoveExpiredInstances(ctx context.Context, expirationDate time.Time) error {
+	_, err := d.Cleanup(ctx, expirationDate, false)
+	return err
+}
+
+// deployerFailure explains why the deployer of an instance can't be created
+func deployerFailure(err error) string {
+	if _, ok := err.(*UnknownTarget); ok {
+		return err.Error()
+	}
+	return "Internal error"
+}
+
 func notify(cfg cfg.Type, i *instance.Instance, msg string) {
-	m := notification.NewMessage(cfg, false, msg)
+	m := notification.NewMessage(cfg, false, withTarget(i, msg))
 	err := m.Send()
 	if err != nil {
 		glog.Warningf("Failed to send notification from DeploymentService:\n%+v\n", m)
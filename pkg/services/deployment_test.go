@@ -1,12 +1,15 @@
 package services
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/namely/broadway/pkg/store"
 	"github.com/namely/broadway/pkg/store/etcdstore"
 	"github.com/namely/broadway/pkg/testutils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 
 	"github.com/namely/broadway/pkg/deployment"
 	"github.com/namely/broadway/pkg/instance"
@@ -59,17 +62,17 @@ func TestRemoveExpiredInstancest(t *testing.T) {
 	s := etcdstore.New()
 	for _, c := range cases {
 		c.Instance.ExpiredAt = instance.NewExpiredAt(ServicesTestCfg.InstanceExpirationDays, c.CurrentDate).Unix()
-		err := instance.Save(s, c.Instance)
+		err := instance.Save(context.Background(), s, c.Instance)
 		assert.Nil(t, err, c.Scenario)
 
-		err = ds.DeployAndNotify(c.Instance)
+		err = ds.DeployAndNotify(context.Background(), c.Instance)
 		assert.Nil(t, err, c.Scenario)
 
-		err = ds.RemoveExpiredInstances(c.ExpirationDate)
+		err = ds.RemoveExpiredInstances(context.Background(), c.ExpirationDate)
 
-		ii, err := instance.FindByPath(s, c.Instance.Path)
+		ii, err := instance.FindByPath(context.Background(), s, c.Instance.Path)
 		assert.Equal(t, c.Error, err, c.Scenario)
-		assert.Equal(t, instance.StatusDeleting, ii.Status, c.Scenario)
+		assert.Equal(t, instance.StatusStopped, ii.Status, c.Scenario)
 	}
 }
 
@@ -109,12 +112,98 @@ func TestDeployment(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		err = ds.DeployAndNotify(c.Instance)
+		err = ds.DeployAndNotify(context.Background(), c.Instance)
 		assert.Equal(t, c.Error, err)
 		assert.EqualValues(t, c.Expected, c.Instance.Status)
 	}
 }
 
+func TestFakeDeployer(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	s := store.NewMemory()
+	fake := deployment.NewFake()
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	ds.Deployers = fake.Factory
+
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "faked",
+		Vars:       map[string]string{"word": "hi"},
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "faked"},
+	}
+	assert.Nil(t, ds.DeployAndNotify(ctx, i))
+	assert.Equal(t, instance.StatusDeployed, i.Status)
+	objects := fake.Objects()
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, deployment.FakeDeploy, objects[0].Action)
+		assert.Equal(t, "faked", objects[0].InstanceID)
+		assert.Equal(t, "hello", objects[0].Manifest)
+		assert.Contains(t, objects[0].Rendered, "kind: ReplicationController")
+	}
+
+	fake.Fail("hello", errors.New("quota exceeded"))
+	assert.EqualError(t, ds.StopAndNotify(ctx, i), "quota exceeded")
+	assert.Equal(t, instance.StatusError, i.Status)
+	assert.Len(t, fake.Objects(), 1, "failed steps render nothing")
+
+	fake.Fail("hello", nil)
+	assert.Nil(t, ds.StopAndNotify(ctx, i))
+	assert.Equal(t, instance.StatusStopped, i.Status)
+	assert.Equal(t, deployment.FakeDestroy, fake.Objects()[1].Action)
+}
+
+// hookDeployer runs hook while it deploys or destroys
+type hookDeployer struct {
+	hook func()
+}
+
+func (d *hookDeployer) Deploy() error  { d.hook(); return nil }
+func (d *hookDeployer) Destroy() error { d.hook(); return nil }
+
+func TestUpdatesWhileDeploying(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+
+	i, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "busy", Vars: map[string]string{"word": "v1"}})
+	assert.Nil(t, err)
+	ds.Deployers = func(deployment.Spec) (deployment.Deployer, error) {
+		return &hookDeployer{hook: func() {
+			_, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "busy", Vars: map[string]string{"word": "v2"}})
+			assert.Nil(t, err)
+			_, err = is.SetPinned(ctx, "helloplaybook", "busy", true)
+			assert.Nil(t, err)
+		}}, nil
+	}
+	assert.Nil(t, ds.DeployAndNotify(ctx, i))
+
+	stored, err := is.Show(ctx, "helloplaybook", "busy")
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusDeployed, stored.Status)
+	assert.Equal(t, "v2", stored.Vars["word"], "vars updated during the deploy are kept")
+	assert.True(t, stored.Pinned, "pinning during the deploy is kept")
+	assert.Equal(t, 1, stored.Revision)
+	assert.Equal(t, "", stored.Owner)
+
+	ds.Deployers = func(deployment.Spec) (deployment.Deployer, error) {
+		return &hookDeployer{hook: func() {
+			_, err := is.SetPinned(ctx, "helloplaybook", "busy", false)
+			assert.Nil(t, err)
+		}}, nil
+	}
+	assert.Nil(t, ds.StopAndNotify(ctx, stored))
+	stored, err = is.Show(ctx, "helloplaybook", "busy")
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusStopped, stored.Status)
+	assert.False(t, stored.Pinned, "unpinning during the stop is kept")
+	assert.Equal(t, "v2", stored.Vars["word"])
+}
+
 func TestCustomDeploymentNotification(t *testing.T) {
 	nt := newNotificationTestHelper()
 	defer nt.Close()
@@ -137,7 +226,7 @@ func TestCustomDeploymentNotification(t *testing.T) {
 		},
 	}
 
-	err = service.DeployAndNotify(i)
+	err = service.DeployAndNotify(context.Background(), i)
 	assert.Equal(t, nil, err)
 	assert.Contains(t, nt.requestBody, "custom deployed")
 	assert.Contains(t, nt.requestBody, "messagesplaybook/test")
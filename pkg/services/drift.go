@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/notification"
+	"golang.org/x/net/context"
+)
+
+// DetectDrift compares every deployed instance with the cluster, recording on
+// the instance the objects that went missing or changed and telling the
+// playbook's team on Slack when that changes. Drifted instances of playbooks
+// that opted in are deployed again to re-apply their manifests. Instances with
+// a job waiting or running are left for later. It returns the instances found
+// drifted.
+func (js *JobService) DetectDrift(ctx context.Context, now time.Time) ([]*instance.Instance, error) {
+	q := instance.Query{Statuses: []instance.Status{instance.StatusDeployed}}
+	instances, _, err := instance.Select(ctx, js.ds.store, fmt.Sprintf("%s/instances", js.ds.Cfg.EtcdPath), q)
+	if err != nil {
+		return nil, err
+	}
+	ctx = actor.NewContext(ctx, actor.System)
+	drifted := []*instance.Instance{}
+	for _, i := range instances {
+		if js.pending(i.PlaybookID, i.ID) {
+			continue
+		}
+		drift, err := js.ds.drift(i)
+		if err != nil {
+			glog.Errorf("Failed to compare %s/%s with the cluster: %s", i.PlaybookID, i.ID, err)
+			continue
+		}
+		recorded, err := js.ds.recordDrift(ctx, i.Path, drift, now)
+		if err != nil {
+			glog.Errorf("Failed to record the drift of %s/%s: %s", i.PlaybookID, i.ID, err)
+			continue
+		}
+		if recorded == nil || len(recorded.Drift) == 0 {
+			continue
+		}
+		drifted = append(drifted, recorded)
+
+		if playbook, ok := js.ds.playbooks[i.PlaybookID]; ok && playbook.ReapplyDrift {
+			if _, err := js.Deploy(ctx, recorded); err != nil {
+				glog.Errorf("Failed to queue a deploy re-applying %s/%s: %s", i.PlaybookID, i.ID, err)
+			}
+		}
+	}
+	return drifted, nil
+}
+
+// drift compares the objects deployed for i with the live ones
+func (d *DeploymentService) drift(i *instance.Instance) ([]deployment.Drift, error) {
+	playbook, ok := d.playbooks[i.PlaybookID]
+	if !ok {
+		return nil, fmt.Errorf("playbook %s is missing", i.PlaybookID)
+	}
+	deployer, err := d.Deployers(deployment.Spec{
+		Target:    d.target(i),
+		Playbook:  playbook,
+		Variables: varMap(i),
+		Manifests: d.manifests,
+	})
+	if err != nil {
+		return nil, err
+	}
+	detector, ok := deployer.(deployment.DriftDetector)
+	if !ok {
+		return []deployment.Drift{}, nil
+	}
+	return detector.Drift()
+}
+
+// recordDrift stores the drift found at now on the instance at path, which is
+// read again in case a job changed it meanwhile, and tells the playbook's team
+// when it differs from the drift stored before. It returns nil if the instance
+// isn't deployed anymore.
+func (d *DeploymentService) recordDrift(ctx context.Context, path instance.Path, found []deployment.Drift, now time.Time) (*instance.Instance, error) {
+	i, err := instance.FindByPath(ctx, d.store, path)
+	if err != nil {
+		return nil, err
+	}
+	if i.Status != instance.StatusDeployed {
+		return nil, nil
+	}
+
+	var drift []instance.Drift
+	for _, f := range found {
+		drift = append(drift, instance.Drift{Manifest: f.Manifest, Kind: f.Kind, Name: f.Name, State: f.State})
+	}
+	if reflect.DeepEqual(drift, i.Drift) {
+		return i, nil
+	}
+
+	switch {
+	case len(drift) == 0:
+		i.DriftedAt = 0
+	case len(i.Drift) == 0:
+		i.DriftedAt = now.Unix()
+	}
+	i.Drift = drift
+	if err := instance.Save(ctx, d.store, i); err != nil {
+		return nil, err
+	}
+
+	playbook := d.playbooks[i.PlaybookID]
+	msg := driftMessage(i, playbook)
+	glog.Info(msg)
+	notifyTeam(d.Cfg, playbook, msg)
+	return i, nil
+}
+
+// driftMessage tells how i drifted from its manifests and what happens next
+func driftMessage(i *instance.Instance, playbook *deployment.Playbook) string {
+	if len(i.Drift) == 0 {
+		return fmt.Sprintf("Instance %s/%s matches its manifests again", i.PlaybookID, i.ID)
+	}
+	objects := []string{}
+	for _, d := range i.Drift {
+		objects = append(objects, fmt.Sprintf("%s %s (%s)", d.Kind, d.Name, d.State))
+	}
+	msg := fmt.Sprintf("Instance %s/%s drifted from its manifests: %s. ", i.PlaybookID, i.ID, strings.Join(objects, ", "))
+	if playbook != nil && playbook.ReapplyDrift {
+		return msg + "Deploying it again."
+	}
+	return msg + fmt.Sprintf("Run `/bw deploy %s %s` to re-apply them.", i.PlaybookID, i.ID)
+}
+
+// notifyTeam sends msg to the Slack channel of the playbook's team, or to the
+// webhook's channel if the playbook doesn't name one
+func notifyTeam(cfg cfg.Type, playbook *deployment.Playbook, msg string) {
+	m := notification.NewMessage(cfg, false, msg)
+	if playbook != nil {
+		m.Channel = playbook.Meta.Slack
+	}
+	if err := m.Send(); err != nil {
+		glog.Warningf("Failed to send notification to %s:\n%+v\n", m.Channel, m)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRecordDrift(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	playbooks := map[string]*deployment.Playbook{
+		"web": {ID: "web", Meta: deployment.Meta{Slack: "#payments"}},
+	}
+	ds := NewDeploymentService(ServicesTestCfg, s, playbooks, nil)
+	path := instance.Path{RootPath: ServicesTestCfg.EtcdPath, PlaybookID: "web", ID: "master"}
+	assert.Nil(t, instance.Save(ctx, s, &instance.Instance{PlaybookID: "web", ID: "master", Status: instance.StatusDeployed, Path: path}))
+
+	found := []deployment.Drift{{Manifest: "web-rc", Kind: "ReplicationController", Name: "web", State: deployment.DriftMissing}}
+	i, err := ds.recordDrift(ctx, path, found, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []instance.Drift{{Manifest: "web-rc", Kind: "ReplicationController", Name: "web", State: "missing"}}, i.Drift)
+	assert.Equal(t, now.Unix(), i.DriftedAt)
+	assert.Contains(t, nt.requestBody, "Instance web/master drifted from its manifests: ReplicationController web (missing). Run `/bw deploy web master` to re-apply them.")
+	assert.Contains(t, nt.requestBody, `"channel":"#payments"`, "the playbook's team is told")
+
+	nt.requestBody = ""
+	i, err = ds.recordDrift(ctx, path, found, now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, now.Unix(), i.DriftedAt, "drift is dated from when it was first found")
+	assert.Empty(t, nt.requestBody, "the same drift is only reported once")
+
+	i, err = ds.recordDrift(ctx, path, nil, now.Add(2*time.Hour))
+	assert.Nil(t, err)
+	assert.Empty(t, i.Drift)
+	assert.Zero(t, i.DriftedAt)
+	assert.Contains(t, nt.requestBody, "Instance web/master matches its manifests again")
+
+	stopped, err := instance.FindByPath(ctx, s, path)
+	assert.Nil(t, err)
+	stopped.Status = instance.StatusStopped
+	assert.Nil(t, instance.Save(ctx, s, stopped))
+	i, err = ds.recordDrift(ctx, path, found, now)
+	assert.Nil(t, err)
+	assert.Nil(t, i, "instances that aren't deployed anymore are left alone")
+}
+
+func TestDriftMessage(t *testing.T) {
+	i := &instance.Instance{PlaybookID: "web", ID: "master", Drift: []instance.Drift{
+		{Kind: "ReplicationController", Name: "web", State: "changed"},
+		{Kind: "Service", Name: "web", State: "missing"},
+	}}
+	assert.Equal(t, "Instance web/master drifted from its manifests: ReplicationController web (changed), Service web (missing). Deploying it again.",
+		driftMessage(i, &deployment.Playbook{ReapplyDrift: true}))
+}
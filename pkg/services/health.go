@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// readinessTimeout bounds each readiness check
+const readinessTimeout = 5 * time.Second
+
+// Check is the outcome of a readiness check
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Readiness checks Broadway can serve requests: that its store and the
+// Kubernetes API of each of its targets can be reached, and that playbooks
+// were loaded. The clusters aren't checked with the fake deployer.
+func (d *DeploymentService) Readiness(ctx context.Context) []Check {
+	checks := []Check{runCheck("store", func() error {
+		ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		defer cancel()
+		_, err := d.store.Value(ctx, d.Cfg.EtcdPath)
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	})}
+
+	if d.Cfg.Deployer != "fake" {
+		for _, name := range d.targetNames() {
+			name := name
+			check := runCheck("kubernetes", func() error {
+				c, err := d.cluster(name)
+				if err != nil {
+					return err
+				}
+				return c.Ping()
+			})
+			check.Detail = name
+			checks = append(checks, check)
+		}
+	}
+
+	playbooks := Check{Name: "playbooks", OK: len(d.playbooks) > 0, Detail: fmt.Sprintf("%d loaded", len(d.playbooks))}
+	if !playbooks.OK {
+		playbooks.Error = "no playbooks were loaded"
+	}
+	return append(checks, playbooks)
+}
+
+// Ready returns true if all checks passed
+func Ready(checks []Check) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// targetNames returns the default target and the configured ones, sorted
+func (d *DeploymentService) targetNames() []string {
+	names := []string{d.Cfg.DefaultTarget}
+	for name := range d.Cfg.Targets {
+		if name != d.Cfg.DefaultTarget {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runCheck runs check, failing it if it takes longer than readinessTimeout
+func runCheck(name string, check func() error) Check {
+	done := make(chan error, 1)
+	go func() {
+		done <- check()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(readinessTimeout):
+		err = errors.New("timed out")
+	}
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+	return Check{Name: name, OK: true}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	config := testutils.TestCfg
+	config.Deployer = "fake"
+	playbooks := map[string]*deployment.Playbook{"web": {ID: "web"}}
+	checks := NewDeploymentService(config, store.NewMemory(), playbooks, nil).Readiness(ctx)
+	assert.Equal(t, []Check{
+		{Name: "store", OK: true},
+		{Name: "playbooks", OK: true, Detail: "1 loaded"},
+	}, checks, "clusters aren't checked with the fake deployer")
+	assert.True(t, Ready(checks))
+
+	unavailable := &store.FakeStore{MockValue: func(path string) (string, error) {
+		return "", &store.UnavailableError{Op: "get", Path: path, Err: context.DeadlineExceeded}
+	}}
+	config.Deployer = "kubernetes"
+	config.DefaultTarget = "down"
+	config.Targets = map[string]cfg.Target{"down": {Host: "http://127.0.0.1:1"}}
+	checks = NewDeploymentService(config, unavailable, nil, nil).Readiness(ctx)
+	assert.False(t, Ready(checks))
+	if assert.Len(t, checks, 3) {
+		assert.Equal(t, "store", checks[0].Name)
+		assert.Contains(t, checks[0].Error, "context deadline exceeded")
+		assert.Equal(t, "kubernetes", checks[1].Name)
+		assert.Equal(t, "down", checks[1].Detail)
+		assert.False(t, checks[1].OK, "the target's API can't be reached")
+		assert.Equal(t, Check{Name: "playbooks", Detail: "0 loaded", Error: "no playbooks were loaded"}, checks[2])
+	}
+}
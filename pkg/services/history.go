@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/metrics"
+	"golang.org/x/net/context"
+)
+
+// startRecord begins a history record for an action taken on i by the actor
+// found in ctx
+func (d *DeploymentService) startRecord(ctx context.Context, i *instance.Instance, action string) *history.Record {
+	return history.NewRecord(i.PlaybookID, i.ID, action, actor.FromContext(ctx).String(), i.Vars, time.Now())
+}
+
+// finishRecord completes rec with the error err points to and saves it,
+// pruning the instance's history down to the configured retention, and
+// reports the action that started at started to the metrics. Failing to
+// record history never fails the action itself.
+func (d *DeploymentService) finishRecord(ctx context.Context, rec *history.Record, started time.Time, errp *error) {
+	err := *errp
+	metrics.Operation(rec.PlaybookID, rec.Action, outcome(ctx, err), time.Since(started))
+	rec.Finish(err, time.Now())
+	if err := history.Save(ctx, d.store, d.Cfg.EtcdPath, rec); err != nil {
+		glog.Errorf("Failed to save %s history for %s/%s: %s", rec.Action, rec.PlaybookID, rec.InstanceID, err)
+		return
+	}
+	p := history.Path{RootPath: d.Cfg.EtcdPath, PlaybookID: rec.PlaybookID, InstanceID: rec.InstanceID}
+	if err := history.Prune(ctx, d.store, p, d.Cfg.HistoryRetention); err != nil {
+		glog.Warningf("Failed to prune history for %s/%s: %s", rec.PlaybookID, rec.InstanceID, err)
+	}
+}
+
+// recordSteps returns a StepObserver appending each step's outcome to rec
+// and reporting it to the metrics
+func recordSteps(rec *history.Record) deployment.StepObserver {
+	return func(r deployment.StepResult) {
+		rec.Steps = append(rec.Steps, historyStep(r))
+		outcome := metrics.OutcomeSucceeded
+		if r.Err != nil {
+			outcome = metrics.OutcomeFailed
+		}
+		metrics.Step(rec.PlaybookID, rec.Action, r.Name, outcome, r.FinishedAt.Sub(r.StartedAt))
+	}
+}
+
+// outcome classifies how an action run with ctx ended for the metrics
+func outcome(ctx context.Context, err error) string {
+	if err == nil {
+		return metrics.OutcomeSucceeded
+	}
+	if _, ok := cancelled(ctx); ok {
+		return metrics.OutcomeCancelled
+	}
+	return metrics.OutcomeFailed
+}
+
+// historyStep converts the outcome of a deployment step for recording
+func historyStep(r deployment.StepResult) history.Step {
+	step := history.Step{
+		Name:       r.Name,
+		StartedAt:  r.StartedAt.Unix(),
+		FinishedAt: r.FinishedAt.Unix(),
+	}
+	if r.Err != nil {
+		step.Error = r.Err.Error()
+	}
+	return step
+}
+
+// History returns the deploy and stop history of an instance, newest first
+func (is *InstanceService) History(ctx context.Context, playbookID, ID string) ([]*history.Record, error) {
+	p := history.Path{RootPath: is.Cfg.EtcdPath, PlaybookID: playbookID, InstanceID: ID}
+	records, err := history.List(ctx, is.store, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		// Distinguish an instance without history from a missing one
+		if _, err := is.Show(ctx, playbookID, ID); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
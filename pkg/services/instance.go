@@ -8,11 +8,13 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/audit"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
 	"github.com/namely/broadway/pkg/instance"
 	"github.com/namely/broadway/pkg/notification"
 	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
 )
 
 var sanitizer = regexp.MustCompile(`[^a-zA-Z0-9\-]`)
@@ -62,6 +64,17 @@ func (e *InvalidID) Error() string {
 	return fmt.Sprintf("%s is an invalid id; valid characters are dash and alphanumerics. Try %s", e.badID, e.suggestedID)
 }
 
+// InstanceExists indicates an instance can't be created because its id is
+// already taken
+type InstanceExists struct {
+	playbookID string
+	id         string
+}
+
+func (e *InstanceExists) Error() string {
+	return fmt.Sprintf("Instance %s/%s already exists", e.playbookID, e.id)
+}
+
 func validateID(id string) error {
 	match := validator.FindStringIndex(id)
 	if match == nil {
@@ -78,26 +91,88 @@ func validateID(id string) error {
 }
 
 // CreateOrUpdate a new instance
-func (is *InstanceService) CreateOrUpdate(i *instance.Instance) (*instance.Instance, error) {
+func (is *InstanceService) CreateOrUpdate(ctx context.Context, i *instance.Instance) (*instance.Instance, error) {
+	return is.createOrUpdate(ctx, i, "")
+}
+
+// Clone creates instance newID from the vars of an existing instance of the
+// same playbook, with overrides applied on top. The clone gets its own
+// expiration and starts out undeployed.
+func (is *InstanceService) Clone(ctx context.Context, playbookID, ID, newID string, overrides map[string]string) (*instance.Instance, error) {
+	src, err := is.Show(ctx, playbookID, ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateID(newID); err != nil {
+		return nil, err
+	}
+	_, err = is.Show(ctx, playbookID, newID)
+	if err == nil {
+		return nil, &InstanceExists{playbookID, newID}
+	}
+	if _, ok := err.(instance.NotFoundError); !ok {
+		return nil, err
+	}
+
+	vars := map[string]string{}
+	for k, v := range src.Vars {
+		vars[k] = v
+	}
+	for k, v := range overrides {
+		vars[k] = v
+	}
+	i := &instance.Instance{PlaybookID: playbookID, ID: newID, Vars: vars, Target: src.Target}
+	return is.createOrUpdate(ctx, i, src.ID)
+}
+
+// createOrUpdate saves i, announcing the instance it was cloned from if any
+func (is *InstanceService) createOrUpdate(ctx context.Context, i *instance.Instance, clonedFrom string) (*instance.Instance, error) {
 	path := instance.Path{RootPath: is.Cfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
 	i.Path = path
 	if err := validateID(i.ID); err != nil {
 		return nil, err
 	}
 
-	existing, err := instance.FindByPath(is.store, path)
+	existing, err := instance.FindByPath(ctx, is.store, path)
 	if err != nil {
-		if err == instance.ErrMalformedSaveData {
+		if _, ok := err.(instance.NotFoundError); !ok {
 			return nil, err
 		}
 		now := time.Now()
 		i.Created = now.Unix()
+		i.LastError = ""
+		i.Timestamps = nil
+		i.Stamp(now)
 		if i.ExpiredAt == 0 {
-			expiredAt := instance.NewExpiredAt(is.Cfg.InstanceExpirationDays, now)
-			i.ExpiredAt = expiredAt.Unix()
+			i.ExpiredAt = is.expiration(i.PlaybookID, now).Unix()
 		}
 	} else {
 		i.Status = existing.Status
+		i.Timestamps = existing.Timestamps
+		i.LastError = existing.LastError
+		i.WarnedFor = existing.WarnedFor
+		i.Drift = existing.Drift
+		i.DriftedAt = existing.DriftedAt
+		i.Revision = existing.Revision
+		// A deploy or stop may be running, whose lease must survive:
+		i.Owner = existing.Owner
+		i.Operation = existing.Operation
+		i.Heartbeat = existing.Heartbeat
+		// Only pin and unpin change whether an existing instance is pinned:
+		i.Pinned = existing.Pinned
+		if i.ExpiredAt == 0 {
+			i.ExpiredAt = existing.ExpiredAt
+		}
+		if i.Target == "" {
+			i.Target = existing.Target
+		}
+		movable := existing.Status == instance.StatusNew || existing.Status == instance.StatusStopped
+		if existing.Target != "" && i.Target != existing.Target && !movable {
+			return nil, &TargetInUse{i.PlaybookID, i.ID, existing.Target}
+		}
+	}
+	if err := checkTarget(is.Cfg, i.Target); err != nil {
+		return nil, err
 	}
 
 	pb, ok := deployment.AllPlaybooks[i.PlaybookID]
@@ -134,12 +209,22 @@ func (is *InstanceService) CreateOrUpdate(i *instance.Instance) (*instance.Insta
 	}
 	i.Vars = vars
 
-	err = instance.Save(is.store, i)
+	err = instance.Save(ctx, is.store, i)
 	if err != nil {
 		return nil, err
 	}
+	action := audit.ActionCreate
+	if existing != nil {
+		action = audit.ActionUpdate
+	}
+	e := newAuditEntry(ctx, action, i.PlaybookID, i.ID)
+	e.Before, e.After = summarize(existing), summarize(i)
+	if clonedFrom != "" {
+		e.Detail = "cloned from " + clonedFrom
+	}
+	recordAudit(ctx, is.Cfg, is.store, e)
 
-	err = sendNotification(is.Cfg, existing != nil, i)
+	err = sendNotification(is.Cfg, existing != nil, i, clonedFrom)
 	if err != nil {
 		return nil, err
 	}
@@ -147,22 +232,61 @@ func (is *InstanceService) CreateOrUpdate(i *instance.Instance) (*instance.Insta
 }
 
 // Update an instance
-func (is *InstanceService) Update(i *instance.Instance) (*instance.Instance, error) {
+func (is *InstanceService) Update(ctx context.Context, i *instance.Instance) (*instance.Instance, error) {
 	glog.Info("Instance Service: Update")
 	path := instance.Path{is.Cfg.EtcdPath, i.PlaybookID, i.ID}
 	i.Path = path
-	err := instance.Save(is.store, i)
+	err := instance.Save(ctx, is.store, i)
 	if err != nil {
 		return nil, err
 	}
 	return i, nil
 }
 
+// Extend pushes back the expiration of an instance by d
+func (is *InstanceService) Extend(ctx context.Context, playbookID, ID string, d time.Duration) (*instance.Instance, error) {
+	i, err := is.Show(ctx, playbookID, ID)
+	if err != nil {
+		return nil, err
+	}
+	before := summarize(i)
+	i.Extend(d, time.Now())
+	return is.audited(ctx, audit.ActionExtend, before, i)
+}
+
+// SetPinned pins or unpins an instance. Pinned instances never expire.
+func (is *InstanceService) SetPinned(ctx context.Context, playbookID, ID string, pinned bool) (*instance.Instance, error) {
+	i, err := is.Show(ctx, playbookID, ID)
+	if err != nil {
+		return nil, err
+	}
+	before := summarize(i)
+	i.Pinned = pinned
+	action := audit.ActionUnpin
+	if pinned {
+		action = audit.ActionPin
+	}
+	return is.audited(ctx, action, before, i)
+}
+
+// audited updates i, recording the action that changed it from before in the
+// audit log
+func (is *InstanceService) audited(ctx context.Context, action string, before *audit.Summary, i *instance.Instance) (*instance.Instance, error) {
+	i, err := is.Update(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	e := newAuditEntry(ctx, action, i.PlaybookID, i.ID)
+	e.Before, e.After = before, summarize(i)
+	recordAudit(ctx, is.Cfg, is.store, e)
+	return i, nil
+}
+
 // Show takes playbookID and instanceID and returns the matching Instance, if
 // any
-func (is *InstanceService) Show(playbookID, ID string) (*instance.Instance, error) {
+func (is *InstanceService) Show(ctx context.Context, playbookID, ID string) (*instance.Instance, error) {
 	path := instance.Path{is.Cfg.EtcdPath, playbookID, ID}
-	instance, err := instance.FindByPath(is.store, path)
+	instance, err := instance.FindByPath(ctx, is.store, path)
 	if err != nil {
 		return instance, err
 	}
@@ -170,23 +294,23 @@ func (is *InstanceService) Show(playbookID, ID string) (*instance.Instance, erro
 }
 
 // AllWithPlaybookID returns all the instances for an specified playbook id
-func (is *InstanceService) AllWithPlaybookID(playbookID string) ([]*instance.Instance, error) {
+func (is *InstanceService) AllWithPlaybookID(ctx context.Context, playbookID string) ([]*instance.Instance, error) {
 	playbookPath := instance.PlaybookPath{is.Cfg.EtcdPath, playbookID}
-	return instance.FindByPlaybookPath(is.store, playbookPath)
+	return instance.FindByPlaybookPath(ctx, is.store, playbookPath)
 }
 
 // Delete removes an instance
-func (is *InstanceService) Delete(i *instance.Instance) error {
-	_, err := is.Show(i.PlaybookID, i.ID)
+func (is *InstanceService) Delete(ctx context.Context, i *instance.Instance) error {
+	_, err := is.Show(ctx, i.PlaybookID, i.ID)
 	if err != nil {
 		return err
 	}
 
 	path := instance.Path{is.Cfg.EtcdPath, i.PlaybookID, i.ID}
-	return instance.Delete(is.store, path)
+	return instance.Delete(ctx, is.store, path)
 }
 
-func sendNotification(cfg cfg.Type, update bool, i *instance.Instance) error {
+func sendNotification(cfg cfg.Type, update bool, i *instance.Instance, clonedFrom string) error {
 	pb, ok := deployment.AllPlaybooks[i.PlaybookID]
 	if !ok {
 		return fmt.Errorf("Failed to lookup playbook for instance %+v", *i)
@@ -197,9 +321,13 @@ func sendNotification(cfg cfg.Type, update bool, i *instance.Instance) error {
 		s = "updated"
 	}
 
+	text := fmt.Sprintf("Broadway instance was %s: %s %s.", s, i.PlaybookID, i.ID)
+	if clonedFrom != "" {
+		text = fmt.Sprintf("Broadway instance was cloned from %s: %s %s.", clonedFrom, i.PlaybookID, i.ID)
+	}
 	atts := []notification.Attachment{
 		{
-			Text: fmt.Sprintf("Broadway instance was %s: %s %s.", s, i.PlaybookID, i.ID),
+			Text: text,
 		},
 	}
 	tp, ok := pb.Messages["created"]
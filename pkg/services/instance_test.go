@@ -5,12 +5,14 @@ import (
 	"time"
 
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
 	"github.com/namely/broadway/pkg/store/etcdstore"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func cleanup() {
-	etcdstore.New().Delete(ServicesTestCfg.EtcdPath + "/instances")
+	etcdstore.New().Delete(context.Background(), ServicesTestCfg.EtcdPath+"/instances")
 }
 
 func TestCreateInstanceFromMissingPlaybook(t *testing.T) {
@@ -19,7 +21,7 @@ func TestCreateInstanceFromMissingPlaybook(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, etcdstore.New())
 
 	i := &instance.Instance{PlaybookID: "vanishing-pb", ID: "gone"}
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "playbook vanishing-pb is missing")
@@ -31,7 +33,7 @@ func TestCreateInstanceWithoutExpiredAt(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, etcdstore.New())
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCreateInstancegone"}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, ii.ExpiredAt)
@@ -45,7 +47,7 @@ func TestCreateInstanceWithIncorrectVars(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCreateInstanceWithIncorrectVars", Vars: map[string]string{"metal": "plutonium"}}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, ii)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "does not declare a var named metal")
@@ -59,7 +61,7 @@ func TestCreateInstanceNotification(t *testing.T) {
 	store := etcdstore.New()
 	is := NewInstanceService(ServicesTestCfg, store)
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCreateInstanceNotification"}
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, err)
 
 	assert.Contains(t, nt.requestBody, "created")
@@ -75,7 +77,7 @@ func TestCreateInstanceCustomNotification(t *testing.T) {
 	store := etcdstore.New()
 	is := NewInstanceService(ServicesTestCfg, store)
 	i := &instance.Instance{PlaybookID: "messagesplaybook", ID: "TestCreateInstanceCustomNotification"}
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, err)
 
 	assert.Contains(t, nt.requestBody, "custom created")
@@ -90,7 +92,7 @@ func TestCreateInstanceWithInvalidId(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "Test*Create_Instance"}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, ii)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Test*Create_Instance")
@@ -105,7 +107,7 @@ func TestCreateInstance(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCreateInstance"}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, err)
 	assert.Equal(t, "helloplaybook", ii.PlaybookID)
 	assert.Equal(t, instance.StatusNew, ii.Status)
@@ -129,11 +131,11 @@ func TestUpdateInstance(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestUpdateInstance", Status: instance.StatusDeployed}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, err)
 
 	ii.Vars["word"] = "test"
-	iii, err := is.CreateOrUpdate(ii)
+	iii, err := is.CreateOrUpdate(context.Background(), ii)
 
 	assert.Nil(t, err)
 	assert.Equal(t, "helloplaybook", iii.PlaybookID)
@@ -151,7 +153,7 @@ func TestShow(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestShow"}
-	ii, err := is.CreateOrUpdate(i)
+	ii, err := is.CreateOrUpdate(context.Background(), i)
 	assert.Nil(t, err)
 	assert.Equal(t, "helloplaybook", ii.PlaybookID)
 	assert.Equal(t, "TestShow", ii.ID)
@@ -162,7 +164,7 @@ func TestShowMissingInstance(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, store)
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "broken"}
-	i, err := is.Show(i.PlaybookID, i.ID)
+	i, err := is.Show(context.Background(), i.PlaybookID, i.ID)
 	assert.NotNil(t, err)
 	assert.Nil(t, i, "Instance should be nil")
 }
@@ -174,12 +176,12 @@ func TestAllWithPlaybookID(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, etcdstore.New())
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestAllWithPlaybookID"}
-	_, err := is.CreateOrUpdate(i)
+	_, err := is.CreateOrUpdate(context.Background(), i)
 	if err != nil {
 		t.Fatal("TestAllWithPlaybookID: ", err)
 	}
 
-	instances, err := is.AllWithPlaybookID(i.PlaybookID)
+	instances, err := is.AllWithPlaybookID(context.Background(), i.PlaybookID)
 	assert.Nil(t, err)
 	assert.NotEmpty(t, instances)
 	assert.Contains(t, nt.requestBody, "created")
@@ -209,14 +211,14 @@ func TestUpdate(t *testing.T) {
 	}
 
 	for _, testcase := range testcases {
-		createdInstance, err := instanceService.CreateOrUpdate(testcase.Instance)
+		createdInstance, err := instanceService.CreateOrUpdate(context.Background(), testcase.Instance)
 		if err != nil {
 			t.Fatal(testcase.Scenario, err)
 		}
 		createdInstance.PlaybookID = testcase.ExpectedPlaybookID
 		createdInstance.ID = testcase.ExpectedID
 		createdInstance.Vars = testcase.ExpectedVars
-		updatedInstance, err := instanceService.Update(createdInstance)
+		updatedInstance, err := instanceService.Update(context.Background(), createdInstance)
 
 		assert.Equal(t, testcase.ExpectedPlaybookID, updatedInstance.PlaybookID)
 		assert.Equal(t, testcase.E, err, testcase.Scenario)
@@ -231,11 +233,11 @@ func TestDeleteWhenExistentInstance(t *testing.T) {
 
 	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "new"}
 
-	createdInstance, err := is.CreateOrUpdate(i)
+	createdInstance, err := is.CreateOrUpdate(context.Background(), i)
 	if err != nil {
 		t.Log(err)
 	}
-	err = is.Delete(createdInstance)
+	err = is.Delete(context.Background(), createdInstance)
 	assert.Nil(t, err, "When instance exists")
 }
 
@@ -244,7 +246,55 @@ func TestDeleteWhenNonExistantInstance(t *testing.T) {
 	is := NewInstanceService(ServicesTestCfg, etcdstore.New())
 	i := &instance.Instance{PlaybookID: "random", ID: "bar"}
 
-	err := is.Delete(i)
+	err := is.Delete(context.Background(), i)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "was not found", "When non-existent instance")
 }
+
+func TestCloneInstance(t *testing.T) {
+	cleanup()
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	store := etcdstore.New()
+	is := NewInstanceService(ServicesTestCfg, store)
+
+	src := &instance.Instance{PlaybookID: "helloplaybook", ID: "TestCloneInstance", Vars: map[string]string{"word": "staging"}, Pinned: true}
+	_, err := is.CreateOrUpdate(context.Background(), src)
+	assert.Nil(t, err)
+
+	clone, err := is.Clone(context.Background(), "helloplaybook", "TestCloneInstance", "TestCloneInstance-mine", map[string]string{"word": "branch"})
+	assert.Nil(t, err)
+	assert.Equal(t, "branch", clone.Vars["word"])
+	assert.Equal(t, instance.StatusNew, clone.Status)
+	assert.False(t, clone.Pinned, "clones get their own expiration")
+	assert.NotEmpty(t, clone.ExpiredAt)
+	assert.Contains(t, nt.requestBody, "cloned from TestCloneInstance")
+
+	_, err = is.Clone(context.Background(), "helloplaybook", "TestCloneInstance", "TestCloneInstance-mine", nil)
+	assert.IsType(t, &InstanceExists{}, err)
+
+	_, err = is.Clone(context.Background(), "helloplaybook", "TestCloneInstance", "Test*Clone", nil)
+	assert.IsType(t, &InvalidID{}, err)
+
+	_, err = is.Clone(context.Background(), "helloplaybook", "TestCloneInstance", "TestCloneInstance-metal", map[string]string{"metal": "plutonium"})
+	assert.IsType(t, &InvalidVar{}, err)
+}
+
+func TestUpdateKeepsPinned(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	is := NewInstanceService(ServicesTestCfg, store.NewMemory())
+
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "pinned", Pinned: true})
+	assert.Nil(t, err)
+	i, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "pinned", Pinned: false})
+	assert.Nil(t, err)
+	assert.True(t, i.Pinned, "updates leave pinning to unpin")
+
+	_, err = is.SetPinned(ctx, "helloplaybook", "pinned", false)
+	assert.Nil(t, err)
+	i, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "pinned", Pinned: true})
+	assert.Nil(t, err)
+	assert.False(t, i.Pinned, "updates leave pinning to pin")
+}
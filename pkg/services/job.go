@@ -0,0 +1,571 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/audit"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"golang.org/x/net/context"
+)
+
+// DefaultWorkers is used when the configuration doesn't set how many jobs run
+// at once
+const DefaultWorkers = 4
+
+// JobService runs deploys and stops in the background, tracking each as a job
+// that can be looked up while it runs and after it finishes. Jobs wait in a
+// queue until a worker is free, both globally and for their playbook, and run
+// in the order they were queued unless their playbook or instance is busy.
+// Jobs on the same instance never run at once.
+type JobService struct {
+	ds      *DeploymentService
+	running sync.WaitGroup
+
+	mu     sync.Mutex
+	queue  []*queuedJob
+	active map[string]*queuedJob
+}
+
+// queuedJob is a job waiting for a worker along with what it runs. The job
+// runs on the instance as stored when it starts, since other jobs may have
+// changed it while it was waiting.
+type queuedJob struct {
+	job          *job.Job
+	ctx          context.Context
+	run          func(context.Context, *instance.Instance) error
+	cancellation *cancellation
+}
+
+// NewJobService creates a new JobService running jobs with ds
+func NewJobService(ds *DeploymentService) *JobService {
+	return &JobService{ds: ds, active: map[string]*queuedJob{}}
+}
+
+// Deploy starts a job deploying i. A deploy requested while another job on i
+// is waiting or running is queued to run once it finishes, and one requested
+// while a deploy of i is queued already is folded into it instead. Since jobs
+// run on the instance as stored when they start, the deploy uses the latest
+// vars either way. Otherwise the deploy is refused right away if i can't be
+// deployed in its current status.
+func (js *JobService) Deploy(ctx context.Context, i *instance.Instance) (*job.Job, error) {
+	js.mu.Lock()
+	if q := js.waiting(i.PlaybookID, i.ID); q != nil && q.job.Action == job.ActionDeploy {
+		q.job.Coalesced++
+		snapshot := *q.job
+		snapshot.Position = js.position(q.job.ID)
+		js.mu.Unlock()
+		glog.Infof("Folded a deploy of %s/%s into job %s", i.PlaybookID, i.ID, q.job.ID)
+		js.save(ctx, q.job)
+		js.audit(ctx, audit.ActionDeploy, i, &snapshot, "folded into a queued deploy")
+		return &snapshot, nil
+	}
+	js.mu.Unlock()
+
+	if !js.pending(i.PlaybookID, i.ID) {
+		if err := canStart(i, instance.StatusDeploying); err != nil {
+			return nil, err
+		}
+	}
+	j, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+		return js.ds.DeployAndNotify(ctx, i)
+	})
+	return js.audit(ctx, audit.ActionDeploy, i, j, ""), err
+}
+
+// Stop starts a job stopping i. It's refused right away if i can't be stopped
+// in its current status.
+func (js *JobService) Stop(ctx context.Context, i *instance.Instance) (*job.Job, error) {
+	if err := canStart(i, instance.StatusDeleting); err != nil {
+		return nil, err
+	}
+	j, err := js.start(ctx, job.ActionStop, i, func(ctx context.Context, i *instance.Instance) error {
+		return js.ds.StopAndNotify(ctx, i)
+	})
+	return js.audit(ctx, audit.ActionStop, i, j, ""), err
+}
+
+// Delete starts a job stopping i and then deleting it from Broadway
+func (js *JobService) Delete(ctx context.Context, i *instance.Instance) (*job.Job, error) {
+	if err := canStart(i, instance.StatusDeleting); err != nil {
+		return nil, err
+	}
+	j, err := js.start(ctx, job.ActionDelete, i, func(ctx context.Context, i *instance.Instance) error {
+		if err := js.ds.StopAndNotify(ctx, i); err != nil {
+			return err
+		}
+		return NewInstanceService(js.ds.Cfg, js.ds.store).Delete(ctx, i)
+	})
+	return js.audit(ctx, audit.ActionDelete, i, j, ""), err
+}
+
+// Rollback starts a job rolling i back to revision n, or to its previous
+// revision if n is 0. It's refused right away if the revision doesn't exist.
+func (js *JobService) Rollback(ctx context.Context, i *instance.Instance, n int) (*job.Job, error) {
+	if _, err := js.ds.rollbackRevision(ctx, i, n); err != nil {
+		return nil, err
+	}
+	if err := canStart(i, instance.StatusDeploying); err != nil {
+		return nil, err
+	}
+	j, err := js.start(ctx, job.ActionRollback, i, func(ctx context.Context, i *instance.Instance) error {
+		_, err := js.ds.RollbackAndNotify(ctx, i, n)
+		return err
+	})
+	detail := "to the previous revision"
+	if n > 0 {
+		detail = fmt.Sprintf("to revision %d", n)
+	}
+	return js.audit(ctx, audit.ActionRollback, i, j, detail), err
+}
+
+// audit records that the actor of ctx requested job j on i, unless the job
+// couldn't be started. It returns j.
+func (js *JobService) audit(ctx context.Context, action string, i *instance.Instance, j *job.Job, detail string) *job.Job {
+	if j == nil {
+		return nil
+	}
+	e := newAuditEntry(ctx, action, i.PlaybookID, i.ID)
+	e.JobID = j.ID
+	e.Detail = detail
+	e.Before = summarize(i)
+	recordAudit(ctx, js.ds.Cfg, js.ds.store, e)
+	return j
+}
+
+// Show returns the job with the given id, along with its position in the
+// queue if it's waiting for a worker
+func (js *JobService) Show(ctx context.Context, id string) (*job.Job, error) {
+	j, err := job.Find(ctx, js.ds.store, js.ds.Cfg.EtcdPath, id)
+	if err != nil {
+		return nil, err
+	}
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	j.Position = js.position(id)
+	return j, nil
+}
+
+// Cancel cancels a job on behalf of the actor found in ctx. A queued job is
+// dropped from the queue, while a running job's deploy or stop gives up as
+// soon as it can, skipping the steps that haven't started. Only jobs queued
+// or run by this broadway process can be cancelled.
+func (js *JobService) Cancel(ctx context.Context, id string) (*job.Job, error) {
+	by := actor.FromContext(ctx).String()
+	js.mu.Lock()
+	j, ok := js.cancel(id, by)
+	js.mu.Unlock()
+	if ok {
+		js.cancelled(ctx, j)
+		js.auditCancel(ctx, j)
+		return j, nil
+	}
+
+	j, err := job.Find(ctx, js.ds.store, js.ds.Cfg.EtcdPath, id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &JobNotCancellable{ID: j.ID, State: j.State}
+}
+
+// CancelInstance cancels every job queued or running on an instance on behalf
+// of the actor found in ctx
+func (js *JobService) CancelInstance(ctx context.Context, playbookID, ID string) ([]*job.Job, error) {
+	by := actor.FromContext(ctx).String()
+	cancelled := []*job.Job{}
+	js.mu.Lock()
+	ids := []string{}
+	for _, a := range js.active {
+		if a.job.PlaybookID == playbookID && a.job.InstanceID == ID {
+			ids = append(ids, a.job.ID)
+		}
+	}
+	for _, q := range js.queue {
+		if q.job.PlaybookID == playbookID && q.job.InstanceID == ID {
+			ids = append(ids, q.job.ID)
+		}
+	}
+	for _, id := range ids {
+		if j, ok := js.cancel(id, by); ok {
+			cancelled = append(cancelled, j)
+		}
+	}
+	js.mu.Unlock()
+	if len(cancelled) == 0 {
+		return nil, &NoJobs{PlaybookID: playbookID, InstanceID: ID}
+	}
+	for _, j := range cancelled {
+		js.cancelled(ctx, j)
+		js.auditCancel(ctx, j)
+	}
+	return cancelled, nil
+}
+
+// cancel cancels the queued or running job with the given id on behalf of by,
+// returning a snapshot of it. It must be called with js.mu held.
+func (js *JobService) cancel(id, by string) (*job.Job, bool) {
+	if a, ok := js.active[id]; ok {
+		a.cancellation.Cancel(by)
+		a.job.CancelledBy = by
+		snapshot := *a.job
+		return &snapshot, true
+	}
+	for n, q := range js.queue {
+		if q.job.ID != id {
+			continue
+		}
+		js.queue = append(js.queue[:n], js.queue[n+1:]...)
+		q.job.Cancel(by, time.Now())
+		js.running.Done()
+		snapshot := *q.job
+		return &snapshot, true
+	}
+	return nil, false
+}
+
+// cancelled records and announces the cancellation of a job that was still
+// queued. Running jobs do so themselves once their deploy or stop gave up.
+func (js *JobService) cancelled(ctx context.Context, j *job.Job) {
+	if j.State != job.StateCancelled {
+		return
+	}
+	if err := job.Save(ctx, js.ds.store, js.ds.Cfg.EtcdPath, j); err != nil {
+		glog.Warningf("Failed to save job %s: %s", j.ID, err)
+	}
+	msg := fmt.Sprintf("Job %s to %s %s/%s was cancelled by %s before it started", j.ID, j.Action, j.PlaybookID, j.InstanceID, j.CancelledBy)
+	notify(js.ds.Cfg, nil, msg)
+}
+
+// auditCancel records that the actor of ctx cancelled j
+func (js *JobService) auditCancel(ctx context.Context, j *job.Job) {
+	e := newAuditEntry(ctx, audit.ActionCancel, j.PlaybookID, j.InstanceID)
+	e.JobID = j.ID
+	e.Detail = "cancelled a " + j.Action
+	recordAudit(ctx, js.ds.Cfg, js.ds.store, e)
+}
+
+// Queue returns the jobs running right now and the jobs waiting for a worker,
+// in the order they were queued
+func (js *JobService) Queue() (running []*job.Job, queued []*job.Job) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	running = []*job.Job{}
+	for _, a := range js.active {
+		r := *a.job
+		running = append(running, &r)
+	}
+	sort.Sort(jobsByID(running))
+	queued = []*job.Job{}
+	for n, q := range js.queue {
+		w := *q.job
+		w.Position = n + 1
+		queued = append(queued, &w)
+	}
+	return running, queued
+}
+
+// Cleanup applies the playbooks' lifecycle policies like
+// DeploymentService.Cleanup, but stops instances through jobs so they share
+// the workers with every other deploy and stop. Instances that already have
+// a job waiting or running are left for later.
+func (js *JobService) Cleanup(ctx context.Context, now time.Time, dryRun bool) ([]*CleanupAction, error) {
+	return js.ds.cleanup(ctx, now, dryRun, js.runCleanup)
+}
+
+func (js *JobService) runCleanup(ctx context.Context, i *instance.Instance, a *CleanupAction) {
+	if !a.Stop {
+		js.ds.runCleanup(ctx, i, a)
+		return
+	}
+	if js.pending(i.PlaybookID, i.ID) {
+		return
+	}
+	action := job.ActionStop
+	if a.Delete {
+		action = job.ActionDelete
+	}
+	planned := *a
+	j, err := js.start(ctx, action, i, func(ctx context.Context, i *instance.Instance) error {
+		js.ds.runCleanup(ctx, i, &planned)
+		if planned.Error != "" {
+			return errors.New(planned.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		glog.Errorf("Failed to queue the cleanup of %s/%s: %s", i.PlaybookID, i.ID, err)
+		a.Error = err.Error()
+		return
+	}
+	a.JobID = j.ID
+}
+
+// Prune deletes the jobs that finished before the given time
+func (js *JobService) Prune(ctx context.Context, before time.Time) error {
+	return job.Prune(ctx, js.ds.store, js.ds.Cfg.EtcdPath, before)
+}
+
+// ErrJobAbandoned fails the jobs left unfinished by a broadway process that
+// went away
+var ErrJobAbandoned = errors.New("broadway went away before the job finished")
+
+// FailAbandoned marks the jobs left queued or running by a broadway process
+// that went away as failed, so that they're reported as finished and pruned
+// in time. It's meant for startup, when the unfinished jobs this process
+// doesn't know about were left by a previous one.
+func (js *JobService) FailAbandoned(ctx context.Context, now time.Time) error {
+	jobs, err := job.List(ctx, js.ds.store, js.ds.Cfg.EtcdPath)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if j.Done() || js.known(j.ID) {
+			continue
+		}
+		glog.Infof("Failing job %s abandoned while it was %s", j.ID, j.State)
+		j.Finish(ErrJobAbandoned, now)
+		if err := job.Save(ctx, js.ds.store, js.ds.Cfg.EtcdPath, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// known returns true if the job with the given id is waiting or running in
+// this process
+func (js *JobService) known(id string) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	_, ok := js.active[id]
+	return ok || js.position(id) > 0
+}
+
+// Wait blocks until every job started so far has finished
+func (js *JobService) Wait() {
+	js.running.Wait()
+}
+
+// canStart refuses to start a job that would move i to a status it can't move
+// to from its current one
+func canStart(i *instance.Instance, to instance.Status) error {
+	if !instance.CanTransition(i.Status, to) {
+		return &instance.InvalidTransition{From: i.Status, To: to}
+	}
+	return nil
+}
+
+// start saves a new job for action on i and queues it. The job outlives the
+// request that started it, so it gets its own context carrying the request's
+// actor. The returned job is a snapshot taken once the job was queued, or
+// started if a worker was free.
+func (js *JobService) start(ctx context.Context, action string, i *instance.Instance, run func(context.Context, *instance.Instance) error) (*job.Job, error) {
+	j := job.New(action, i.PlaybookID, i.ID, actor.FromContext(ctx).String(), time.Now())
+	if playbook, ok := js.ds.playbooks[i.PlaybookID]; ok {
+		j.TotalSteps = len(playbook.Manifests)
+	}
+	if err := job.Save(ctx, js.ds.store, js.ds.Cfg.EtcdPath, j); err != nil {
+		return nil, err
+	}
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.running.Add(1)
+	js.queue = append(js.queue, &queuedJob{job: j, ctx: actor.Detach(ctx), run: run})
+	js.dispatch()
+	snapshot := *j
+	snapshot.Position = js.position(j.ID)
+	return &snapshot, nil
+}
+
+// position returns the position of a job in the queue, or 0 if it isn't
+// waiting for a worker. It must be called with js.mu held.
+func (js *JobService) position(id string) int {
+	for n, q := range js.queue {
+		if q.job.ID == id {
+			return n + 1
+		}
+	}
+	return 0
+}
+
+// waiting returns the job queued last on the instance, if any. It must be
+// called with js.mu held.
+func (js *JobService) waiting(playbookID, ID string) *queuedJob {
+	for n := len(js.queue) - 1; n >= 0; n-- {
+		q := js.queue[n]
+		if q.job.PlaybookID == playbookID && q.job.InstanceID == ID {
+			return q
+		}
+	}
+	return nil
+}
+
+// dispatch hands queued jobs to free workers, oldest first, skipping the jobs
+// whose playbook has no free worker or whose instance is busy. It must be
+// called with js.mu held.
+func (js *JobService) dispatch() {
+	for n := 0; n < len(js.queue) && len(js.active) < js.workers(); {
+		q := js.queue[n]
+		if !js.runnable(q.job) {
+			n++
+			continue
+		}
+		js.queue = append(js.queue[:n], js.queue[n+1:]...)
+		js.active[q.job.ID] = q
+		q.ctx, q.cancellation = withCancellation(q.ctx)
+		q.job.Start(time.Now())
+		go js.run(q)
+	}
+}
+
+// runnable returns true if j's playbook has a free worker and no other job is
+// running on j's instance. It must be called with js.mu held.
+func (js *JobService) runnable(j *job.Job) bool {
+	busy := 0
+	for _, a := range js.active {
+		if a.job.PlaybookID != j.PlaybookID {
+			continue
+		}
+		if a.job.InstanceID == j.InstanceID {
+			return false
+		}
+		busy++
+	}
+	limit := js.playbookWorkers(j.PlaybookID)
+	return limit <= 0 || busy < limit
+}
+
+// pending returns true if a job on the instance is waiting or running
+func (js *JobService) pending(playbookID, ID string) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	for _, a := range js.active {
+		if a.job.PlaybookID == playbookID && a.job.InstanceID == ID {
+			return true
+		}
+	}
+	for _, q := range js.queue {
+		if q.job.PlaybookID == playbookID && q.job.InstanceID == ID {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs a dispatched job, then frees its worker for the next one
+func (js *JobService) run(q *queuedJob) {
+	defer js.running.Done()
+	j := q.job
+	glog.Infof("Running job %s: %s %s/%s", j.ID, j.Action, j.PlaybookID, j.InstanceID)
+	js.save(q.ctx, j)
+
+	path := instance.Path{RootPath: js.ds.Cfg.EtcdPath, PlaybookID: j.PlaybookID, ID: j.InstanceID}
+	i, err := instance.FindByPath(q.ctx, js.ds.store, path)
+	if err == nil {
+		err = q.run(withStepObserver(withJobAction(q.ctx, j.Action), func(r deployment.StepResult) {
+			js.mu.Lock()
+			j.Steps = append(j.Steps, historyStep(r))
+			js.mu.Unlock()
+			js.save(q.ctx, j)
+		}), i)
+	}
+
+	js.mu.Lock()
+	if by, ok := q.cancellation.cancelledBy(); ok && err != nil {
+		j.Cancel(by, time.Now())
+	} else {
+		j.Finish(err, time.Now())
+	}
+	delete(js.active, j.ID)
+	js.mu.Unlock()
+	js.save(q.ctx, j)
+	if err != nil {
+		glog.Errorf("Job %s failed to %s %s/%s: %s", j.ID, j.Action, j.PlaybookID, j.InstanceID, err)
+	} else {
+		glog.Infof("Job %s finished: %s %s/%s", j.ID, j.Action, j.PlaybookID, j.InstanceID)
+	}
+
+	js.mu.Lock()
+	js.dispatch()
+	js.mu.Unlock()
+}
+
+func (js *JobService) workers() int {
+	if js.ds.Cfg.Workers <= 0 {
+		return DefaultWorkers
+	}
+	return js.ds.Cfg.Workers
+}
+
+// playbookWorkers returns how many jobs of a playbook may run at once, where
+// 0 or less leaves it to the global limit
+func (js *JobService) playbookWorkers(playbookID string) int {
+	if pb, ok := js.ds.playbooks[playbookID]; ok && pb.Workers > 0 {
+		return pb.Workers
+	}
+	return js.ds.Cfg.PlaybookWorkers
+}
+
+// save stores the progress of a running job. Failing to do so never fails the
+// job itself.
+func (js *JobService) save(ctx context.Context, j *job.Job) {
+	js.mu.Lock()
+	snapshot := *j
+	js.mu.Unlock()
+	if err := job.Save(ctx, js.ds.store, js.ds.Cfg.EtcdPath, &snapshot); err != nil {
+		glog.Warningf("Failed to save job %s: %s", j.ID, err)
+	}
+}
+
+type jobsByID []*job.Job
+
+func (jj jobsByID) Len() int           { return len(jj) }
+func (jj jobsByID) Less(i, j int) bool { return jj[i].ID < jj[j].ID }
+func (jj jobsByID) Swap(i, j int)      { jj[i], jj[j] = jj[j], jj[i] }
+
+type observerKey struct{}
+
+// withStepObserver returns a context asking the deploys and stops run with it
+// to report each step to obs as well
+func withStepObserver(ctx context.Context, obs deployment.StepObserver) context.Context {
+	return context.WithValue(ctx, observerKey{}, obs)
+}
+
+// observeSteps returns a StepObserver appending each step's outcome to rec
+// and reporting it to the observer found in ctx, if any
+func observeSteps(ctx context.Context, rec *history.Record) deployment.StepObserver {
+	record := recordSteps(rec)
+	obs, ok := ctx.Value(observerKey{}).(deployment.StepObserver)
+	if !ok {
+		return record
+	}
+	return func(r deployment.StepResult) {
+		record(r)
+		obs(r)
+	}
+}
+
+type actionKey struct{}
+
+// withJobAction returns a context telling the deploys and stops run with it
+// which job action they're part of
+func withJobAction(ctx context.Context, action string) context.Context {
+	return context.WithValue(ctx, actionKey{}, action)
+}
+
+// jobAction returns the job action found in ctx, or fallback if the deploy or
+// stop isn't run by a job
+func jobAction(ctx context.Context, fallback string) string {
+	if action, ok := ctx.Value(actionKey{}).(string); ok {
+		return action
+	}
+	return fallback
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestJobStart(t *testing.T) {
+	s := store.NewMemory()
+	playbooks := map[string]*deployment.Playbook{
+		"helloplaybook": {ID: "helloplaybook", Manifests: []string{"hello-rc", "hello-service"}},
+	}
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, playbooks, nil))
+	ctx := actor.NewContext(context.Background(), actor.Actor{Source: actor.SourceSlack, Name: "bill"})
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "jobbed",
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "jobbed"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	release := make(chan struct{})
+	queued, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+		<-release
+		assert.Equal(t, "jobbed", i.ID, "jobs run on the stored instance")
+		rec := &history.Record{}
+		obs := observeSteps(ctx, rec)
+		obs(deployment.StepResult{Name: "hello-rc", StartedAt: time.Now(), FinishedAt: time.Now()})
+		obs(deployment.StepResult{Name: "hello-service", Err: errors.New("boom")})
+		assert.Len(t, rec.Steps, 2, "steps are still recorded in the history")
+		return errors.New("boom")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateRunning, queued.State, "jobs start right away when a worker is free")
+	assert.Equal(t, "slack:bill", queued.Actor)
+	assert.Equal(t, 2, queued.TotalSteps)
+
+	close(release)
+	js.Wait()
+
+	j, err := js.Show(context.Background(), queued.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateFailed, j.State)
+	assert.Equal(t, "boom", j.Error)
+	assert.NotZero(t, j.StartedAt)
+	assert.NotZero(t, j.FinishedAt)
+	if assert.Len(t, j.Steps, 2) {
+		assert.Equal(t, "hello-rc", j.Steps[0].Name)
+		assert.Equal(t, "boom", j.Steps[1].Error)
+	}
+}
+
+func TestJobRefused(t *testing.T) {
+	s := store.NewMemory()
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, nil, nil))
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "busy", Status: instance.StatusDeleting}
+
+	_, err := js.Deploy(context.Background(), i)
+	assert.Equal(t, &instance.InvalidTransition{From: instance.StatusDeleting, To: instance.StatusDeploying}, err)
+	_, err = js.Stop(context.Background(), i)
+	assert.Equal(t, &instance.InvalidTransition{From: instance.StatusDeleting, To: instance.StatusDeleting}, err)
+
+	jobs, err := job.List(context.Background(), s, testutils.TestCfg.EtcdPath)
+	assert.Nil(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestJobQueue(t *testing.T) {
+	s := store.NewMemory()
+	cfg := testutils.TestCfg
+	cfg.Workers = 2
+	cfg.PlaybookWorkers = 1
+	playbooks := map[string]*deployment.Playbook{
+		"a": {ID: "a"},
+		"b": {ID: "b", Workers: 2},
+	}
+	js := NewJobService(NewDeploymentService(cfg, s, playbooks, nil))
+	ctx := context.Background()
+
+	started := make(chan string, 10)
+	release := map[string]chan struct{}{}
+	queue := func(playbookID, ID string) *job.Job {
+		i := &instance.Instance{PlaybookID: playbookID, ID: ID, Path: instance.Path{cfg.EtcdPath, playbookID, ID}}
+		assert.Nil(t, instance.Save(ctx, s, i))
+		done := make(chan struct{})
+		j, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+			started <- i.PlaybookID + "/" + i.ID
+			<-done
+			return nil
+		})
+		assert.Nil(t, err)
+		release[j.ID] = done
+		return j
+	}
+
+	a1 := queue("a", "1")
+	assert.Equal(t, "a/1", <-started)
+	a2 := queue("a", "2")
+	assert.Equal(t, job.StateQueued, a2.State, "playbook a has a single worker")
+	assert.Equal(t, 1, a2.Position)
+	a1again := queue("a", "1")
+	assert.Equal(t, 2, a1again.Position)
+	b1 := queue("b", "1")
+	assert.Equal(t, "b/1", <-started, "other playbooks aren't held up")
+	b2 := queue("b", "2")
+	assert.Equal(t, job.StateQueued, b2.State, "no worker is left")
+
+	running, queued := js.Queue()
+	assert.Len(t, running, 2)
+	if assert.Len(t, queued, 3) {
+		assert.Equal(t, []string{a2.ID, a1again.ID, b2.ID}, []string{queued[0].ID, queued[1].ID, queued[2].ID})
+		assert.Equal(t, 3, queued[2].Position)
+	}
+
+	close(release[a1.ID])
+	assert.Equal(t, "a/2", <-started, "the oldest runnable job goes first")
+	close(release[b1.ID])
+	assert.Equal(t, "b/2", <-started, "jobs on a busy playbook are skipped")
+	close(release[a2.ID])
+	assert.Equal(t, "a/1", <-started)
+	close(release[b2.ID])
+	close(release[a1again.ID])
+	js.Wait()
+
+	running, queued = js.Queue()
+	assert.Empty(t, running)
+	assert.Empty(t, queued)
+	j, err := js.Show(ctx, a1again.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateSucceeded, j.State)
+}
+
+func TestJobCancel(t *testing.T) {
+	s := store.NewMemory()
+	cfg := testutils.TestCfg
+	cfg.PlaybookWorkers = 1
+	playbooks := map[string]*deployment.Playbook{"a": {ID: "a"}}
+	js := NewJobService(NewDeploymentService(cfg, s, playbooks, nil))
+	ctx := actor.NewContext(context.Background(), actor.Actor{Source: actor.SourceSlack, Name: "bill"})
+
+	started := make(chan struct{})
+	queue := func(ID string) *job.Job {
+		i := &instance.Instance{PlaybookID: "a", ID: ID, Path: instance.Path{cfg.EtcdPath, "a", ID}}
+		assert.Nil(t, instance.Save(ctx, s, i))
+		j, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+			started <- struct{}{}
+			<-deployerContext(ctx).Done()
+			c, _ := cancelled(ctx)
+			return c
+		})
+		assert.Nil(t, err)
+		return j
+	}
+
+	running := queue("1")
+	<-started
+	waiting := queue("2")
+	assert.Equal(t, job.StateQueued, waiting.State)
+
+	j, err := js.Cancel(ctx, waiting.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateCancelled, j.State, "queued jobs are cancelled right away")
+	assert.Equal(t, "slack:bill", j.CancelledBy)
+
+	jobs, err := js.CancelInstance(ctx, "a", "1")
+	assert.Nil(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, running.ID, jobs[0].ID)
+		assert.Equal(t, "slack:bill", jobs[0].CancelledBy)
+	}
+	js.Wait()
+
+	j, err = js.Show(ctx, running.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateCancelled, j.State)
+	assert.Equal(t, "cancelled by slack:bill", j.Error)
+	j, err = js.Show(ctx, waiting.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, job.StateCancelled, j.State)
+
+	_, err = js.Cancel(ctx, running.ID)
+	assert.Equal(t, &JobNotCancellable{ID: running.ID, State: job.StateCancelled}, err)
+	_, err = js.Cancel(ctx, "missing")
+	assert.Equal(t, job.NotFoundError("missing"), err)
+	_, err = js.CancelInstance(ctx, "a", "1")
+	assert.Equal(t, &NoJobs{PlaybookID: "a", InstanceID: "1"}, err)
+}
+
+func TestJobCoalesce(t *testing.T) {
+	s := store.NewMemory()
+	playbooks := map[string]*deployment.Playbook{"a": {ID: "a"}}
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, playbooks, nil))
+	ctx := context.Background()
+	i := &instance.Instance{PlaybookID: "a", ID: "ci", Status: instance.StatusDeploying, Path: instance.Path{testutils.TestCfg.EtcdPath, "a", "ci"}}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	release := make(chan struct{})
+	current, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+		<-release
+		return nil
+	})
+	assert.Nil(t, err)
+
+	next, err := js.Deploy(ctx, i)
+	assert.Nil(t, err, "deploys of a busy instance are queued instead of refused")
+	assert.Equal(t, job.StateQueued, next.State)
+	assert.Equal(t, 1, next.Position)
+	assert.Zero(t, next.Coalesced)
+
+	again, err := js.Deploy(ctx, i)
+	assert.Nil(t, err)
+	assert.Equal(t, next.ID, again.ID, "pending deploys collapse into one")
+	assert.Equal(t, 1, again.Coalesced)
+	again, err = js.Deploy(ctx, i)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, again.Coalesced)
+
+	running, queued := js.Queue()
+	assert.Len(t, running, 1)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, current.ID, running[0].ID)
+	j, err := js.Show(ctx, next.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, j.Coalesced)
+
+	_, err = js.Cancel(ctx, next.ID)
+	assert.Nil(t, err)
+	close(release)
+	js.Wait()
+}
+
+func TestJobFailAbandoned(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, testPlaybooks, nil))
+	now := time.Now()
+	running := job.New(job.ActionDeploy, "helloplaybook", "left", "api:token", now.Add(-time.Hour))
+	running.Start(now.Add(-time.Hour))
+	queued := job.New(job.ActionStop, "helloplaybook", "left", "api:token", now.Add(-time.Minute))
+	for _, j := range []*job.Job{running, queued} {
+		assert.Nil(t, job.Save(ctx, s, testutils.TestCfg.EtcdPath, j))
+	}
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "current",
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "current"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	release := make(chan struct{})
+	current, err := js.start(ctx, job.ActionDeploy, i, func(context.Context, *instance.Instance) error {
+		<-release
+		return nil
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, js.FailAbandoned(ctx, now.Add(-time.Minute)))
+	for _, id := range []string{running.ID, queued.ID} {
+		j, err := js.Show(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, job.StateFailed, j.State)
+		assert.Equal(t, ErrJobAbandoned.Error(), j.Error)
+	}
+	j, err := js.Show(ctx, current.ID)
+	assert.Nil(t, err)
+	assert.False(t, j.Done(), "jobs of this process are left alone")
+	close(release)
+	js.Wait()
+
+	assert.Nil(t, js.Prune(ctx, now))
+	jobs, err := job.List(ctx, s, testutils.TestCfg.EtcdPath)
+	assert.Nil(t, err)
+	if assert.Len(t, jobs, 1, "abandoned jobs are pruned like finished ones") {
+		assert.Equal(t, current.ID, jobs[0].ID)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"golang.org/x/net/context"
+)
+
+// DefaultLeaseTTL is used when the configuration doesn't set a lease TTL
+const DefaultLeaseTTL = 90 * time.Second
+
+// owner identifies this broadway process in the leases it holds on instances
+var owner = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "broadway"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}()
+
+func (d *DeploymentService) leaseTTL() time.Duration {
+	if d.Cfg.LeaseTTL <= 0 {
+		return DefaultLeaseTTL
+	}
+	return d.Cfg.LeaseTTL
+}
+
+// heartbeat keeps renewing the lease on i while a deploy or stop runs. The
+// returned func stops the renewals and must be called before i is changed
+// again.
+func (d *DeploymentService) heartbeat(ctx context.Context, i *instance.Instance) func() {
+	path, status, heartbeat := i.Path, i.Status, i.Heartbeat
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(d.leaseTTL() / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				if err := d.renewLease(ctx, path, status, now); err != nil {
+					glog.Warningf("Failed to renew the lease on %s/%s: %s", path.PlaybookID, path.ID, err)
+					continue
+				}
+				heartbeat = now.Unix()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		i.Heartbeat = heartbeat
+	}
+}
+
+// renewLease stamps the stored instance at path with a heartbeat at now. Only
+// the heartbeat is changed so that updates made to the instance meanwhile,
+// such as extending or pinning it, are kept. The lease is left alone if it's
+// no longer held by this process for the same operation.
+func (d *DeploymentService) renewLease(ctx context.Context, path instance.Path, status instance.Status, now time.Time) error {
+	i, err := instance.FindByPath(ctx, d.store, path)
+	if err != nil {
+		return err
+	}
+	if i.Owner != owner || i.Status != status {
+		return fmt.Errorf("the lease is held by %q while %s", i.Owner, i.Status)
+	}
+	i.Heartbeat = now.Unix()
+	return instance.Save(ctx, d.store, i)
+}
+
+// RecoverAbandoned finds instances left deploying or stopping by a broadway
+// process that went away, moves them to the error status and reports them.
+// When configured to, the interrupted deploy, stop or delete is then queued
+// again as a job, like any other.
+func (js *JobService) RecoverAbandoned(ctx context.Context, now time.Time) error {
+	globalPath := fmt.Sprintf("%s/instances", js.ds.Cfg.EtcdPath)
+	instances, err := instance.AllAbandoned(ctx, js.ds.store, globalPath, js.ds.leaseTTL(), now)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		glog.Infof("Recovering %d abandoned instances", len(instances))
+	}
+	ctx = actor.NewContext(ctx, actor.System)
+	for _, i := range instances {
+		interrupted := i.Operation
+		if interrupted == "" {
+			// The lease was taken before operations were recorded on it:
+			interrupted = job.ActionDeploy
+			if i.Status == instance.StatusDeleting {
+				interrupted = job.ActionStop
+			}
+		}
+		cause := abandonedError(i)
+		if err := i.Transition(instance.StatusError, now, cause); err != nil {
+			glog.Error(err)
+			continue
+		}
+		if err := instance.Save(ctx, js.ds.store, i); err != nil {
+			glog.Errorf("Failed to save recovered instance %s/%s: %s", i.PlaybookID, i.ID, err)
+			continue
+		}
+		notify(js.ds.Cfg, i, fmt.Sprintf("Recovered %s/%s: %s", i.PlaybookID, i.ID, cause))
+
+		if !js.ds.Cfg.ResumeInterrupted {
+			continue
+		}
+		switch interrupted {
+		case job.ActionDelete:
+			_, err = js.Delete(ctx, i)
+		case job.ActionStop:
+			_, err = js.Stop(ctx, i)
+		default:
+			// Rollbacks saved the vars they restore before deploying:
+			_, err = js.Deploy(ctx, i)
+		}
+		if err != nil {
+			glog.Errorf("Failed to resume %s/%s: %s", i.PlaybookID, i.ID, err)
+		}
+	}
+	return nil
+}
+
+// abandonedError explains why an abandoned instance was moved to error
+func abandonedError(i *instance.Instance) error {
+	verb := "deploying"
+	switch {
+	case i.Operation == job.ActionDelete:
+		verb = "deleting"
+	case i.Status == instance.StatusDeleting:
+		verb = "stopping"
+	}
+	if i.Owner == "" {
+		return fmt.Errorf("broadway went away while %s the instance", verb)
+	}
+	return fmt.Errorf("broadway (%s) went away while %s the instance", i.Owner, verb)
+}
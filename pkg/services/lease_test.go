@@ -0,0 +1,205 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRecoverAbandoned(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	js := NewJobService(NewDeploymentService(ServicesTestCfg, s, nil, nil))
+
+	stuck := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "stuck",
+		Status:     instance.StatusDeploying,
+		Owner:      "old-host/42",
+		Heartbeat:  now.Add(-time.Hour).Unix(),
+		Path:       instance.Path{ServicesTestCfg.EtcdPath, "helloplaybook", "stuck"},
+	}
+	running := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "running",
+		Status:     instance.StatusDeleting,
+		Owner:      owner,
+		Heartbeat:  now.Unix(),
+		Path:       instance.Path{ServicesTestCfg.EtcdPath, "helloplaybook", "running"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, stuck))
+	assert.Nil(t, instance.Save(ctx, s, running))
+
+	assert.Nil(t, js.RecoverAbandoned(ctx, now))
+
+	i, err := instance.FindByPath(ctx, s, stuck.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusError, i.Status)
+	assert.Equal(t, "broadway (old-host/42) went away while deploying the instance", i.LastError)
+	assert.Equal(t, "", i.Owner)
+	assert.Contains(t, nt.requestBody, "Recovered helloplaybook/stuck")
+
+	i, err = instance.FindByPath(ctx, s, running.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusDeleting, i.Status, "operations still heartbeating are left alone")
+}
+
+func TestResumeAbandoned(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	c := ServicesTestCfg
+	c.ResumeInterrupted = true
+	ds := NewDeploymentService(c, s, testPlaybooks, testManifests)
+	ds.Deployers = deployment.NewFake().Factory
+	js := NewJobService(ds)
+
+	stuck := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "stuck",
+		Status:     instance.StatusDeploying,
+		Vars:       map[string]string{"word": "hi"},
+		Owner:      "old-host/42",
+		Operation:  job.ActionDeploy,
+		Heartbeat:  now.Add(-time.Hour).Unix(),
+		Path:       instance.Path{c.EtcdPath, "helloplaybook", "stuck"},
+	}
+	deleted := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "deleted",
+		Status:     instance.StatusDeleting,
+		Owner:      "old-host/42",
+		Operation:  job.ActionDelete,
+		Heartbeat:  now.Add(-time.Hour).Unix(),
+		Path:       instance.Path{c.EtcdPath, "helloplaybook", "deleted"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, stuck))
+	assert.Nil(t, instance.Save(ctx, s, deleted))
+
+	assert.Nil(t, js.RecoverAbandoned(ctx, now))
+	js.Wait()
+
+	jobs, err := job.List(ctx, s, c.EtcdPath)
+	assert.Nil(t, err)
+	actions := map[string]string{}
+	for _, j := range jobs {
+		actions[j.InstanceID] = j.Action
+		assert.Equal(t, actor.System.String(), j.Actor)
+		assert.Equal(t, job.StateSucceeded, j.State)
+	}
+	assert.Equal(t, map[string]string{"stuck": job.ActionDeploy, "deleted": job.ActionDelete}, actions, "the operations are resumed as jobs")
+
+	i, err := instance.FindByPath(ctx, s, stuck.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusDeployed, i.Status)
+	_, err = instance.FindByPath(ctx, s, deleted.Path)
+	assert.IsType(t, instance.NotFoundError(""), err, "the interrupted delete removes the instance")
+}
+
+func TestLeaseOperation(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	s := store.NewMemory()
+	ds := NewDeploymentService(ServicesTestCfg, s, testPlaybooks, testManifests)
+	js := NewJobService(ds)
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "leased",
+		Status:     instance.StatusDeployed,
+		Path:       instance.Path{ServicesTestCfg.EtcdPath, "helloplaybook", "leased"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	operation := ""
+	ds.Deployers = func(deployment.Spec) (deployment.Deployer, error) {
+		return &hookDeployer{hook: func() {
+			leased, err := instance.FindByPath(ctx, s, i.Path)
+			assert.Nil(t, err)
+			operation = leased.Operation
+		}}, nil
+	}
+	_, err := js.Delete(ctx, i)
+	assert.Nil(t, err)
+	js.Wait()
+	assert.Equal(t, job.ActionDelete, operation, "the lease records that the stop is part of a delete")
+}
+
+func TestRenewLease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	ds := NewDeploymentService(ServicesTestCfg, s, nil, nil)
+
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "leased",
+		Status:     instance.StatusDeploying,
+		Owner:      owner,
+		Heartbeat:  now.Add(-time.Minute).Unix(),
+		Path:       instance.Path{ServicesTestCfg.EtcdPath, "helloplaybook", "leased"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	// The instance is pinned while it's being deployed:
+	pinned := *i
+	pinned.Pinned = true
+	assert.Nil(t, instance.Save(ctx, s, &pinned))
+
+	assert.Nil(t, ds.renewLease(ctx, i.Path, instance.StatusDeploying, now))
+	renewed, err := instance.FindByPath(ctx, s, i.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Unix(), renewed.Heartbeat)
+	assert.True(t, renewed.Pinned, "renewing the lease keeps concurrent updates")
+
+	renewed.Owner = "other-host/42"
+	assert.Nil(t, instance.Save(ctx, s, renewed))
+	assert.NotNil(t, ds.renewLease(ctx, i.Path, instance.StatusDeploying, now.Add(time.Minute)))
+	stolen, _ := instance.FindByPath(ctx, s, i.Path)
+	assert.Equal(t, now.Unix(), stolen.Heartbeat, "leases held by others are left alone")
+}
+
+func TestUpdateKeepsLease(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	ds := NewDeploymentService(ServicesTestCfg, s, nil, nil)
+	is := NewInstanceService(ServicesTestCfg, s)
+
+	deploying := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "updated",
+		Status:     instance.StatusDeploying,
+		Vars:       map[string]string{"word": "hi"},
+		Owner:      owner,
+		Heartbeat:  now.Add(-time.Minute).Unix(),
+		Path:       instance.Path{ServicesTestCfg.EtcdPath, "helloplaybook", "updated"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, deploying))
+
+	// The vars are changed while the deploy runs:
+	i, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "updated", Vars: map[string]string{"word": "bye"}})
+	assert.Nil(t, err)
+	assert.Equal(t, owner, i.Owner)
+	assert.Equal(t, deploying.Heartbeat, i.Heartbeat)
+
+	assert.Nil(t, ds.renewLease(ctx, deploying.Path, instance.StatusDeploying, now), "the deploy keeps its lease")
+	assert.Nil(t, NewJobService(ds).RecoverAbandoned(ctx, now.Add(ds.leaseTTL()/2)))
+	i, err = instance.FindByPath(ctx, s, deploying.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, instance.StatusDeploying, i.Status, "the deploy isn't taken for abandoned")
+	assert.Equal(t, "bye", i.Vars["word"])
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/audit"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/metrics"
+	"github.com/namely/broadway/pkg/notification"
+	"golang.org/x/net/context"
+)
+
+// Reasons for the cleanup worker to act on an instance
+const (
+	CleanupExpired = "expired"
+	CleanupIdle    = "idle"
+)
+
+// CleanupAction describes what the cleanup worker does, or would do, to an
+// instance
+type CleanupAction struct {
+	PlaybookID string          `json:"playbook_id"`
+	InstanceID string          `json:"instance_id"`
+	Status     instance.Status `json:"status"`
+	Reason     string          `json:"reason"`
+	Stop       bool            `json:"stop"`
+	Delete     bool            `json:"delete"`
+	JobID      string          `json:"job_id,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func (a *CleanupAction) verb() string {
+	switch {
+	case a.Stop && a.Delete:
+		return "stop and delete"
+	case a.Delete:
+		return "delete"
+	}
+	return "stop"
+}
+
+// expiration returns when a new instance of a playbook expires, following the
+// playbook's lifecycle TTL or else the configured expiration days
+func (is *InstanceService) expiration(playbookID string, now time.Time) time.Time {
+	if pb, ok := deployment.AllPlaybooks[playbookID]; ok && pb.Lifecycle.TTL > 0 {
+		return now.Add(time.Duration(pb.Lifecycle.TTL))
+	}
+	return instance.NewExpiredAt(is.Cfg.InstanceExpirationDays, now)
+}
+
+// renewExpiration pushes back the expiration of a freshly deployed instance to
+// its playbook's deploy TTL. Instances that never expire are left alone.
+func renewExpiration(i *instance.Instance, playbook *deployment.Playbook, now time.Time) {
+	ttl := time.Duration(playbook.Lifecycle.DeployTTL)
+	if ttl <= 0 || i.ExpiredAt == 0 {
+		return
+	}
+	if at := now.Add(ttl).Unix(); at > i.ExpiredAt {
+		i.ExpiredAt = at
+	}
+}
+
+// Cleanup applies the playbooks' lifecycle policies to every instance as of
+// now. Expired instances are stopped, and also deleted when their playbook
+// says so. New and errored instances left idle past their playbook's idle TTL
+// are deleted. Pinned instances and instances being deployed or stopped are
+// skipped. A dry run only reports what would be done.
+func (d *DeploymentService) Cleanup(ctx context.Context, now time.Time, dryRun bool) ([]*CleanupAction, error) {
+	return d.cleanup(ctx, now, dryRun, d.runCleanup)
+}
+
+// cleanup plans the cleanup of every instance as of now, handing each action
+// to run unless it's a dry run
+func (d *DeploymentService) cleanup(ctx context.Context, now time.Time, dryRun bool, run func(context.Context, *instance.Instance, *CleanupAction)) ([]*CleanupAction, error) {
+	instances, _, err := instance.Select(ctx, d.store, fmt.Sprintf("%s/instances", d.Cfg.EtcdPath), instance.Query{})
+	if err != nil {
+		if !dryRun {
+			metrics.CleanupRun(metrics.OutcomeFailed)
+		}
+		return nil, err
+	}
+	if !dryRun {
+		metrics.CleanupRun(metrics.OutcomeSucceeded)
+	}
+	ctx = actor.NewContext(ctx, actor.System)
+	actions := []*CleanupAction{}
+	for _, i := range instances {
+		a := d.planCleanup(i, now)
+		if a == nil {
+			continue
+		}
+		actions = append(actions, a)
+		if dryRun {
+			glog.Infof("Cleanup would %s %s instance %s/%s", a.verb(), a.Reason, a.PlaybookID, a.InstanceID)
+			continue
+		}
+		run(ctx, i, a)
+	}
+	if len(actions) > 0 {
+		glog.Infof("Cleanup found %d instances to clean up (dry run: %t)", len(actions), dryRun)
+	}
+	return actions, nil
+}
+
+// planCleanup decides what the lifecycle policy of i's playbook requires, if
+// anything
+func (d *DeploymentService) planCleanup(i *instance.Instance, now time.Time) *CleanupAction {
+	if i.Pinned || i.Status.Transitional() {
+		return nil
+	}
+	var lifecycle deployment.Lifecycle
+	if pb, ok := d.playbooks[i.PlaybookID]; ok {
+		lifecycle = pb.Lifecycle
+	}
+
+	a := &CleanupAction{PlaybookID: i.PlaybookID, InstanceID: i.ID, Status: i.Status}
+	switch {
+	case i.ExpiredAt != 0 && i.ExpiredAt <= now.Unix():
+		a.Reason = CleanupExpired
+		a.Delete = lifecycle.Deletes()
+	case lifecycle.IdleTTL > 0 && idle(i, time.Duration(lifecycle.IdleTTL), now):
+		a.Reason = CleanupIdle
+		a.Delete = true
+	default:
+		return nil
+	}
+	a.Stop = i.Status == instance.StatusDeployed || i.Status == instance.StatusError
+	if !a.Stop && !a.Delete {
+		return nil
+	}
+	return a
+}
+
+// idle returns true if i never got deployed successfully and hasn't changed
+// status for ttl
+func idle(i *instance.Instance, ttl time.Duration, now time.Time) bool {
+	if i.Status != instance.StatusNew && i.Status != instance.StatusError {
+		return false
+	}
+	since, ok := i.StatusSince()
+	if !ok {
+		since = time.Unix(i.Created, 0)
+	}
+	return !since.Add(ttl).After(now)
+}
+
+func (d *DeploymentService) runCleanup(ctx context.Context, i *instance.Instance, a *CleanupAction) {
+	e := newAuditEntry(ctx, audit.ActionExpire, i.PlaybookID, i.ID)
+	e.Detail = fmt.Sprintf("%s %s instance", a.verb(), a.Reason)
+	e.Before = summarize(i)
+	defer func() {
+		e.Error = a.Error
+		recordAudit(ctx, d.Cfg, d.store, e)
+	}()
+
+	if a.Stop {
+		if err := d.StopAndNotify(ctx, i); err != nil {
+			glog.Error(err)
+			a.Error = err.Error()
+			return
+		}
+		e.After = summarize(i)
+	}
+	if a.Delete {
+		path := instance.Path{RootPath: d.Cfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+		if err := instance.Delete(ctx, d.store, path); err != nil {
+			glog.Errorf("Failed to delete %s instance %s/%s: %s", a.Reason, i.PlaybookID, i.ID, err)
+			a.Error = err.Error()
+			return
+		}
+		e.After = nil
+		notify(d.Cfg, i, fmt.Sprintf("Deleted %s instance %s/%s", a.Reason, i.PlaybookID, i.ID))
+	}
+	metrics.CleanupRemoval(i.PlaybookID, a.Reason, strings.Replace(a.verb(), " ", "_", -1))
+}
+
+// ownerVar is the playbook var naming who an instance belongs to
+const ownerVar = "owner"
+
+// WarnExpiring warns on Slack about every instance the cleanup worker will
+// stop or delete within the configured expiry warning, telling how to keep it
+// around. Each instance is warned once per expiration, so extending it allows
+// a new warning.
+func (d *DeploymentService) WarnExpiring(ctx context.Context, now time.Time) ([]*instance.Instance, error) {
+	warned := []*instance.Instance{}
+	if d.Cfg.ExpiryWarning <= 0 {
+		return warned, nil
+	}
+	q := instance.Query{ExpiresFrom: now.Unix() + 1, ExpiresTo: now.Add(d.Cfg.ExpiryWarning).Unix()}
+	instances, _, err := instance.Select(ctx, d.store, fmt.Sprintf("%s/instances", d.Cfg.EtcdPath), q)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range instances {
+		if i.WarnedFor == i.ExpiredAt {
+			continue
+		}
+		a := d.planCleanup(i, time.Unix(i.ExpiredAt, 0))
+		if a == nil {
+			continue
+		}
+
+		m := notification.NewMessage(d.Cfg, false, d.expiryWarning(i, a, now))
+		m.LinkNames = true
+		if err := m.Send(); err != nil {
+			glog.Warningf("Failed to warn about the expiration of %s/%s: %s", i.PlaybookID, i.ID, err)
+			continue
+		}
+		i.WarnedFor = i.ExpiredAt
+		if err := instance.Save(ctx, d.store, i); err != nil {
+			glog.Errorf("Failed to record the expiry warning of %s/%s: %s", i.PlaybookID, i.ID, err)
+		}
+		warned = append(warned, i)
+	}
+	return warned, nil
+}
+
+// expiryWarning tells what will happen to i when it expires, mentioning its
+// owner if its playbook declares one
+func (d *DeploymentService) expiryWarning(i *instance.Instance, a *CleanupAction, now time.Time) string {
+	outcome := "stopped"
+	switch {
+	case a.Stop && a.Delete:
+		outcome = "stopped and deleted"
+	case a.Delete:
+		outcome = "deleted"
+	}
+	left, _ := i.ExpiresIn(now)
+	msg := fmt.Sprintf("Instance %s/%s expires in %s and will be %s. ", i.PlaybookID, i.ID, fmtLifetime(left), outcome)
+	if owner := d.owner(i); owner != "" {
+		msg += "@" + owner + ", "
+	}
+	return msg + fmt.Sprintf("run `/bw extend %s %s 1d` to keep it longer or `/bw pin %s %s` to keep it until unpinned.", i.PlaybookID, i.ID, i.PlaybookID, i.ID)
+}
+
+// owner returns the Slack name held by i's owner var, if its playbook declares
+// one
+func (d *DeploymentService) owner(i *instance.Instance) string {
+	playbook, ok := d.playbooks[i.PlaybookID]
+	if !ok || !containsID(playbook.Vars, ownerVar) {
+		return ""
+	}
+	return strings.TrimPrefix(i.Vars[ownerVar], "@")
+}
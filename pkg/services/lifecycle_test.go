@@ -0,0 +1,180 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+var lifecyclePlaybooks = map[string]*deployment.Playbook{
+	"stopping": {ID: "stopping"},
+	"deleting": {ID: "deleting", Lifecycle: deployment.Lifecycle{
+		IdleTTL: deployment.Lifetime(24 * time.Hour),
+		Action:  deployment.LifecycleDelete,
+	}},
+}
+
+func TestPlanCleanup(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour).Unix()
+	future := now.Add(time.Hour).Unix()
+	longAgo := now.Add(-48 * time.Hour).Unix()
+	ds := NewDeploymentService(testutils.TestCfg, store.NewMemory(), lifecyclePlaybooks, nil)
+
+	testcases := []struct {
+		Scenario string
+		Instance *instance.Instance
+		Expected *CleanupAction
+	}{
+		{
+			"expired deployed instances are stopped",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusDeployed, ExpiredAt: past},
+			&CleanupAction{PlaybookID: "stopping", InstanceID: "a", Status: instance.StatusDeployed, Reason: CleanupExpired, Stop: true},
+		},
+		{
+			"expired errored instances are stopped",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusError, ExpiredAt: past},
+			&CleanupAction{PlaybookID: "stopping", InstanceID: "a", Status: instance.StatusError, Reason: CleanupExpired, Stop: true},
+		},
+		{
+			"expired stopped instances are left alone without a delete policy",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusStopped, ExpiredAt: past},
+			nil,
+		},
+		{
+			"expired stopped instances are deleted with a delete policy",
+			&instance.Instance{PlaybookID: "deleting", ID: "a", Status: instance.StatusStopped, ExpiredAt: past},
+			&CleanupAction{PlaybookID: "deleting", InstanceID: "a", Status: instance.StatusStopped, Reason: CleanupExpired, Delete: true},
+		},
+		{
+			"unexpired instances are left alone",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusDeployed, ExpiredAt: future},
+			nil,
+		},
+		{
+			"pinned instances are left alone",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusDeployed, ExpiredAt: past, Pinned: true},
+			nil,
+		},
+		{
+			"instances being deployed are left alone",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Status: instance.StatusDeploying, ExpiredAt: past},
+			nil,
+		},
+		{
+			"idle new instances are deleted",
+			&instance.Instance{PlaybookID: "deleting", ID: "a", Created: longAgo, ExpiredAt: future},
+			&CleanupAction{PlaybookID: "deleting", InstanceID: "a", Status: instance.StatusNew, Reason: CleanupIdle, Delete: true},
+		},
+		{
+			"recently errored instances aren't idle",
+			&instance.Instance{PlaybookID: "deleting", ID: "a", Status: instance.StatusError, Created: longAgo, ExpiredAt: future, Timestamps: map[string]int64{"error": past}},
+			nil,
+		},
+		{
+			"new instances without an idle policy are kept",
+			&instance.Instance{PlaybookID: "stopping", ID: "a", Created: longAgo, ExpiredAt: future},
+			nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.Expected, ds.planCleanup(tc.Instance, now), tc.Scenario)
+	}
+}
+
+func TestCleanupDryRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	ds := NewDeploymentService(testutils.TestCfg, s, lifecyclePlaybooks, nil)
+	i := &instance.Instance{PlaybookID: "deleting", ID: "idle", Created: now.Add(-48 * time.Hour).Unix()}
+	i.Path = instance.Path{RootPath: testutils.TestCfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	actions, err := ds.Cleanup(ctx, now, true)
+	assert.Nil(t, err)
+	assert.Len(t, actions, 1)
+
+	_, err = instance.FindByPath(ctx, s, i.Path)
+	assert.Nil(t, err, "a dry run doesn't delete anything")
+
+	_, err = ds.Cleanup(ctx, now, false)
+	assert.Nil(t, err)
+	_, err = instance.FindByPath(ctx, s, i.Path)
+	assert.IsType(t, instance.NotFoundError(""), err)
+}
+
+func TestRenewExpiration(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	playbook := &deployment.Playbook{Lifecycle: deployment.Lifecycle{DeployTTL: deployment.Lifetime(48 * time.Hour)}}
+
+	i := &instance.Instance{ExpiredAt: now.Add(time.Hour).Unix()}
+	renewExpiration(i, playbook, now)
+	assert.Equal(t, now.Add(48*time.Hour).Unix(), i.ExpiredAt)
+
+	i = &instance.Instance{ExpiredAt: now.Add(96 * time.Hour).Unix()}
+	renewExpiration(i, playbook, now)
+	assert.Equal(t, now.Add(96*time.Hour).Unix(), i.ExpiredAt, "a longer expiration is kept")
+
+	i = &instance.Instance{}
+	renewExpiration(i, playbook, now)
+	assert.Equal(t, int64(0), i.ExpiredAt, "instances that never expire are left alone")
+}
+
+func TestWarnExpiring(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory()
+	cfg := ServicesTestCfg
+	cfg.ExpiryWarning = 24 * time.Hour
+	playbooks := map[string]*deployment.Playbook{
+		"owned": {ID: "owned", Vars: []string{"owner"}},
+	}
+	ds := NewDeploymentService(cfg, s, playbooks, nil)
+
+	soon := &instance.Instance{
+		PlaybookID: "owned",
+		ID:         "soon",
+		Status:     instance.StatusDeployed,
+		ExpiredAt:  now.Add(3 * time.Hour).Unix(),
+		Vars:       map[string]string{"owner": "bill"},
+	}
+	later := &instance.Instance{
+		PlaybookID: "owned",
+		ID:         "later",
+		Status:     instance.StatusDeployed,
+		ExpiredAt:  now.Add(72 * time.Hour).Unix(),
+	}
+	for _, i := range []*instance.Instance{soon, later} {
+		i.Path = instance.Path{RootPath: cfg.EtcdPath, PlaybookID: i.PlaybookID, ID: i.ID}
+		assert.Nil(t, instance.Save(ctx, s, i))
+	}
+
+	warned, err := ds.WarnExpiring(ctx, now)
+	assert.Nil(t, err)
+	if assert.Len(t, warned, 1) {
+		assert.Equal(t, "soon", warned[0].ID)
+	}
+	assert.Contains(t, nt.requestBody, "Instance owned/soon expires in 2h59m and will be stopped. @bill, run `/bw extend owned soon 1d`")
+
+	warned, err = ds.WarnExpiring(ctx, now)
+	assert.Nil(t, err)
+	assert.Empty(t, warned, "each expiration is only warned about once")
+
+	i, err := instance.FindByPath(ctx, s, soon.Path)
+	assert.Nil(t, err)
+	i.Extend(time.Hour, now)
+	assert.Nil(t, instance.Save(ctx, s, i))
+	warned, err = ds.WarnExpiring(ctx, now)
+	assert.Nil(t, err)
+	assert.Len(t, warned, 1, "extending an instance opens a new warning window")
+}
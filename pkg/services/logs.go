@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"golang.org/x/net/context"
+)
+
+// NoLogs indicates an instance has no pods to read logs from
+type NoLogs struct {
+	PlaybookID string
+	ID         string
+}
+
+func (e *NoLogs) Error() string {
+	return fmt.Sprintf("%s/%s has no pods to read logs from", e.PlaybookID, e.ID)
+}
+
+// Logs opens the logs of the pods deployed for i. The caller must close them.
+func (d *DeploymentService) Logs(ctx context.Context, i *instance.Instance, opts deployment.LogOptions) ([]deployment.PodLog, error) {
+	playbook, ok := d.playbooks[i.PlaybookID]
+	if !ok {
+		return nil, &PlaybookNotFound{i.PlaybookID}
+	}
+	deployer, err := d.Deployers(deployment.Spec{
+		Target:    d.target(i),
+		Playbook:  playbook,
+		Variables: varMap(i),
+		Manifests: d.manifests,
+		Context:   ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := deployer.(deployment.LogReader)
+	if !ok {
+		return nil, errors.New("The deployer can't read logs")
+	}
+	logs, err := reader.Logs(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, &NoLogs{i.PlaybookID, i.ID}
+	}
+	return logs, nil
+}
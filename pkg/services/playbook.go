@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
+)
+
+// PlaybookService implements the Broadway logic for playbooks
+type PlaybookService struct {
+	Cfg       cfg.Type
+	store     store.Store
+	playbooks map[string]*deployment.Playbook
+}
+
+// NewPlaybookService creates a new PlaybookService
+func NewPlaybookService(cfg cfg.Type, s store.Store, ps map[string]*deployment.Playbook) *PlaybookService {
+	return &PlaybookService{
+		Cfg:       cfg,
+		store:     s,
+		playbooks: ps,
+	}
+}
+
+// PlaybookMeta is the metadata of a playbook as shown to API clients
+type PlaybookMeta struct {
+	Team  string `json:"team"`
+	Email string `json:"email"`
+	Slack string `json:"slack"`
+}
+
+// PlaybookInfo describes a playbook and how many instances of it exist
+type PlaybookInfo struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Meta      PlaybookMeta      `json:"meta"`
+	Vars      []string          `json:"vars"`
+	Manifests []string          `json:"manifests"`
+	Messages  map[string]string `json:"messages"`
+	Instances map[string]int    `json:"instances"`
+}
+
+// All returns every playbook ordered by id
+func (ps *PlaybookService) All(ctx context.Context) ([]*PlaybookInfo, error) {
+	counts, err := ps.countInstances(ctx, instance.Query{})
+	if err != nil {
+		return nil, err
+	}
+	infos := []*PlaybookInfo{}
+	for _, p := range ps.playbooks {
+		infos = append(infos, playbookInfo(p, counts[p.ID]))
+	}
+	sort.Sort(byPlaybookID(infos))
+	return infos, nil
+}
+
+// Show returns a single playbook
+func (ps *PlaybookService) Show(ctx context.Context, ID string) (*PlaybookInfo, error) {
+	p, ok := ps.playbooks[ID]
+	if !ok {
+		return nil, &PlaybookNotFound{ID}
+	}
+	counts, err := ps.countInstances(ctx, instance.Query{PlaybookIDs: []string{ID}})
+	if err != nil {
+		return nil, err
+	}
+	return playbookInfo(p, counts[ID]), nil
+}
+
+// countInstances counts the instances matching q by playbook and status
+func (ps *PlaybookService) countInstances(ctx context.Context, q instance.Query) (map[string]map[string]int, error) {
+	instances, _, err := instance.Select(ctx, ps.store, fmt.Sprintf("%s/instances", ps.Cfg.EtcdPath), q)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]map[string]int{}
+	for _, i := range instances {
+		if counts[i.PlaybookID] == nil {
+			counts[i.PlaybookID] = map[string]int{}
+		}
+		counts[i.PlaybookID][i.Status.String()]++
+	}
+	return counts, nil
+}
+
+func playbookInfo(p *deployment.Playbook, counts map[string]int) *PlaybookInfo {
+	if counts == nil {
+		counts = map[string]int{}
+	}
+	messages := p.Messages
+	if messages == nil {
+		messages = map[string]string{}
+	}
+	return &PlaybookInfo{
+		ID:        p.ID,
+		Name:      p.Name,
+		Meta:      PlaybookMeta{Team: p.Meta.Team, Email: p.Meta.Email, Slack: p.Meta.Slack},
+		Vars:      p.Vars,
+		Manifests: p.Manifests,
+		Messages:  messages,
+		Instances: counts,
+	}
+}
+
+type byPlaybookID []*PlaybookInfo
+
+func (pp byPlaybookID) Len() int           { return len(pp) }
+func (pp byPlaybookID) Less(i, j int) bool { return pp[i].ID < pp[j].ID }
+func (pp byPlaybookID) Swap(i, j int)      { pp[i], pp[j] = pp[j], pp[i] }
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestPlaybookService(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	playbooks := map[string]*deployment.Playbook{
+		"web": {ID: "web", Name: "Web", Meta: deployment.Meta{Team: "payments"}, Vars: []string{"version"}, Manifests: []string{"web"}},
+		"api": {ID: "api", Name: "API", Vars: []string{"version", "owner"}},
+	}
+	for _, i := range []*instance.Instance{
+		{PlaybookID: "web", ID: "master", Status: instance.StatusDeployed},
+		{PlaybookID: "web", ID: "feature", Status: instance.StatusError},
+		{PlaybookID: "web", ID: "other", Status: instance.StatusDeployed},
+	} {
+		i.Path = instance.Path{testutils.TestCfg.EtcdPath, i.PlaybookID, i.ID}
+		assert.Nil(t, instance.Save(ctx, s, i))
+	}
+	ps := NewPlaybookService(testutils.TestCfg, s, playbooks)
+
+	all, err := ps.All(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, all, 2) {
+		assert.Equal(t, "api", all[0].ID)
+		assert.Empty(t, all[0].Instances)
+		assert.Equal(t, "web", all[1].ID)
+	}
+
+	web, err := ps.Show(ctx, "web")
+	assert.Nil(t, err)
+	assert.Equal(t, "payments", web.Meta.Team)
+	assert.Equal(t, map[string]int{"deployed": 2, "error": 1}, web.Instances)
+
+	_, err = ps.Show(ctx, "missing")
+	assert.IsType(t, &PlaybookNotFound{}, err)
+
+	is := NewInstanceService(testutils.TestCfg, s)
+	command := BuildSlackCommand(testutils.TestCfg, "playbooks", nil, is, playbooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "Playbooks:\n  - api: API, vars: version owner, 0 instances\n  - web: Web (team payments), vars: version, 3 instances\n", msg)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/metrics"
+	"golang.org/x/net/context"
+)
+
+// varPrefix marks query parameters filtering on instance vars, as in
+// var.owner=bill
+const varPrefix = "var."
+
+// InvalidQuery indicates an instance query parameter that can't be understood
+type InvalidQuery struct {
+	param  string
+	reason string
+}
+
+func (e *InvalidQuery) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.param, e.reason)
+}
+
+var statuses = map[string]instance.Status{
+	"new":       instance.StatusNew,
+	"deploying": instance.StatusDeploying,
+	"deployed":  instance.StatusDeployed,
+	"deleting":  instance.StatusDeleting,
+	"stopped":   instance.StatusStopped,
+	"error":     instance.StatusError,
+}
+
+// ParseQuery builds an instance query from request parameters. Lists may be
+// given as repeated or comma separated parameters. The team parameter
+// selects the playbooks whose meta names that team.
+func ParseQuery(values url.Values, playbooks map[string]*deployment.Playbook) (instance.Query, error) {
+	q := instance.Query{}
+	for param, vs := range values {
+		if strings.HasPrefix(param, varPrefix) {
+			if q.Vars == nil {
+				q.Vars = map[string]string{}
+			}
+			q.Vars[strings.TrimPrefix(param, varPrefix)] = vs[len(vs)-1]
+			continue
+		}
+		v := vs[len(vs)-1]
+		var err error
+		switch param {
+		case "playbook":
+			q.PlaybookIDs = splitList(vs)
+		case "status":
+			for _, s := range splitList(vs) {
+				status, ok := statuses[s]
+				if !ok {
+					return q, &InvalidQuery{param, "unknown status " + s}
+				}
+				q.Statuses = append(q.Statuses, status)
+			}
+		case "team":
+		case "pinned":
+			pinned, err := strconv.ParseBool(v)
+			if err != nil {
+				return q, &InvalidQuery{param, "must be true or false"}
+			}
+			q.Pinned = &pinned
+		case "drifted":
+			drifted, err := strconv.ParseBool(v)
+			if err != nil {
+				return q, &InvalidQuery{param, "must be true or false"}
+			}
+			q.Drifted = &drifted
+		case "created_from":
+			q.CreatedFrom, err = parseQueryTime(v, false)
+		case "created_to":
+			q.CreatedTo, err = parseQueryTime(v, true)
+		case "expires_from":
+			q.ExpiresFrom, err = parseQueryTime(v, false)
+		case "expires_to":
+			q.ExpiresTo, err = parseQueryTime(v, true)
+		case "sort":
+			q.Sort = v
+		case "offset":
+			q.Offset, err = strconv.Atoi(v)
+			if err == nil && q.Offset < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "limit":
+			q.Limit, err = strconv.Atoi(v)
+			if err == nil && q.Limit < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		default:
+			return q, &InvalidQuery{param, "unknown parameter"}
+		}
+		if err != nil {
+			return q, &InvalidQuery{param, err.Error()}
+		}
+	}
+
+	if team := values.Get("team"); team != "" {
+		ids := []string{}
+		for id, p := range playbooks {
+			if p.Meta.Team != team {
+				continue
+			}
+			if q.PlaybookIDs == nil || containsID(q.PlaybookIDs, id) {
+				ids = append(ids, id)
+			}
+		}
+		q.PlaybookIDs = ids
+	}
+	return q, nil
+}
+
+// Query returns the instances across all playbooks matching q, along with how
+// many matched before pagination
+func (is *InstanceService) Query(ctx context.Context, q instance.Query) ([]*instance.Instance, int, error) {
+	return instance.Select(ctx, is.store, fmt.Sprintf("%s/instances", is.Cfg.EtcdPath), q)
+}
+
+// Counts returns how many instances each playbook has in each status
+func (is *InstanceService) Counts(ctx context.Context) ([]metrics.InstanceCount, error) {
+	instances, _, err := is.Query(ctx, instance.Query{})
+	if err != nil {
+		return nil, err
+	}
+	index := map[[2]string]int{}
+	counts := []metrics.InstanceCount{}
+	for _, i := range instances {
+		status := string(i.Status)
+		if i.Status == instance.StatusNew {
+			status = "new"
+		}
+		key := [2]string{i.PlaybookID, status}
+		n, ok := index[key]
+		if !ok {
+			n = len(counts)
+			index[key] = n
+			counts = append(counts, metrics.InstanceCount{Playbook: i.PlaybookID, Status: status})
+		}
+		counts[n].Count++
+	}
+	return counts, nil
+}
+
+// parseQueryTime reads a unix timestamp, an RFC 3339 time or a date. A date
+// closing a range stands for the end of that day.
+func parseQueryTime(v string, end bool) (int64, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a timestamp or date", v)
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t.Unix(), nil
+}
+
+func splitList(vs []string) []string {
+	list := []string{}
+	for _, v := range vs {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+func containsID(ids []string, id string) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	playbooks := map[string]*deployment.Playbook{
+		"web": {ID: "web", Meta: deployment.Meta{Team: "payments"}},
+		"api": {ID: "api", Meta: deployment.Meta{Team: "payments"}},
+		"ops": {ID: "ops", Meta: deployment.Meta{Team: "platform"}},
+	}
+
+	values, _ := url.ParseQuery("status=error,deploying&var.owner=bill&sort=-created&limit=10&offset=20&pinned=false&drifted=true")
+	q, err := ParseQuery(values, playbooks)
+	assert.Nil(t, err)
+	assert.Equal(t, []instance.Status{instance.StatusError, instance.StatusDeploying}, q.Statuses)
+	assert.Equal(t, map[string]string{"owner": "bill"}, q.Vars)
+	assert.Equal(t, "-created", q.Sort)
+	assert.Equal(t, 10, q.Limit)
+	assert.Equal(t, 20, q.Offset)
+	assert.False(t, *q.Pinned)
+	assert.True(t, *q.Drifted)
+
+	values, _ = url.ParseQuery("expires_from=2016-08-05&expires_to=2016-08-05")
+	q, err = ParseQuery(values, playbooks)
+	assert.Nil(t, err)
+	day := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, day.Unix(), q.ExpiresFrom)
+	assert.Equal(t, day.Add(24*time.Hour).Unix()-1, q.ExpiresTo, "a closing date covers the whole day")
+
+	values, _ = url.ParseQuery("team=payments&playbook=web,ops")
+	q, err = ParseQuery(values, playbooks)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"web"}, q.PlaybookIDs)
+
+	values, _ = url.ParseQuery("team=nobody")
+	q, err = ParseQuery(values, playbooks)
+	assert.Nil(t, err)
+	assert.NotNil(t, q.PlaybookIDs)
+	assert.Empty(t, q.PlaybookIDs, "unknown teams match no playbooks")
+
+	for _, bad := range []string{"status=sleeping", "pinned=maybe", "drifted=maybe", "limit=-1", "created_from=yesterday", "color=red"} {
+		values, _ = url.ParseQuery(bad)
+		_, err = ParseQuery(values, playbooks)
+		assert.IsType(t, &InvalidQuery{}, err, bad)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/namely/broadway/pkg/actor"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/revision"
+	"golang.org/x/net/context"
+)
+
+// ErrNoPreviousRevision is returned when rolling back an instance that has not
+// been deployed successfully at least twice
+var ErrNoPreviousRevision = errors.New("broadway/services: there is no previous revision to roll back to")
+
+func revisionPath(root string, i *instance.Instance) revision.Path {
+	return revision.Path{RootPath: root, PlaybookID: i.PlaybookID, InstanceID: i.ID}
+}
+
+// saveRevision records the vars of a successful deploy of i as its next
+// numbered revision and points i at it
+func (d *DeploymentService) saveRevision(ctx context.Context, i *instance.Instance, playbook *deployment.Playbook, manifestHash string) error {
+	p := revisionPath(d.Cfg.EtcdPath, i)
+	latest, err := revision.Latest(ctx, d.store, p)
+	if err != nil {
+		return err
+	}
+	if latest < i.Revision {
+		latest = i.Revision
+	}
+	vars := make(map[string]string, len(i.Vars))
+	for k, v := range i.Vars {
+		vars[k] = v
+	}
+	r := &revision.Revision{
+		Number:       latest + 1,
+		PlaybookID:   i.PlaybookID,
+		InstanceID:   i.ID,
+		Vars:         vars,
+		PlaybookHash: playbook.Hash(),
+		ManifestHash: manifestHash,
+		DeployedAt:   time.Now().Unix(),
+		Actor:        actor.FromContext(ctx).String(),
+	}
+	if err := revision.Save(ctx, d.store, p, r); err != nil {
+		return err
+	}
+	i.Revision = r.Number
+	return revision.Prune(ctx, d.store, p, d.Cfg.HistoryRetention)
+}
+
+// RollbackAndNotify restores the vars of revision number n of an instance and
+// redeploys it. A number of 0 rolls back to the revision before the current
+// one. The restored revision is returned along with any deployment error.
+func (d *DeploymentService) RollbackAndNotify(ctx context.Context, i *instance.Instance, n int) (*revision.Revision, error) {
+	r, err := d.rollbackRevision(ctx, i, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only restore vars the playbook still declares:
+	vars := map[string]string{}
+	if playbook, ok := d.playbooks[i.PlaybookID]; ok {
+		for _, v := range playbook.Vars {
+			vars[v] = r.Vars[v]
+		}
+	}
+	i.Vars = vars
+
+	summary := fmt.Sprintf("Instance %s/%s rolled back to revision %d", i.PlaybookID, i.ID, r.Number)
+	return r, d.deploy(ctx, i, history.ActionRollback, summary)
+}
+
+// rollbackRevision finds revision number n of i, or the one before its current
+// revision if n is 0
+func (d *DeploymentService) rollbackRevision(ctx context.Context, i *instance.Instance, n int) (*revision.Revision, error) {
+	if n == 0 {
+		if i.Revision <= 1 {
+			return nil, ErrNoPreviousRevision
+		}
+		n = i.Revision - 1
+	}
+	return revision.Find(ctx, d.store, revisionPath(d.Cfg.EtcdPath, i), n)
+}
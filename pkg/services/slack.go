@@ -1,49 +1,108 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/audit"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"github.com/namely/broadway/pkg/store"
+	"golang.org/x/net/context"
 )
 
 // SlackCommand represents a user command that came in from Slack
 type SlackCommand interface {
-	Execute() (string, error)
+	Execute(ctx context.Context) (string, error)
 }
 
 type deployCommand struct {
 	pID string
 	ID  string
 	is  *InstanceService
-	ds  *DeploymentService
+	js  *JobService
 	Cfg cfg.Type
 }
 
-func (c *deployCommand) Execute() (string, error) {
-	i, err := c.is.Show(c.pID, c.ID)
+func (c *deployCommand) Execute(ctx context.Context) (string, error) {
+	i, err := c.is.Show(ctx, c.pID, c.ID)
 	if err != nil {
-		msg := fmt.Sprintf("Failed to deploy instance %s/%s: Instance not found", c.pID, c.ID)
+		msg := lookupFailure("Failed to deploy instance", c.pID, c.ID, err)
 		glog.Error(msg)
 		return msg, err
 	}
 
-	go func() {
-		glog.Infof("Asynchronously deploying %s/%s...", i.PlaybookID, i.ID)
-		err := c.ds.DeployAndNotify(i)
-		if err != nil {
-			glog.Errorf("Slack command failed to deploy instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-			return
+	j, err := c.js.Deploy(ctx, i)
+	if err != nil {
+		msg := jobFailure("deploy", i, err)
+		glog.Error(msg)
+		return msg, err
+	}
+
+	if j.Coalesced > 0 {
+		return fmt.Sprintf("A deployment of %s/%s is queued already as job %s, it will deploy the latest vars once the current job finishes", i.PlaybookID, i.ID, j.ID), nil
+	}
+	if j.State == job.StateQueued {
+		return fmt.Sprintf("Queued deployment of %s/%s as job %s, #%d in the queue", i.PlaybookID, i.ID, j.ID, j.Position), nil
+	}
+	return fmt.Sprintf("Started deployment of %s/%s as job %s", i.PlaybookID, i.ID, j.ID), nil
+}
+
+// InvalidRollback represents an error for invalid rollback syntax
+type InvalidRollback struct{}
+
+func (e *InvalidRollback) Error() string {
+	return "Syntax error, example: rollback playbook1 instance10 3"
+}
+
+type rollbackCommand struct {
+	pID      string
+	ID       string
+	revision string
+	is       *InstanceService
+	js       *JobService
+}
+
+func (c *rollbackCommand) Execute(ctx context.Context) (string, error) {
+	n := 0
+	if c.revision != "" {
+		var err error
+		n, err = strconv.Atoi(c.revision)
+		if err != nil || n < 1 {
+			return "", &InvalidRollback{}
 		}
-		glog.Infof("Slack command successfully deployed instance %s/%s", i.PlaybookID, i.ID)
-		return
-	}()
+	}
+	i, err := c.is.Show(ctx, c.pID, c.ID)
+	if err != nil {
+		msg := lookupFailure("Failed to roll back instance", c.pID, c.ID, err)
+		glog.Error(msg)
+		return msg, err
+	}
+	if n == 0 && i.Revision <= 1 {
+		return fmt.Sprintf("Instance %s/%s has no previous revision to roll back to", i.PlaybookID, i.ID), ErrNoPreviousRevision
+	}
 
-	return fmt.Sprintf("Started deployment of %s/%s", i.PlaybookID, i.ID), nil
+	j, err := c.js.Rollback(ctx, i, n)
+	if err != nil {
+		msg := jobFailure("roll back", i, err)
+		glog.Error(msg)
+		return msg, err
+	}
+
+	if n == 0 {
+		return fmt.Sprintf("Started rollback of %s/%s to the previous revision as job %s", i.PlaybookID, i.ID, j.ID), nil
+	}
+	return fmt.Sprintf("Started rollback of %s/%s to revision %d as job %s", i.PlaybookID, i.ID, n, j.ID), nil
 }
 
 // InvalidSetVar error presentation for invalid setvar syntax
@@ -66,17 +125,18 @@ type setvarCommand struct {
 	is        *InstanceService
 }
 
-func (c *setvarCommand) Execute() (string, error) {
+func (c *setvarCommand) Execute(ctx context.Context) (string, error) {
 	var commandMsg string
 	if len(c.args) < 4 {
 		return commandHints, &InvalidSetVar{}
 	}
 	kvs := c.args[3:] // from e.g. "setvar foo bar var1=val1 var2=val2"
-	i, err := c.is.Show(c.args[1], c.args[2])
+	i, err := c.is.Show(ctx, c.args[1], c.args[2])
 	if err != nil {
 		glog.Warningf("Cannot setvars for not found instance %s/%s\n", c.args[1], c.args[2])
 		return "", err
 	}
+	before := summarize(i)
 	for _, kv := range kvs {
 		tmp := strings.SplitN(kv, "=", 2)
 		if len(tmp) != 2 {
@@ -90,7 +150,7 @@ func (c *setvarCommand) Execute() (string, error) {
 			return fmt.Sprintf("Playbook %s does not define those variables", i.PlaybookID), &InvalidSetVar{}
 		}
 	}
-	_, err = c.is.Update(i)
+	_, err = c.is.audited(ctx, audit.ActionSetVar, before, i)
 	if err != nil {
 		glog.Errorf("Failed to save instance %s/%s with new vars\n", c.args[1], c.args[2])
 		return "", err
@@ -109,18 +169,233 @@ func (c *setvarCommand) playbookContainsVar(playbookID, name string) bool {
 	return false
 }
 
+// InvalidClone represents an error for invalid clone syntax
+type InvalidClone struct{}
+
+func (e *InvalidClone) Error() string {
+	return "Syntax error, example: clone playbook1 instance10 instance11 var1=value"
+}
+
+// Clone slack command creates a new instance from the vars of another
+type cloneCommand struct {
+	args []string
+	is   *InstanceService
+}
+
+func (c *cloneCommand) Execute(ctx context.Context) (string, error) {
+	if len(c.args) < 4 {
+		return commandHints, &InvalidClone{}
+	}
+	pID, ID, newID := c.args[1], c.args[2], c.args[3]
+	overrides := map[string]string{}
+	for _, kv := range c.args[4:] { // from e.g. "clone foo bar baz var1=val1"
+		tmp := strings.SplitN(kv, "=", 2)
+		if len(tmp) != 2 {
+			glog.Warningf("Clone tried to parse badly formatted variable: %s", kv)
+			return "", &InvalidClone{}
+		}
+		overrides[tmp[0]] = tmp[1]
+	}
+
+	i, err := c.is.Clone(ctx, pID, ID, newID, overrides)
+	if err != nil {
+		if _, ok := err.(instance.NotFoundError); ok || store.IsUnavailable(err) {
+			msg := lookupFailure("Failed to clone", pID, ID, err)
+			glog.Error(msg)
+			return msg, err
+		}
+		return fmt.Sprintf("Failed to clone %s/%s: %s", pID, ID, strings.TrimSpace(err.Error())), err
+	}
+	return fmt.Sprintf("Instance %s/%s cloned from %s", i.PlaybookID, i.ID, ID), nil
+}
+
+// listLimit is the default number of instances shown by the list command
+const listLimit = 20
+
+// List slack command finds instances across playbooks
+type listCommand struct {
+	args      []string
+	is        *InstanceService
+	playbooks map[string]*deployment.Playbook
+}
+
+func (c *listCommand) Execute(ctx context.Context) (string, error) {
+	values := url.Values{}
+	for _, term := range c.args[1:] { // from e.g. "list web status=error var.owner=bill"
+		if term == "" {
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) == 1 {
+			values.Add("playbook", kv[0])
+			continue
+		}
+		values.Add(kv[0], kv[1])
+	}
+	if values.Get("limit") == "" {
+		values.Set("limit", strconv.Itoa(listLimit))
+	}
+	q, err := ParseQuery(values, c.playbooks)
+	if err != nil {
+		return err.Error(), err
+	}
+
+	instances, total, err := c.is.Query(ctx, q)
+	if err != nil {
+		if store.IsUnavailable(err) {
+			return "Failed to list instances: Broadway's store is unavailable, try again later", err
+		}
+		return "Failed to list instances: " + err.Error(), err
+	}
+	if total == 0 {
+		return "No instances found", nil
+	}
+
+	now := time.Now()
+	msg := fmt.Sprintf("Found %d instances:\n", total)
+	for _, i := range instances {
+		msg += fmt.Sprintf("  - %s/%s: %s, expires %s\n", i.PlaybookID, i.ID, i.Status, fmtExpiration(i, now))
+	}
+	if shown := q.Offset + len(instances); shown < total {
+		msg += fmt.Sprintf("Use offset=%d to see more\n", shown)
+	}
+	return msg, nil
+}
+
+// Playbooks slack command lists the playbooks Broadway knows about
+type playbooksCommand struct {
+	ps *PlaybookService
+}
+
+func (c *playbooksCommand) Execute(ctx context.Context) (string, error) {
+	playbooks, err := c.ps.All(ctx)
+	if err != nil {
+		if store.IsUnavailable(err) {
+			return "Failed to list playbooks: Broadway's store is unavailable, try again later", err
+		}
+		return "Failed to list playbooks: " + err.Error(), err
+	}
+	if len(playbooks) == 0 {
+		return "No playbooks found", nil
+	}
+	msg := "Playbooks:\n"
+	for _, p := range playbooks {
+		total := 0
+		for _, n := range p.Instances {
+			total += n
+		}
+		msg += fmt.Sprintf("  - %s: %s", p.ID, p.Name)
+		if p.Meta.Team != "" {
+			msg += fmt.Sprintf(" (team %s)", p.Meta.Team)
+		}
+		msg += fmt.Sprintf(", vars: %s, %d instances\n", strings.Join(p.Vars, " "), total)
+	}
+	return msg, nil
+}
+
+// Slack replies show this many lines of each pod's logs by default
+const slackLogLines = 20
+
+// slackLogLimit bounds the length of the logs in a Slack reply, which Slack
+// would cut off arbitrarily
+const slackLogLimit = 3000
+
+// logsCommand shows the recent logs of an instance's pods
+type logsCommand struct {
+	pID       string
+	ID        string
+	container string
+	tail      int64
+	is        *InstanceService
+	js        *JobService
+}
+
+// newLogsCommand parses `logs pb inst [container] [--tail N]`
+func newLogsCommand(terms []string, is *InstanceService, js *JobService) SlackCommand {
+	c := &logsCommand{pID: terms[1], ID: terms[2], tail: slackLogLines, is: is, js: js}
+	for k := 3; k < len(terms); k++ {
+		if terms[k] != "--tail" {
+			c.container = terms[k]
+			continue
+		}
+		if k+1 == len(terms) {
+			return &helpCommand{}
+		}
+		k++
+		if _, err := fmt.Sscan(terms[k], &c.tail); err != nil || c.tail <= 0 {
+			return &helpCommand{}
+		}
+	}
+	return c
+}
+
+func (c *logsCommand) Execute(ctx context.Context) (string, error) {
+	i, err := c.is.Show(ctx, c.pID, c.ID)
+	if err != nil {
+		msg := lookupFailure("Failed to read the logs of", c.pID, c.ID, err)
+		return msg, errors.New(msg)
+	}
+	logs, err := c.js.ds.Logs(ctx, i, deployment.LogOptions{Container: c.container, TailLines: c.tail})
+	if err != nil {
+		msg := fmt.Sprintf("Failed to read the logs of %s/%s: %s", c.pID, c.ID, err)
+		return msg, errors.New(msg)
+	}
+	defer func() {
+		for _, l := range logs {
+			l.Stream.Close()
+		}
+	}()
+
+	msg := fmt.Sprintf("Logs of %s/%s:\n", c.pID, c.ID)
+	budget := slackLogLimit / len(logs)
+	for _, l := range logs {
+		text, err := ioutil.ReadAll(io.LimitReader(l.Stream, 1<<20))
+		if err != nil {
+			msg += fmt.Sprintf("%s/%s: %s\n", l.Pod, l.Container, err)
+			continue
+		}
+		msg += fmt.Sprintf("%s/%s:\n```%s```\n", l.Pod, l.Container, logTail(string(text), budget))
+	}
+	return msg, nil
+}
+
+// logTail returns the end of text that fits in limit bytes, starting on a new
+// line
+func logTail(text string, limit int) string {
+	text = strings.TrimRight(text, "\n")
+	if len(text) <= limit {
+		return text
+	}
+	text = text[len(text)-limit:]
+	if n := strings.Index(text, "\n"); n >= 0 {
+		text = text[n+1:]
+	}
+	return "…\n" + text
+}
+
 // CommandHints slack commands help hints
 const commandHints = `
 */bw deploy myPlaybookID myInstanceID*: Deploy an instance
-*/bw info myPlaybookID myInstanceID*: Display the age and playbook variables of an instance
+*/bw info myPlaybookID myInstanceID*: Display the age, remaining lifetime and playbook variables of an instance
 */bw stop myPlaybookID myInstanceID*: Stop an instance
+*/bw job myJobID*: Display the progress of a deploy or stop
+*/bw queue*: List the running deploys and stops and the ones waiting their turn
+*/bw cancel myPlaybookID myInstanceID*: Cancel the deploys and stops running or waiting on an instance
+*/bw logs myPlaybookID myInstanceID [container] [--tail 20]*: Display the recent logs of the pods of an instance
+*/bw history myPlaybookID myInstanceID*: Display the latest deploys and stops of an instance
+*/bw rollback myPlaybookID myInstanceID [revision]*: Redeploy an instance with the vars of a previous revision
+*/bw extend myPlaybookID myInstanceID 3d*: Push back the expiration of an instance
+*/bw &lt;pin|unpin&gt; myPlaybookID myInstanceID*: Keep an instance from expiring, or let it expire again
 */bw &lt;setvar|setvars&gt; myPlaybookID myInstanceID var1=val1 ...* : Set one or more playbook variables for an instance
+*/bw clone myPlaybookID myInstanceID newInstanceID [var1=val1 ...]*: Create a new instance with the variables of an existing one
+*/bw playbooks*: List the playbooks with their variables
+*/bw list [myPlaybookID] [status=error] [team=myTeam] [var.owner=bill] [sort=-created] ...*: Find instances across playbooks
 `
 
 // Help slack command
 type helpCommand struct{}
 
-func (c *helpCommand) Execute() (string, error) {
+func (c *helpCommand) Execute(ctx context.Context) (string, error) {
 	return commandHints, nil
 }
 
@@ -137,36 +412,116 @@ type stopCommand struct {
 	ID  string
 	is  *InstanceService
 	Cfg cfg.Type
-	ds  *DeploymentService
+	js  *JobService
 }
 
-func (c *stopCommand) Execute() (string, error) {
-	i, err := c.is.Show(c.pID, c.ID)
+func (c *stopCommand) Execute(ctx context.Context) (string, error) {
+	i, err := c.is.Show(ctx, c.pID, c.ID)
 	if err != nil {
-		msg := fmt.Sprintf("Failed to stop instance %s/%s: Instance not found", c.pID, c.ID)
+		msg := lookupFailure("Failed to stop instance", c.pID, c.ID, err)
 		glog.Error(msg)
 		return msg, err
 	}
 
-	go func() {
-		glog.Infof("Asynchronously stopping %s/%s...", i.PlaybookID, i.ID)
+	j, err := c.js.Stop(ctx, i)
+	if err != nil {
+		msg := jobFailure("stop", i, err)
+		glog.Error(msg)
+		return msg, err
+	}
 
-		if err := c.ds.StopAndNotify(i); err != nil {
-			glog.Errorf("Slack command failed to stop instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-			return
-		}
+	return fmt.Sprintf("Stopping instance: %s/%s as job %s", i.PlaybookID, i.ID, j.ID), nil
+}
+
+// Queue slack command lists the running jobs and the jobs waiting for a worker
+type queueCommand struct {
+	js *JobService
+}
 
-		i.Status = instance.StatusNew
-		if _, err := c.is.Update(i); err != nil {
-			glog.Errorf("Slack command failed to stop instance %s/%s:\n%s\n", i.PlaybookID, i.ID, err)
-			return
+func (c *queueCommand) Execute(ctx context.Context) (string, error) {
+	running, queued := c.js.Queue()
+	if len(running) == 0 && len(queued) == 0 {
+		return "No jobs are running or queued", nil
+	}
+	msg := fmt.Sprintf("%d jobs running, %d queued:\n", len(running), len(queued))
+	for _, j := range running {
+		msg += fmt.Sprintf("  - running: %s %s/%s by %s, %d of %d steps done (job %s)\n", j.Action, j.PlaybookID, j.InstanceID, j.Actor, len(j.Steps), j.TotalSteps, j.ID)
+	}
+	for _, j := range queued {
+		msg += fmt.Sprintf("  - #%d: %s %s/%s by %s, queued %s ago (job %s)\n", j.Position, j.Action, j.PlaybookID, j.InstanceID, j.Actor, fmtAge(j.CreatedAt), j.ID)
+	}
+	return msg, nil
+}
+
+// Cancel slack command cancels the jobs running or waiting on an instance
+type cancelCommand struct {
+	pID string
+	ID  string
+	js  *JobService
+}
+
+func (c *cancelCommand) Execute(ctx context.Context) (string, error) {
+	jobs, err := c.js.CancelInstance(ctx, c.pID, c.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Can't cancel %s/%s: No deploy or stop is running or queued", c.pID, c.ID)
+		glog.Error(msg)
+		return msg, err
+	}
+	msg := ""
+	for _, j := range jobs {
+		if j.State == job.StateCancelled {
+			msg += fmt.Sprintf("Cancelled job %s (%s %s/%s) before it started\n", j.ID, j.Action, j.PlaybookID, j.InstanceID)
+		} else {
+			msg += fmt.Sprintf("Cancelling job %s (%s %s/%s), the remaining steps will be skipped\n", j.ID, j.Action, j.PlaybookID, j.InstanceID)
 		}
+	}
+	return msg, nil
+}
 
-		glog.Infof("Slack command successfully stopped instance %s/%s", i.PlaybookID, i.ID)
-		return
-	}()
+// jobFailure explains why a job could not be started for a command
+func jobFailure(verb string, i *instance.Instance, err error) string {
+	if _, ok := err.(*instance.InvalidTransition); ok {
+		return fmt.Sprintf("Can't %s %s/%s: %s", verb, i.PlaybookID, i.ID, refusal(i, err))
+	}
+	if store.IsUnavailable(err) {
+		return fmt.Sprintf("Failed to %s %s/%s: Broadway's store is unavailable, try again later", verb, i.PlaybookID, i.ID)
+	}
+	return fmt.Sprintf("Failed to %s %s/%s: %s", verb, i.PlaybookID, i.ID, err)
+}
 
-	return fmt.Sprintf("Stopping instance: %s/%s", i.PlaybookID, i.ID), nil
+// Job slack command reports the progress of a job
+type jobCommand struct {
+	ID string
+	js *JobService
+}
+
+func (c *jobCommand) Execute(ctx context.Context) (string, error) {
+	j, err := c.js.Show(ctx, c.ID)
+	if err != nil {
+		if store.IsUnavailable(err) {
+			return fmt.Sprintf("Failed to retrieve job %s: Broadway's store is unavailable, try again later", c.ID), err
+		}
+		return fmt.Sprintf("Failed to retrieve job %s: Job not found", c.ID), err
+	}
+	msg := fmt.Sprintf("Job %s: %s %s/%s by %s\n", j.ID, j.Action, j.PlaybookID, j.InstanceID, j.Actor)
+	msg += fmt.Sprintf("State: %s, %d of %d steps done\n", j.State, len(j.Steps), j.TotalSteps)
+	if j.Position > 0 {
+		msg += fmt.Sprintf("Position in queue: %d\n", j.Position)
+	}
+	if j.StartedAt != 0 {
+		msg += fmt.Sprintf("Started: %s ago\n", fmtAge(j.StartedAt))
+	}
+	for _, step := range j.Steps {
+		outcome := fmt.Sprintf("%ds", step.FinishedAt-step.StartedAt)
+		if step.Error != "" {
+			outcome = "failed: " + step.Error
+		}
+		msg += fmt.Sprintf("  - %s (%s)\n", step.Name, outcome)
+	}
+	if j.Error != "" {
+		msg += fmt.Sprintf("Error: %s\n", j.Error)
+	}
+	return msg, nil
 }
 
 // Info slack command returns info about the instance
@@ -174,12 +529,13 @@ type infoCommand struct {
 	pID string
 	ID  string
 	is  *InstanceService
+	js  *JobService
 }
 
-func (c *infoCommand) Execute() (string, error) {
-	i, err := c.is.Show(c.pID, c.ID)
+func (c *infoCommand) Execute(ctx context.Context) (string, error) {
+	i, err := c.is.Show(ctx, c.pID, c.ID)
 	if err != nil {
-		msg := fmt.Sprintf("Failed to retrieve info for %s/%s: Instance not found", c.pID, c.ID)
+		msg := lookupFailure("Failed to retrieve info for", c.pID, c.ID, err)
 		glog.Error(msg)
 		return msg, err
 	}
@@ -196,6 +552,34 @@ func (c *infoCommand) Execute() (string, error) {
 	m2 := fmt.Sprintf("Instance: %s\n", wrapQuotes(i.ID))
 	m3 := fmt.Sprintf("Age: %s\n", wrapQuotes(fmtAge(i.Created)))
 	m4 := fmt.Sprintf("Status: %s\n", wrapQuotes(string(i.Status)))
+	if since, ok := i.StatusSince(); ok {
+		m4 += fmt.Sprintf("Since: %s\n", wrapQuotes(fmtAge(since.Unix())))
+	}
+	if i.LastError != "" {
+		m4 += fmt.Sprintf("Last error: %s\n", wrapQuotes(i.LastError))
+	}
+	if i.Target != "" {
+		m4 += fmt.Sprintf("Target: %s\n", wrapQuotes(i.Target))
+	}
+	if i.Revision > 0 {
+		m4 += fmt.Sprintf("Revision: %s\n", wrapQuotes(strconv.Itoa(i.Revision)))
+	}
+	if len(i.Drift) > 0 {
+		m4 += fmt.Sprintf("Drifted for: %s\n", wrapQuotes(fmtAge(i.DriftedAt)))
+		for _, d := range i.Drift {
+			m4 += fmt.Sprintf("  - %s %s from %s: %s\n", d.Kind, d.Name, d.Manifest, d.State)
+		}
+	}
+	if c.js != nil {
+		live, err := c.js.ds.LiveStatus(ctx, i)
+		if err != nil {
+			glog.Errorf("Failed to read the live status of %s/%s: %s", i.PlaybookID, i.ID, err)
+			m4 += fmt.Sprintf("Live: %s\n", wrapQuotes("unavailable: "+err.Error()))
+		} else if len(live) > 0 {
+			m4 += "Live:\n" + fmtLiveStatus(live)
+		}
+	}
+	m4 += fmt.Sprintf("Expires: %s\n", wrapQuotes(fmtExpiration(i, time.Now())))
 	m5 := "Vars:\n"
 	for _, vr := range vv {
 		m5 += fmt.Sprintf("  - %s: %s\n", vr.k, wrapQuotes(vr.v))
@@ -204,6 +588,182 @@ func (c *infoCommand) Execute() (string, error) {
 	return msg, nil
 }
 
+// fmtLiveStatus lists the live state of objects, with the pods of each
+func fmtLiveStatus(objects []deployment.ObjectStatus) string {
+	msg := ""
+	for _, o := range objects {
+		state := []string{}
+		switch {
+		case o.Missing:
+			state = append(state, "missing")
+		case o.Replicas != nil:
+			state = append(state, fmt.Sprintf("%d/%d ready, %d current", o.Replicas.Ready, o.Replicas.Desired, o.Replicas.Current))
+		case o.ClusterIP != "":
+			state = append(state, o.ClusterIP)
+		}
+		for _, p := range o.Ports {
+			port := fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+			if p.NodePort != 0 {
+				port += fmt.Sprintf(" on node port %d", p.NodePort)
+			}
+			state = append(state, port)
+		}
+		msg += fmt.Sprintf("  - %s %s from %s", o.Kind, o.Name, o.Manifest)
+		if len(state) > 0 {
+			msg += ": " + strings.Join(state, ", ")
+		}
+		msg += "\n"
+		for _, p := range o.Pods {
+			pod := []string{p.Phase}
+			if !p.Ready {
+				pod = append(pod, "not ready")
+			}
+			if p.Restarts > 0 {
+				pod = append(pod, fmt.Sprintf("%d restarts", p.Restarts))
+			}
+			containers := []string{}
+			for c := range p.Waiting {
+				containers = append(containers, c)
+			}
+			sort.Strings(containers)
+			for _, c := range containers {
+				pod = append(pod, fmt.Sprintf("%s %s", c, p.Waiting[c]))
+			}
+			msg += fmt.Sprintf("    - pod %s: %s\n", p.Name, strings.Join(pod, ", "))
+		}
+	}
+	return msg
+}
+
+// lookupFailure explains why an instance could not be loaded for a command
+func lookupFailure(prefix, pID, ID string, err error) string {
+	if store.IsUnavailable(err) {
+		return fmt.Sprintf("%s %s/%s: Broadway's store is unavailable, try again later", prefix, pID, ID)
+	}
+	return fmt.Sprintf("%s %s/%s: Instance not found", prefix, pID, ID)
+}
+
+// History slack command lists the latest deploys and stops of an instance
+type historyCommand struct {
+	pID string
+	ID  string
+	is  *InstanceService
+}
+
+// historyLimit is the number of records shown by the history command
+const historyLimit = 10
+
+func (c *historyCommand) Execute(ctx context.Context) (string, error) {
+	records, err := c.is.History(ctx, c.pID, c.ID)
+	if err != nil {
+		msg := lookupFailure("Failed to retrieve history for", c.pID, c.ID, err)
+		glog.Error(msg)
+		return msg, err
+	}
+	if len(records) == 0 {
+		return fmt.Sprintf("Instance %s/%s has no history yet", c.pID, c.ID), nil
+	}
+	if len(records) > historyLimit {
+		records = records[:historyLimit]
+	}
+	msg := fmt.Sprintf("History of %s/%s:\n", c.pID, c.ID)
+	for _, r := range records {
+		outcome := "ok"
+		if r.Revision > 0 {
+			outcome = fmt.Sprintf("ok, revision %d", r.Revision)
+		}
+		if r.Error != "" {
+			outcome = "failed: " + r.Error
+		} else if r.FinishedAt == 0 {
+			outcome = "unfinished"
+		}
+		msg += fmt.Sprintf("  - %s ago: %s by %s (%s)\n", fmtAge(r.StartedAt), r.Action, r.Actor, outcome)
+	}
+	return msg, nil
+}
+
+// InvalidExtend represents an error for invalid extend syntax
+type InvalidExtend struct{}
+
+func (e *InvalidExtend) Error() string {
+	return "Syntax error, example: extend playbook1 instance10 3d"
+}
+
+// Extend slack command pushes back the expiration of an instance
+type extendCommand struct {
+	pID      string
+	ID       string
+	lifetime string
+	is       *InstanceService
+}
+
+func (c *extendCommand) Execute(ctx context.Context) (string, error) {
+	d, err := instance.ParseLifetime(c.lifetime)
+	if err != nil || d <= 0 {
+		return "", &InvalidExtend{}
+	}
+	i, err := c.is.Extend(ctx, c.pID, c.ID, d)
+	if err != nil {
+		msg := lookupFailure("Failed to extend instance", c.pID, c.ID, err)
+		glog.Error(msg)
+		return msg, err
+	}
+	return fmt.Sprintf("Instance %s/%s now expires %s", i.PlaybookID, i.ID, fmtExpiration(i, time.Now())), nil
+}
+
+// Pin slack command pins or unpins an instance
+type pinCommand struct {
+	pID    string
+	ID     string
+	pinned bool
+	is     *InstanceService
+}
+
+func (c *pinCommand) Execute(ctx context.Context) (string, error) {
+	i, err := c.is.SetPinned(ctx, c.pID, c.ID, c.pinned)
+	if err != nil {
+		verb := "pin"
+		if !c.pinned {
+			verb = "unpin"
+		}
+		msg := lookupFailure("Failed to "+verb+" instance", c.pID, c.ID, err)
+		glog.Error(msg)
+		return msg, err
+	}
+	if i.Pinned {
+		return fmt.Sprintf("Instance %s/%s is pinned and will not expire", i.PlaybookID, i.ID), nil
+	}
+	return fmt.Sprintf("Instance %s/%s is unpinned and expires %s", i.PlaybookID, i.ID, fmtExpiration(i, time.Now())), nil
+}
+
+// fmtExpiration describes when an instance expires relative to now
+func fmtExpiration(i *instance.Instance, now time.Time) string {
+	left, ok := i.ExpiresIn(now)
+	switch {
+	case !ok && i.Pinned:
+		return "never (pinned)"
+	case !ok:
+		return "never"
+	case left <= 0:
+		return "now (expired)"
+	}
+	return "in " + fmtLifetime(left)
+}
+
+// fmtLifetime formats a duration with its two most significant units
+func fmtLifetime(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
 func wrapQuotes(s string) string {
 	return fmt.Sprintf("\"%s\"", s)
 }
@@ -223,11 +783,17 @@ func fmtAge(d0 int64) string {
 }
 
 // BuildSlackCommand takes a string and some context and creates a SlackCommand
-func BuildSlackCommand(cfg cfg.Type, payload string, ds *DeploymentService, is *InstanceService, playbooks map[string]*deployment.Playbook) SlackCommand {
+func BuildSlackCommand(cfg cfg.Type, payload string, js *JobService, is *InstanceService, playbooks map[string]*deployment.Playbook) SlackCommand {
 	terms := strings.Split(payload, " ")
 	switch terms[0] {
 	case "setvar", "setvars": // setvar foo bar var1=val1 var2=val2
 		return &setvarCommand{args: terms, is: is, playbooks: playbooks}
+	case "playbooks":
+		return &playbooksCommand{ps: NewPlaybookService(cfg, is.store, playbooks)}
+	case "list": // list foo status=error var.owner=bill
+		return &listCommand{args: terms, is: is, playbooks: playbooks}
+	case "clone": // clone foo bar baz var1=val1
+		return &cloneCommand{args: terms, is: is}
 	case "deploy":
 		if len(terms) < 3 {
 			return &helpCommand{}
@@ -236,19 +802,60 @@ func BuildSlackCommand(cfg cfg.Type, payload string, ds *DeploymentService, is *
 			pID: terms[1],
 			ID:  terms[2],
 			is:  is,
-			ds:  ds,
+			js:  js,
 			Cfg: cfg,
 		}
 	case "stop":
 		if len(terms) < 3 {
 			return &helpCommand{}
 		}
-		return &stopCommand{pID: terms[1], ID: terms[2], is: is, ds: ds, Cfg: cfg}
+		return &stopCommand{pID: terms[1], ID: terms[2], is: is, js: js, Cfg: cfg}
+	case "queue":
+		return &queueCommand{js: js}
+	case "cancel":
+		if len(terms) < 3 {
+			return &helpCommand{}
+		}
+		return &cancelCommand{pID: terms[1], ID: terms[2], js: js}
+	case "job":
+		if len(terms) < 2 {
+			return &helpCommand{}
+		}
+		return &jobCommand{ID: terms[1], js: js}
+	case "logs":
+		if len(terms) < 3 {
+			return &helpCommand{}
+		}
+		return newLogsCommand(terms, is, js)
 	case "info":
 		if len(terms) < 3 {
 			return &helpCommand{}
 		}
-		return &infoCommand{pID: terms[1], ID: terms[2], is: is}
+		return &infoCommand{pID: terms[1], ID: terms[2], is: is, js: js}
+	case "extend":
+		if len(terms) < 4 {
+			return &helpCommand{}
+		}
+		return &extendCommand{pID: terms[1], ID: terms[2], lifetime: terms[3], is: is}
+	case "pin", "unpin":
+		if len(terms) < 3 {
+			return &helpCommand{}
+		}
+		return &pinCommand{pID: terms[1], ID: terms[2], pinned: terms[0] == "pin", is: is}
+	case "rollback":
+		if len(terms) < 3 {
+			return &helpCommand{}
+		}
+		c := &rollbackCommand{pID: terms[1], ID: terms[2], is: is, js: js}
+		if len(terms) > 3 {
+			c.revision = terms[3]
+		}
+		return c
+	case "history":
+		if len(terms) < 3 {
+			return &helpCommand{}
+		}
+		return &historyCommand{pID: terms[1], ID: terms[2], is: is}
 	default:
 		return &helpCommand{}
 	}
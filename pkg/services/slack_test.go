@@ -1,16 +1,22 @@
 package services
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/namely/broadway/pkg/actor"
 	"github.com/namely/broadway/pkg/cfg"
 	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/history"
 	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/job"
+	"github.com/namely/broadway/pkg/store"
 	"github.com/namely/broadway/pkg/store/etcdstore"
 	"github.com/namely/broadway/pkg/testutils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 var testCfg = cfg.Type{}
@@ -52,18 +58,101 @@ func TestDeployExecute(t *testing.T) {
 	}
 
 	for _, testcase := range testcases {
-		_, err := is.CreateOrUpdate(testcase.Instance)
+		_, err := is.CreateOrUpdate(context.Background(), testcase.Instance)
 		if err != nil {
 			t.Log(err)
 		}
-		command := BuildSlackCommand(testutils.TestCfg, testcase.Arguments, ds, is, testcase.Playbooks)
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Arguments, NewJobService(ds), is, testcase.Playbooks)
 
-		msg, err := command.Execute()
-		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+		msg, err := command.Execute(context.Background())
+		assert.Contains(t, msg, testcase.ExpectedMsg, testcase.Scenario)
 		assert.Equal(t, testcase.E, err, testcase.Scenario)
 	}
 }
 
+func TestDeployRefused(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	busy := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "busy",
+		Status:     instance.StatusDeploying,
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "busy"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, busy))
+
+	command := BuildSlackCommand(testutils.TestCfg, "deploy helloplaybook busy", NewJobService(ds), is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.IsType(t, &instance.InvalidTransition{}, err)
+	assert.Equal(t, "Can't deploy helloplaybook/busy: Instance is being deployed already.", msg)
+
+	jobs, err := job.List(ctx, s, testutils.TestCfg.EtcdPath)
+	assert.Nil(t, err)
+	assert.Empty(t, jobs, "refused deploys don't start a job")
+}
+
+func TestDeployQueued(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests))
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "ci",
+		Status:     instance.StatusDeploying,
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "ci"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	release := make(chan struct{})
+	_, err := js.start(ctx, job.ActionDeploy, i, func(ctx context.Context, i *instance.Instance) error {
+		<-release
+		return nil
+	})
+	assert.Nil(t, err)
+
+	command := BuildSlackCommand(testutils.TestCfg, "deploy helloplaybook ci", js, is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "Queued deployment of helloplaybook/ci as job")
+	msg, err = command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "it will deploy the latest vars once the current job finishes")
+
+	_, err = js.CancelInstance(ctx, "helloplaybook", "ci")
+	assert.Nil(t, err)
+	close(release)
+	js.Wait()
+}
+
+func TestJobExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+
+	j := job.New(job.ActionDeploy, "helloplaybook", "jobbed", "slack:bill", time.Now())
+	j.TotalSteps = 2
+	j.Start(time.Now())
+	j.Steps = append(j.Steps, history.Step{Name: "hello-rc", Error: "boom"})
+	j.Finish(errors.New("boom"), time.Now())
+	assert.Nil(t, job.Save(ctx, s, testutils.TestCfg.EtcdPath, j))
+
+	command := BuildSlackCommand(testutils.TestCfg, "job "+j.ID, NewJobService(ds), is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "deploy helloplaybook/jobbed by slack:bill")
+	assert.Contains(t, msg, "State: failed, 1 of 2 steps done")
+	assert.Contains(t, msg, "  - hello-rc (failed: boom)")
+	assert.Contains(t, msg, "Error: boom")
+
+	command = BuildSlackCommand(testutils.TestCfg, "job missing", NewJobService(ds), is, testPlaybooks)
+	msg, err = command.Execute(ctx)
+	assert.Equal(t, job.NotFoundError("missing"), err)
+	assert.Equal(t, "Failed to retrieve job missing: Job not found", msg)
+}
+
 func TestSetvarExecute(t *testing.T) {
 	nt := newNotificationTestHelper()
 	defer nt.Close()
@@ -179,17 +268,17 @@ func TestSetvarExecute(t *testing.T) {
 	}
 
 	for _, testcase := range testcases {
-		_, err := is.CreateOrUpdate(testcase.Instance)
+		_, err := is.CreateOrUpdate(context.Background(), testcase.Instance)
 		if err != nil {
 			t.Fatal(err)
 		}
-		command := BuildSlackCommand(testutils.TestCfg, testcase.Arguments, ds, is, testcase.Playbooks)
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Arguments, NewJobService(ds), is, testcase.Playbooks)
 
-		msg, err := command.Execute()
+		msg, err := command.Execute(context.Background())
 		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
 		assert.Equal(t, testcase.ExpectedErr, err, testcase.Scenario)
 
-		updatedInstance, err := is.Show(testcase.Instance.PlaybookID, testcase.Instance.ID)
+		updatedInstance, err := is.Show(context.Background(), testcase.Instance.PlaybookID, testcase.Instance.ID)
 		assert.Nil(t, err)
 		assert.Equal(t, testcase.ExpectedVars, updatedInstance.Vars, testcase.Scenario)
 	}
@@ -230,24 +319,25 @@ func TestStop(t *testing.T) {
 	}
 	is := NewInstanceService(testutils.TestCfg, etcdstore.New())
 	ds := NewDeploymentService(testutils.TestCfg, etcdstore.New(), testPlaybooks, testManifests)
+	ds.Deployers = deployment.NewFake().Factory
 	for _, testcase := range testcases {
-		_, err := is.CreateOrUpdate(testcase.Instance)
+		_, err := is.CreateOrUpdate(context.Background(), testcase.Instance)
 		if err != nil {
 			t.Log(err)
 		}
 		command := BuildSlackCommand(
 			testutils.TestCfg,
 			testcase.Args,
-			ds,
+			NewJobService(ds),
 			is,
 			map[string]*deployment.Playbook{
 				"helloplaybook": {ID: "randomapp"},
 			},
 		)
 
-		msg, err := command.Execute()
+		msg, err := command.Execute(context.Background())
 		assert.Equal(t, testcase.ExpectedErr, err, testcase.Scenario)
-		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+		assert.Contains(t, msg, testcase.ExpectedMsg, testcase.Scenario)
 		// Wait for Kubernetes to destroy the pod so we can recreate and destroy it in future test cases:
 		time.Sleep(3 * time.Second)
 	}
@@ -276,8 +366,8 @@ func TestHelpExecute(t *testing.T) {
 	is := NewInstanceService(testutils.TestCfg, etcdstore.New())
 	ds := NewDeploymentService(testutils.TestCfg, etcdstore.New(), testPlaybooks, testManifests)
 	for _, testcase := range testcases {
-		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, ds, is, nil)
-		msg, err := command.Execute()
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, NewJobService(ds), is, nil)
+		msg, err := command.Execute(context.Background())
 		assert.Equal(t, testcase.ExpectedErr, err, testcase.Scenario)
 		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
 	}
@@ -304,6 +394,10 @@ func TestInfoExecute(t *testing.T) {
 Instance: "showinfo"
 Age: "3s"
 Status: "deployed"
+Since: "3s"
+Live:
+  - Fake hello from hello: missing
+Expires: "in 4d23h"
 Vars:
   - bird: "albatross"
   - word: "phlegmatic"
@@ -328,14 +422,14 @@ Vars:
 	is := NewInstanceService(testutils.TestCfg, etcdstore.New())
 	ds := NewDeploymentService(testutils.TestCfg, etcdstore.New(), testPlaybooks, testManifests)
 	for _, testcase := range testcases {
-		_, err := is.CreateOrUpdate(testcase.Instance)
+		_, err := is.CreateOrUpdate(context.Background(), testcase.Instance)
 		if err != nil {
 			t.Log(err)
 		}
 		command := BuildSlackCommand(
 			testutils.TestCfg,
 			testcase.Args,
-			ds,
+			NewJobService(ds),
 			is,
 			map[string]*deployment.Playbook{
 				"helloplaybook": {ID: "showinfo"},
@@ -343,8 +437,403 @@ Vars:
 		)
 		// CreateOrUpdate always resets instance.Created so we can't mock it:
 		time.Sleep(3 * time.Second)
-		msg, err := command.Execute()
+		msg, err := command.Execute(context.Background())
 		assert.IsType(t, testcase.ExpectedErr, err, testcase.Scenario)
 		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
 	}
 }
+
+func TestInfoDrift(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "drifted",
+		Status:     instance.StatusDeployed,
+		Drift:      []instance.Drift{{Manifest: "hello-rc", Kind: "ReplicationController", Name: "hello", State: "changed"}},
+		DriftedAt:  time.Now().Add(-time.Hour).Unix(),
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "drifted"},
+	}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	command := BuildSlackCommand(testutils.TestCfg, "info helloplaybook drifted", nil, is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "Drifted for: \"1h\"\n  - ReplicationController hello from hello-rc: changed\n")
+}
+
+func TestFmtLiveStatus(t *testing.T) {
+	objects := []deployment.ObjectStatus{
+		{
+			Manifest: "hello-rc",
+			Kind:     "ReplicationController",
+			Name:     "hello",
+			Replicas: &deployment.ReplicaStatus{Desired: 2, Current: 2, Ready: 1},
+			Pods: []deployment.PodStatus{
+				{Name: "hello-a", Phase: "Running", Ready: true},
+				{Name: "hello-b", Phase: "Pending", Restarts: 3, Waiting: map[string]string{"web": "ImagePullBackOff", "sidecar": "CrashLoopBackOff"}},
+			},
+		},
+		{
+			Manifest:  "hello-service",
+			Kind:      "Service",
+			Name:      "hello",
+			ClusterIP: "10.0.0.12",
+			Ports:     []deployment.ServicePort{{Protocol: "TCP", Port: 80, NodePort: 30080}},
+		},
+		{Manifest: "hello-pod", Kind: "Pod", Name: "job", Missing: true},
+	}
+	assert.Equal(t, `  - ReplicationController hello from hello-rc: 1/2 ready, 2 current
+    - pod hello-a: Running
+    - pod hello-b: Pending, not ready, 3 restarts, sidecar CrashLoopBackOff, web ImagePullBackOff
+  - Service hello from hello-service: 10.0.0.12, 80/TCP on node port 30080
+  - Pod job from hello-pod: missing
+`, fmtLiveStatus(objects))
+}
+
+func TestHistoryExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "withhistory"})
+	assert.Nil(t, err)
+
+	command := BuildSlackCommand(testutils.TestCfg, "history helloplaybook withhistory", NewJobService(ds), is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "Instance helloplaybook/withhistory has no history yet", msg)
+
+	rec := history.NewRecord("helloplaybook", "withhistory", history.ActionDeploy, "slack:bill", nil, time.Now())
+	rec.Finish(errors.New("image not found"), time.Now())
+	assert.Nil(t, history.Save(ctx, s, testutils.TestCfg.EtcdPath, rec))
+
+	msg, err = command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "deploy by slack:bill (failed: image not found)")
+
+	command = BuildSlackCommand(testutils.TestCfg, "history helloplaybook nohistory", NewJobService(ds), is, testPlaybooks)
+	msg, err = command.Execute(ctx)
+	assert.IsType(t, instance.NotFoundError(""), err)
+	assert.Equal(t, "Failed to retrieve history for helloplaybook/nohistory: Instance not found", msg)
+}
+
+func TestRollbackExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "rollmeback"})
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		Scenario    string
+		Args        string
+		ExpectedMsg string
+		ExpectedErr error
+	}{
+		{
+			"rollback with a bad revision fails",
+			"rollback helloplaybook rollmeback two",
+			"",
+			&InvalidRollback{},
+		},
+		{
+			"rollback without a previous revision fails",
+			"rollback helloplaybook rollmeback",
+			"Instance helloplaybook/rollmeback has no previous revision to roll back to",
+			ErrNoPreviousRevision,
+		},
+		{
+			"rollback of a missing instance fails",
+			"rollback helloplaybook missing 1",
+			"Failed to roll back instance helloplaybook/missing: Instance not found",
+			instance.NotFoundError(""),
+		},
+	}
+	for _, testcase := range testcases {
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, NewJobService(ds), is, testPlaybooks)
+		msg, err := command.Execute(ctx)
+		assert.IsType(t, testcase.ExpectedErr, err, testcase.Scenario)
+		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+	}
+}
+
+func TestExtendAndPinExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "longlived",
+		ExpiredAt:  time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		Scenario    string
+		Args        string
+		ExpectedMsg string
+		ExpectedErr error
+	}{
+		{
+			"extend with a bad lifetime fails",
+			"extend helloplaybook longlived soon",
+			"",
+			&InvalidExtend{},
+		},
+		{
+			"extend pushes back the expiration",
+			"extend helloplaybook longlived 2d",
+			"Instance helloplaybook/longlived now expires in 2d0h",
+			nil,
+		},
+		{
+			"pin disables the expiration",
+			"pin helloplaybook longlived",
+			"Instance helloplaybook/longlived is pinned and will not expire",
+			nil,
+		},
+		{
+			"unpin restores the expiration",
+			"unpin helloplaybook longlived",
+			"Instance helloplaybook/longlived is unpinned and expires in 2d0h",
+			nil,
+		},
+		{
+			"pin of a missing instance fails",
+			"pin helloplaybook missing",
+			"Failed to pin instance helloplaybook/missing: Instance not found",
+			instance.NotFoundError(""),
+		},
+	}
+	for _, testcase := range testcases {
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, NewJobService(ds), is, testPlaybooks)
+		msg, err := command.Execute(ctx)
+		assert.IsType(t, testcase.ExpectedErr, err, testcase.Scenario)
+		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+	}
+}
+
+func TestCloneExecute(t *testing.T) {
+	nt := newNotificationTestHelper()
+	defer nt.Close()
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	_, err := is.CreateOrUpdate(ctx, &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "staging",
+		Vars:       map[string]string{"word": "staging"},
+	})
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		Scenario    string
+		Args        string
+		ExpectedMsg string
+		ExpectedErr error
+	}{
+		{
+			"clone without a new id fails",
+			"clone helloplaybook staging",
+			commandHints,
+			&InvalidClone{},
+		},
+		{
+			"clone with a badly formatted var fails",
+			"clone helloplaybook staging mine word",
+			"",
+			&InvalidClone{},
+		},
+		{
+			"clone copies the instance with overrides",
+			"clone helloplaybook staging mine word=branch",
+			"Instance helloplaybook/mine cloned from staging",
+			nil,
+		},
+		{
+			"clone onto an existing instance fails",
+			"clone helloplaybook staging mine",
+			"Failed to clone helloplaybook/staging: Instance helloplaybook/mine already exists",
+			&InstanceExists{},
+		},
+		{
+			"clone of a missing instance fails",
+			"clone helloplaybook missing other",
+			"Failed to clone helloplaybook/missing: Instance not found",
+			instance.NotFoundError(""),
+		},
+	}
+	for _, testcase := range testcases {
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, NewJobService(ds), is, testPlaybooks)
+		msg, err := command.Execute(ctx)
+		assert.IsType(t, testcase.ExpectedErr, err, testcase.Scenario)
+		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+	}
+
+	i, err := is.Show(ctx, "helloplaybook", "mine")
+	assert.Nil(t, err)
+	assert.Equal(t, "branch", i.Vars["word"])
+}
+
+func TestListExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	for _, i := range []*instance.Instance{
+		{PlaybookID: "helloplaybook", ID: "broken", Status: instance.StatusError},
+		{PlaybookID: "helloplaybook", ID: "fine", Status: instance.StatusDeployed, Pinned: true},
+	} {
+		i.Path = instance.Path{testutils.TestCfg.EtcdPath, i.PlaybookID, i.ID}
+		assert.Nil(t, instance.Save(ctx, s, i))
+	}
+
+	testcases := []struct {
+		Scenario    string
+		Args        string
+		ExpectedMsg string
+		ExpectedErr error
+	}{
+		{
+			"list filters by status",
+			"list helloplaybook status=error",
+			"Found 1 instances:\n  - helloplaybook/broken: error, expires never\n",
+			nil,
+		},
+		{
+			"list pages through results",
+			"list limit=1",
+			"Found 2 instances:\n  - helloplaybook/broken: error, expires never\nUse offset=1 to see more\n",
+			nil,
+		},
+		{
+			"list without matches",
+			"list status=deploying",
+			"No instances found",
+			nil,
+		},
+		{
+			"list with a bad filter fails",
+			"list status=sleeping",
+			"Invalid status: unknown status sleeping",
+			&InvalidQuery{},
+		},
+	}
+	for _, testcase := range testcases {
+		command := BuildSlackCommand(testutils.TestCfg, testcase.Args, NewJobService(ds), is, testPlaybooks)
+		msg, err := command.Execute(ctx)
+		assert.IsType(t, testcase.ExpectedErr, err, testcase.Scenario)
+		assert.Equal(t, testcase.ExpectedMsg, msg, testcase.Scenario)
+	}
+}
+
+func TestQueueExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests))
+
+	command := BuildSlackCommand(testutils.TestCfg, "queue", js, is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "No jobs are running or queued", msg)
+
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "queued", Path: instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "queued"}}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	release := make(chan struct{})
+	block := func(ctx context.Context, i *instance.Instance) error {
+		<-release
+		return nil
+	}
+	running, err := js.start(ctx, job.ActionDeploy, i, block)
+	assert.Nil(t, err)
+	queued, err := js.start(ctx, job.ActionStop, i, block)
+	assert.Nil(t, err)
+
+	msg, err = command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "1 jobs running, 1 queued")
+	assert.Contains(t, msg, "running: deploy helloplaybook/queued by system:broadway")
+	assert.Contains(t, msg, running.ID)
+	assert.Contains(t, msg, "#1: stop helloplaybook/queued by system:broadway")
+	assert.Contains(t, msg, queued.ID)
+	close(release)
+	js.Wait()
+}
+
+func TestCancelExecute(t *testing.T) {
+	ctx := actor.NewContext(context.Background(), actor.Actor{Source: actor.SourceSlack, Name: "bill"})
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	js := NewJobService(NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests))
+
+	command := BuildSlackCommand(testutils.TestCfg, "cancel helloplaybook cancelled", js, is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Equal(t, &NoJobs{PlaybookID: "helloplaybook", InstanceID: "cancelled"}, err)
+	assert.Equal(t, "Can't cancel helloplaybook/cancelled: No deploy or stop is running or queued", msg)
+
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "cancelled", Path: instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "cancelled"}}
+	assert.Nil(t, instance.Save(ctx, s, i))
+	block := func(ctx context.Context, i *instance.Instance) error {
+		<-deployerContext(ctx).Done()
+		c, _ := cancelled(ctx)
+		return c
+	}
+	running, err := js.start(ctx, job.ActionDeploy, i, block)
+	assert.Nil(t, err)
+	queued, err := js.start(ctx, job.ActionStop, i, block)
+	assert.Nil(t, err)
+
+	msg, err = command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, msg, "Cancelling job "+running.ID+" (deploy helloplaybook/cancelled)")
+	assert.Contains(t, msg, "Cancelled job "+queued.ID+" (stop helloplaybook/cancelled) before it started")
+	js.Wait()
+}
+
+func TestLogsCommand(t *testing.T) {
+	testcases := []struct {
+		Scenario  string
+		Command   string
+		Container string
+		Tail      int64
+	}{
+		{"Defaults", "logs helloplaybook test", "", slackLogLines},
+		{"Container", "logs helloplaybook test web", "web", slackLogLines},
+		{"Tail", "logs helloplaybook test --tail 5 web", "web", 5},
+	}
+	for _, tc := range testcases {
+		command := BuildSlackCommand(testutils.TestCfg, tc.Command, nil, nil, testPlaybooks)
+		if c, ok := command.(*logsCommand); assert.True(t, ok, tc.Scenario) {
+			assert.Equal(t, tc.Container, c.container, tc.Scenario)
+			assert.Equal(t, tc.Tail, c.tail, tc.Scenario)
+		}
+	}
+	_, ok := BuildSlackCommand(testutils.TestCfg, "logs helloplaybook test --tail", nil, nil, testPlaybooks).(*helpCommand)
+	assert.True(t, ok, "a tail needs a number of lines")
+}
+
+func TestLogsExecute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(testutils.TestCfg, s)
+	ds := NewDeploymentService(testutils.TestCfg, s, testPlaybooks, testManifests)
+	ds.Deployers = deployment.NewFake().Factory
+	i := &instance.Instance{PlaybookID: "helloplaybook", ID: "logged", Path: instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "logged"}}
+	assert.Nil(t, instance.Save(ctx, s, i))
+
+	command := BuildSlackCommand(testutils.TestCfg, "logs helloplaybook logged", NewJobService(ds), is, testPlaybooks)
+	msg, err := command.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "Logs of helloplaybook/logged:\nhello/hello:\n```Fake logs of hello for helloplaybook/logged```\n", msg)
+}
+
+func TestLogTail(t *testing.T) {
+	assert.Equal(t, "one\ntwo", logTail("one\ntwo\n", 100))
+	assert.Equal(t, "…\nthree", logTail("one\ntwo\nthree\n", 8), "logs are cut at a line")
+}
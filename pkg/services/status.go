@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"golang.org/x/net/context"
+)
+
+// liveStatusTTL is how long the live status of an instance is reused before
+// the cluster is asked again
+const liveStatusTTL = 5 * time.Second
+
+// liveStatus is the live status of an instance read at a point in time
+type liveStatus struct {
+	key     string
+	read    time.Time
+	objects []deployment.ObjectStatus
+}
+
+// LiveStatus reads the state of the objects deployed for i from its cluster.
+// It's cached for a few seconds, until i is deployed or stopped again, so
+// polling it doesn't hammer the cluster. Instances that were never deployed,
+// or stopped, have no live status.
+func (d *DeploymentService) LiveStatus(ctx context.Context, i *instance.Instance) ([]deployment.ObjectStatus, error) {
+	if i.Status == instance.StatusNew || i.Status == instance.StatusStopped {
+		return []deployment.ObjectStatus{}, nil
+	}
+	playbook, ok := d.playbooks[i.PlaybookID]
+	if !ok {
+		return nil, &PlaybookNotFound{i.PlaybookID}
+	}
+
+	target := d.target(i)
+	key := fmt.Sprintf("%s:%s:%d", target, i.Status, i.Revision)
+	now := time.Now()
+	d.mu.Lock()
+	cached, ok := d.statuses[i.Path]
+	d.mu.Unlock()
+	if ok && cached.key == key && now.Sub(cached.read) < liveStatusTTL {
+		return cached.objects, nil
+	}
+
+	deployer, err := d.Deployers(deployment.Spec{
+		Target:    target,
+		Playbook:  playbook,
+		Variables: varMap(i),
+		Manifests: d.manifests,
+		Context:   ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := deployer.(deployment.StatusReader)
+	if !ok {
+		return []deployment.ObjectStatus{}, nil
+	}
+	objects, err := reader.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for path, s := range d.statuses {
+		if now.Sub(s.read) >= liveStatusTTL {
+			delete(d.statuses, path)
+		}
+	}
+	d.statuses[i.Path] = liveStatus{key: key, read: now, objects: objects}
+	return objects, nil
+}
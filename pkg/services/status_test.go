@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLiveStatus(t *testing.T) {
+	ctx := context.Background()
+	fake := deployment.NewFake()
+	ds := NewDeploymentService(testutils.TestCfg, store.NewMemory(), testPlaybooks, testManifests)
+	reads := 0
+	ds.Deployers = func(s deployment.Spec) (deployment.Deployer, error) {
+		reads++
+		return fake.Factory(s)
+	}
+
+	i := &instance.Instance{
+		PlaybookID: "helloplaybook",
+		ID:         "live",
+		Vars:       map[string]string{"word": "hi"},
+		Path:       instance.Path{testutils.TestCfg.EtcdPath, "helloplaybook", "live"},
+	}
+	objects, err := ds.LiveStatus(ctx, i)
+	assert.Nil(t, err)
+	assert.Empty(t, objects, "new instances aren't in the cluster")
+	assert.Equal(t, 0, reads)
+
+	i.Status = instance.StatusDeploying
+	objects, err = ds.LiveStatus(ctx, i)
+	assert.Nil(t, err)
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, "hello", objects[0].Manifest)
+		assert.True(t, objects[0].Missing)
+	}
+
+	d, _ := fake.Factory(deployment.Spec{Playbook: testPlaybooks["helloplaybook"], Variables: varMap(i), Manifests: testManifests})
+	assert.Nil(t, d.Deploy())
+	objects, _ = ds.LiveStatus(ctx, i)
+	assert.True(t, objects[0].Missing, "the live status is cached")
+	assert.Equal(t, 1, reads)
+
+	i.Status = instance.StatusDeployed
+	objects, _ = ds.LiveStatus(ctx, i)
+	assert.False(t, objects[0].Missing, "status changes skip the cache")
+	assert.Equal(t, 2, reads)
+
+	_, err = ds.LiveStatus(ctx, &instance.Instance{PlaybookID: "gone", ID: "live", Status: instance.StatusDeployed})
+	assert.IsType(t, &PlaybookNotFound{}, err)
+}
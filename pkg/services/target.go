@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+)
+
+// UnknownTarget indicates a cluster target that isn't defined
+type UnknownTarget struct {
+	Name string
+}
+
+func (e *UnknownTarget) Error() string {
+	return fmt.Sprintf("Target %s is not defined", e.Name)
+}
+
+// TargetInUse indicates an instance can't move to another target while its
+// workloads may still run on its current one
+type TargetInUse struct {
+	PlaybookID string
+	ID         string
+	Target     string
+}
+
+func (e *TargetInUse) Error() string {
+	return fmt.Sprintf("%s/%s runs on target %s, stop it before moving it to another target", e.PlaybookID, e.ID, e.Target)
+}
+
+// ValidateTargets checks that the default target and the targets chosen by
+// playbooks are defined
+func ValidateTargets(cfg cfg.Type, playbooks map[string]*deployment.Playbook) error {
+	if err := checkTarget(cfg, cfg.DefaultTarget); err != nil {
+		return err
+	}
+	for _, p := range playbooks {
+		if err := checkTarget(cfg, p.Target); err != nil {
+			return fmt.Errorf("Playbook %s: %s", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkTarget returns an error if the named target isn't defined. The empty
+// name stands for the cluster configured by the K8s settings.
+func checkTarget(cfg cfg.Type, name string) error {
+	if _, ok := cfg.Targets[name]; name != "" && !ok {
+		return &UnknownTarget{name}
+	}
+	return nil
+}
+
+// target returns the name of the cluster target i is deployed to: its own,
+// its playbook's or the default one, in that order
+func (d *DeploymentService) target(i *instance.Instance) string {
+	if i.Target != "" {
+		return i.Target
+	}
+	if p, ok := d.playbooks[i.PlaybookID]; ok && p.Target != "" {
+		return p.Target
+	}
+	return d.Cfg.DefaultTarget
+}
+
+// cluster returns the Kubernetes cluster of the named target, creating its
+// client the first time it's needed. The empty name stands for the cluster
+// configured by the K8s settings.
+func (d *DeploymentService) cluster(name string) (*deployment.Cluster, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.clusters[name]; ok {
+		return c, nil
+	}
+
+	config, err := deployment.Config(d.Cfg)
+	namespace := d.Cfg.K8sNamespace
+	if name != "" {
+		t, ok := d.Cfg.Targets[name]
+		if !ok {
+			return nil, &UnknownTarget{name}
+		}
+		config, err = deployment.TargetConfig(t)
+		if t.Namespace != "" {
+			namespace = t.Namespace
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := deployment.NewCluster(config, namespace)
+	if err != nil {
+		return nil, err
+	}
+	d.clusters[name] = c
+	return c, nil
+}
+
+// withTarget prefixes a message about i with its target, if it has one
+func withTarget(i *instance.Instance, msg string) string {
+	if i == nil || i.Target == "" {
+		return msg
+	}
+	return fmt.Sprintf("[%s] %s", i.Target, msg)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/namely/broadway/pkg/cfg"
+	"github.com/namely/broadway/pkg/deployment"
+	"github.com/namely/broadway/pkg/instance"
+	"github.com/namely/broadway/pkg/store"
+	"github.com/namely/broadway/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func targetsCfg() cfg.Type {
+	c := testutils.TestCfg
+	c.Targets = map[string]cfg.Target{
+		"staging":    {Host: "https://staging.example.com", Namespace: "apps"},
+		"production": {Host: "https://production.example.com"},
+	}
+	return c
+}
+
+func TestTargetOf(t *testing.T) {
+	c := targetsCfg()
+	playbooks := map[string]*deployment.Playbook{
+		"chosen": {ID: "chosen", Target: "production"},
+		"plain":  {ID: "plain"},
+	}
+	testcases := []struct {
+		Scenario      string
+		DefaultTarget string
+		Instance      *instance.Instance
+		Expected      string
+	}{
+		{"Instances choose their own target first", "staging", &instance.Instance{PlaybookID: "chosen", Target: "staging"}, "staging"},
+		{"Then their playbook's", "staging", &instance.Instance{PlaybookID: "chosen"}, "production"},
+		{"Then the default target", "staging", &instance.Instance{PlaybookID: "plain"}, "staging"},
+		{"Or the configured cluster", "", &instance.Instance{PlaybookID: "plain"}, ""},
+	}
+	for _, tc := range testcases {
+		c.DefaultTarget = tc.DefaultTarget
+		d := NewDeploymentService(c, store.NewMemory(), playbooks, nil)
+		assert.Equal(t, tc.Expected, d.target(tc.Instance), tc.Scenario)
+	}
+}
+
+func TestClusterPerTarget(t *testing.T) {
+	d := NewDeploymentService(targetsCfg(), store.NewMemory(), nil, nil)
+
+	staging, err := d.cluster("staging")
+	assert.Nil(t, err)
+	assert.Equal(t, "apps", staging.Namespace)
+	again, err := d.cluster("staging")
+	assert.Nil(t, err)
+	assert.True(t, staging == again, "clusters are created once")
+	production, err := d.cluster("production")
+	assert.Nil(t, err)
+	assert.Equal(t, testutils.TestCfg.K8sNamespace, production.Namespace, "targets default to the configured namespace")
+
+	_, err = d.cluster("missing")
+	assert.Equal(t, &UnknownTarget{"missing"}, err)
+}
+
+func TestValidateTargets(t *testing.T) {
+	c := targetsCfg()
+	assert.Nil(t, ValidateTargets(c, map[string]*deployment.Playbook{"a": {ID: "a", Target: "staging"}}))
+
+	err := ValidateTargets(c, map[string]*deployment.Playbook{"a": {ID: "a", Target: "qa"}})
+	assert.EqualError(t, err, "Playbook a: Target qa is not defined")
+
+	c.DefaultTarget = "qa"
+	assert.Equal(t, &UnknownTarget{"qa"}, ValidateTargets(c, nil))
+}
+
+func TestCreateOrUpdateTarget(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	is := NewInstanceService(targetsCfg(), s)
+
+	i, err := is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "targeted", Target: "staging"})
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", i.Target)
+
+	i, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "targeted"})
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", i.Target, "updates keep the target")
+
+	_, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "targeted", Target: "qa"})
+	assert.Equal(t, &UnknownTarget{"qa"}, err)
+
+	i.Status = instance.StatusDeployed
+	assert.Nil(t, instance.Save(ctx, s, i))
+	_, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "targeted", Target: "production"})
+	assert.Equal(t, &TargetInUse{"helloplaybook", "targeted", "staging"}, err)
+
+	i.Status = instance.StatusStopped
+	assert.Nil(t, instance.Save(ctx, s, i))
+	i, err = is.CreateOrUpdate(ctx, &instance.Instance{PlaybookID: "helloplaybook", ID: "targeted", Target: "production"})
+	assert.Nil(t, err)
+	assert.Equal(t, "production", i.Target, "stopped instances can move")
+}
@@ -2,6 +2,7 @@ package etcdstore
 
 import (
 	"strings"
+	"time"
 
 	etcdclient "github.com/coreos/etcd/client"
 	"github.com/golang/glog"
@@ -12,6 +13,13 @@ import (
 
 var api etcdclient.KeysAPI
 
+// DefaultTimeout is used for each etcd request when the configuration does not
+// specify one
+const DefaultTimeout = 5 * time.Second
+
+var timeout = DefaultTimeout
+var retries int
+
 // SetupEtcd configures etcdstore with an injected configuration
 func SetupEtcd(cfg cfg.Type) {
 	var err error
@@ -24,68 +32,116 @@ func SetupEtcd(cfg cfg.Type) {
 		glog.Fatal("wrong etcd client")
 	}
 	api = etcdclient.NewKeysAPI(client)
+
+	timeout = DefaultTimeout
+	if cfg.EtcdTimeout > 0 {
+		timeout = cfg.EtcdTimeout
+	}
+	retries = cfg.EtcdRetries
 }
 
-type etcdStore struct{}
+type etcdStore struct {
+	timeout time.Duration
+	retries int
+}
 
 // New instantiates and returns a Store using the etcd driver
 func New() store.Store {
-	return &etcdStore{}
+	return &etcdStore{timeout: timeout, retries: retries}
+}
+
+// do runs fn with a per-attempt timeout derived from ctx, retrying failures
+// that were not answered by etcd itself (timeouts, unreachable members)
+func (s *etcdStore) do(ctx context.Context, op, path string, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			glog.Warningf("Retrying etcd %s %s (attempt %d): %s", op, path, attempt, err)
+		}
+		callCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		err = fn(callCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(etcdclient.Error); ok {
+			return err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return &store.UnavailableError{Op: op, Path: path, Err: err}
 }
 
 // SetValue sets the string value for a string key. The key may include
 // '/' path separators.
-func (*etcdStore) SetValue(path, value string) error {
-	_, err := api.Set(context.Background(), path, value, nil)
-	return err
+func (s *etcdStore) SetValue(ctx context.Context, path, value string) error {
+	return s.do(ctx, "set", path, func(ctx context.Context) error {
+		_, err := api.Set(ctx, path, value, nil)
+		return err
+	})
 }
 
-// Value retrieves the string value for a string key.
-func (*etcdStore) Value(path string) string {
-	resp, err := api.Get(context.Background(), path, nil)
-	if err == nil && resp.Node != nil {
-		return resp.Node.Value
+// Value retrieves the string value for a string key. It returns
+// store.ErrNotFound if the key does not exist.
+func (s *etcdStore) Value(ctx context.Context, path string) (string, error) {
+	var resp *etcdclient.Response
+	err := s.do(ctx, "get", path, func(ctx context.Context) error {
+		var err error
+		resp, err = api.Get(ctx, path, nil)
+		return err
+	})
+	if etcdclient.IsKeyNotFound(err) {
+		return "", store.ErrNotFound
+	}
+	if err != nil {
+		return "", err
 	}
-	return ""
+	if resp.Node == nil {
+		return "", store.ErrNotFound
+	}
+	return resp.Node.Value, nil
 }
 
-// Values finds all leaf nodes under the given key. It strips any leading path
-// components from the keys and returns a key/value map. For example, given keys
+// Values finds all leaf nodes under the given key. It strips the given key
+// from the keys and returns a key/value map. For example, given keys
 // "animals/flea" and "animals/cats/egyptian", Values("animals") would return
-// {"flea" : "...", "egyptian": "..."}
-func (*etcdStore) Values(path string) (values map[string]string) {
-	values = map[string]string{}
-	resp, err := api.Get(context.Background(), path, &etcdclient.GetOptions{Recursive: true})
+// {"flea" : "...", "cats/egyptian": "..."}. A missing key yields an empty map.
+func (s *etcdStore) Values(ctx context.Context, path string) (map[string]string, error) {
+	values := map[string]string{}
+	var resp *etcdclient.Response
+	err := s.do(ctx, "list", path, func(ctx context.Context) error {
+		var err error
+		resp, err = api.Get(ctx, path, &etcdclient.GetOptions{Recursive: true})
+		return err
+	})
+	if etcdclient.IsKeyNotFound(err) {
+		return values, nil
+	}
 	if err != nil {
-		glog.Warningf("Ignoring error getting values: %s. Error: %s", path, err.Error())
-		return values
+		return nil, err
 	}
 	if resp.Node != nil && len(resp.Node.Nodes) > 0 {
-		valueFromNode(resp.Node.Nodes, values)
-	} else {
-		glog.Error("No values found here: " + path)
+		valueFromNode("/"+strings.Trim(path, "/")+"/", resp.Node.Nodes, values)
 	}
-	return values
+	return values, nil
 }
 
-func valueFromNode(nodes []*etcdclient.Node, values map[string]string) {
+func valueFromNode(prefix string, nodes []*etcdclient.Node, values map[string]string) {
 	for _, node := range nodes {
 		if node.Dir == false {
-			values[lastKeyItem(node.Key)] = node.Value
+			values[strings.TrimPrefix(node.Key, prefix)] = node.Value
 		} else {
-			valueFromNode(node.Nodes, values)
+			valueFromNode(prefix, node.Nodes, values)
 		}
 	}
 }
 
 // Delete removes the specified key and its value from the store
-func (*etcdStore) Delete(path string) error {
-	_, err := api.Delete(context.Background(), path, &etcdclient.DeleteOptions{Recursive: true})
-	return err
-}
-
-// lastKeyItem returns the last path element in a slash-separated key path
-func lastKeyItem(key string) string {
-	keyItems := strings.Split(key, "/")
-	return keyItems[len(keyItems)-1]
+func (s *etcdStore) Delete(ctx context.Context, path string) error {
+	return s.do(ctx, "delete", path, func(ctx context.Context) error {
+		_, err := api.Delete(ctx, path, &etcdclient.DeleteOptions{Recursive: true})
+		return err
+	})
 }
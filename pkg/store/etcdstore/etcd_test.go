@@ -3,8 +3,10 @@ package etcdstore
 import (
 	"testing"
 
+	"github.com/namely/broadway/pkg/store"
 	"github.com/namely/broadway/pkg/testutils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func init() {
@@ -12,49 +14,70 @@ func init() {
 }
 
 func TestValue(t *testing.T) {
+	ctx := context.Background()
 	s := New()
-	err := s.SetValue("/testing/a", "val")
+	err := s.SetValue(ctx, "/testing/a", "val")
 	assert.Nil(t, err)
 
-	val := s.Value("/testing/a")
+	val, err := s.Value(ctx, "/testing/a")
+	assert.Nil(t, err)
 	assert.Equal(t, "val", val)
 
-	err = s.SetValue("/testing/a", "ok")
+	err = s.SetValue(ctx, "/testing/a", "ok")
 	assert.Nil(t, err)
 
-	val = s.Value("/testing/a")
+	val, err = s.Value(ctx, "/testing/a")
+	assert.Nil(t, err)
 	assert.Equal(t, "ok", val)
 
-	val = s.Value("/testing/empty")
+	val, err = s.Value(ctx, "/testing/empty")
+	assert.Equal(t, store.ErrNotFound, err)
 	assert.Equal(t, "", val)
 }
 
 func TestValues(t *testing.T) {
+	ctx := context.Background()
 	s := New()
-	err := s.SetValue("/testing/vv/a", "A")
+	err := s.SetValue(ctx, "/testing/vv/a", "A")
+	assert.Nil(t, err)
+	err = s.SetValue(ctx, "/testing/vv/b", "B")
 	assert.Nil(t, err)
-	err = s.SetValue("/testing/vv/b", "B")
+	err = s.SetValue(ctx, "/testing/vv/c", "C")
 	assert.Nil(t, err)
-	err = s.SetValue("/testing/vv/c", "C")
+	err = s.SetValue(ctx, "/testing/vv/d/a", "DA")
 	assert.Nil(t, err)
 
-	values := s.Values("/testing/vv")
-	assert.Len(t, values, 3)
+	values, err := s.Values(ctx, "/testing/vv")
+	assert.Nil(t, err)
+	assert.Len(t, values, 4)
 	assert.Equal(t, "A", values["a"])
 	assert.Equal(t, "B", values["b"])
 	assert.Equal(t, "C", values["c"])
+	assert.Equal(t, "DA", values["d/a"], "nested keys don't collide")
 
-	values = s.Values("/testing/oooo")
+	values, err = s.Values(ctx, "/testing/oooo")
+	assert.Nil(t, err)
 	assert.Len(t, values, 0)
 }
 
 func TestDelete(t *testing.T) {
+	ctx := context.Background()
 	s := New()
-	err := s.SetValue("/testd", "A")
+	err := s.SetValue(ctx, "/testd", "A")
 	assert.Nil(t, err)
 
-	err = s.Delete("/testd")
+	err = s.Delete(ctx, "/testd")
 	assert.Nil(t, err)
 
-	assert.Equal(t, "", s.Value("/testd"))
+	_, err = s.Value(ctx, "/testd")
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := New()
+
+	_, err := s.Value(ctx, "/testing/a")
+	assert.True(t, store.IsUnavailable(err))
 }
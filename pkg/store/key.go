@@ -0,0 +1,18 @@
+package store
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// TimeKey returns the key of a record created at time now. Keys sort by time,
+// and end with a random suffix so that records created at the same time by
+// different requests or processes don't replace each other.
+func TimeKey(now time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%020d-%x", now.UnixNano(), suffix)
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeKey(t *testing.T) {
+	now := time.Date(2016, 8, 5, 0, 0, 0, 0, time.UTC)
+	k1, k2 := TimeKey(now), TimeKey(now)
+	assert.NotEqual(t, k1, k2, "keys created at the same time differ")
+	assert.True(t, strings.HasPrefix(k1, "01470355200000000000-"))
+
+	later := TimeKey(now.Add(time.Nanosecond))
+	keys := []string{later, k1}
+	sort.Strings(keys)
+	assert.Equal(t, later, keys[1], "keys sort by time")
+}
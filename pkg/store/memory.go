@@ -1,6 +1,11 @@
 package store
 
-import "sync"
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
 
 type memoryStore struct {
 	sync.Mutex
@@ -14,29 +19,52 @@ func NewMemory() Store {
 
 // SetValue sets the string value for a string key. The key may include
 // '/' path separators.
-func (s *memoryStore) SetValue(path, value string) error {
+func (s *memoryStore) SetValue(ctx context.Context, path, value string) error {
 	s.Lock()
 	s.store[path] = value
 	s.Unlock()
 	return nil
 }
 
-// Value retrieves the string value for a string key.
-func (s *memoryStore) Value(path string) string {
+// Value retrieves the string value for a string key. It returns ErrNotFound
+// if the key does not exist.
+func (s *memoryStore) Value(ctx context.Context, path string) (string, error) {
 	s.Lock()
 	defer s.Unlock()
-	return s.store[path]
+	v, ok := s.store[path]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
 }
 
-// Values finds all leaf nodes under the given key. It strips any leading path
-// components from the keys and returns a key/value map. For example, given keys
+// Values finds all leaf nodes under the given key. It strips the given key
+// from the keys and returns a key/value map. For example, given keys
 // "animals/flea" and "animals/cats/egyptian", Values("animals") would return
-// {"flea" : "...", "egyptian": "..."}
-func (s *memoryStore) Values(path string) map[string]string {
-	return s.store
+// {"flea" : "...", "cats/egyptian": "..."}
+func (s *memoryStore) Values(ctx context.Context, path string) (map[string]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	values := map[string]string{}
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for k, v := range s.store {
+		if strings.HasPrefix(k, prefix) {
+			values[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return values, nil
 }
 
-// Delete removes the specified key and its value from the store
-func (s *memoryStore) Delete(path string) error {
+// Delete removes the specified key, anything nested under it, and their values
+// from the store
+func (s *memoryStore) Delete(ctx context.Context, path string) error {
+	s.Lock()
+	defer s.Unlock()
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for k := range s.store {
+		if k == path || strings.HasPrefix(k, prefix) {
+			delete(s.store, k)
+		}
+	}
 	return nil
 }
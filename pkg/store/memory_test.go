@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMemoryValues(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	assert.Nil(t, s.SetValue(ctx, "/animals/flea", "F"))
+	assert.Nil(t, s.SetValue(ctx, "/animals/cats/egyptian", "E"))
+	assert.Nil(t, s.SetValue(ctx, "/animals/dogs/egyptian", "D"))
+	assert.Nil(t, s.SetValue(ctx, "/plants/fern", "P"))
+
+	values, err := s.Values(ctx, "/animals")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"flea":          "F",
+		"cats/egyptian": "E",
+		"dogs/egyptian": "D",
+	}, values)
+
+	assert.Nil(t, s.Delete(ctx, "/animals/cats"))
+	values, err = s.Values(ctx, "/animals")
+	assert.Nil(t, err)
+	assert.Len(t, values, 2)
+
+	_, err = s.Value(ctx, "/animals/cats/egyptian")
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -1,29 +1,31 @@
 package store
 
+import "golang.org/x/net/context"
+
 // FakeStore mock for the store
 type FakeStore struct {
 	MockSetValue func(path, value string) error
-	MockValue    func(path string) string
-	MockValues   func(path string) map[string]string
+	MockValue    func(path string) (string, error)
+	MockValues   func(path string) (map[string]string, error)
 	MockDelete   func(path string) error
 }
 
 // SetValue mocked implementation
-func (fs *FakeStore) SetValue(path, value string) error {
+func (fs *FakeStore) SetValue(ctx context.Context, path, value string) error {
 	return fs.MockSetValue(path, value)
 }
 
 // Value mocked implementation
-func (fs *FakeStore) Value(path string) string {
+func (fs *FakeStore) Value(ctx context.Context, path string) (string, error) {
 	return fs.MockValue(path)
 }
 
 // Values mocked implementation
-func (fs *FakeStore) Values(path string) map[string]string {
+func (fs *FakeStore) Values(ctx context.Context, path string) (map[string]string, error) {
 	return fs.MockValues(path)
 }
 
 // Delete mocked implementation
-func (fs *FakeStore) Delete(path string) error {
+func (fs *FakeStore) Delete(ctx context.Context, path string) error {
 	return fs.MockDelete(path)
 }
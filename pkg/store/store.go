@@ -1,9 +1,37 @@
 package store
 
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNotFound is returned by Value when the requested key does not exist
+var ErrNotFound = errors.New("broadway/store: key not found")
+
+// UnavailableError indicates the backend could not serve a request, for
+// example because it timed out or could not be reached
+type UnavailableError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("broadway/store: %s %s failed: %s", e.Op, e.Path, e.Err)
+}
+
+// IsUnavailable returns true if err was caused by an unavailable backend
+func IsUnavailable(err error) bool {
+	_, ok := err.(*UnavailableError)
+	return ok
+}
+
 // Store declares an interface for a key/value store
 type Store interface {
-	SetValue(path, value string) error
-	Value(path string) string
-	Values(path string) map[string]string
-	Delete(path string) error
+	SetValue(ctx context.Context, path, value string) error
+	Value(ctx context.Context, path string) (string, error)
+	Values(ctx context.Context, path string) (map[string]string, error)
+	Delete(ctx context.Context, path string) error
 }
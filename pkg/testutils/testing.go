@@ -17,6 +17,7 @@ var TestCfg = cfg.Type{
 	SlackWebhook:           "",
 	EtcdPath:               "/broadwaytest",
 	EtcdEndpoints:          "http://localhost:4001",
+	K8sServiceHost:         "http://localhost:8080",
 	K8sNamespace:           "broadway",
 	ManifestsExtension:     ".yml",
 	ManifestsPath:          "../../examples/manifests",